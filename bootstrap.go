@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/monitoring/metrics"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/snapshot"
+)
+
+// Builds the cache from the current state of the documents and edges tables, all the documents
+// are stored first so that the edge endpoints exist. The last irreversible block is recorded before
+// reading the tables and the cursor is set to it once done, so that the stream resumes from a block
+// that can not be undone, as there is no undo information for the snapshot. The changes applied
+// after that block are processed again, which is safe as storing documents and edges is idempotent
+func (m *deltaStreamHandler) bootstrap(chainSnapshot *snapshot.ChainSnapshot) error {
+	lastIrreversibleBlockNum, err := chainSnapshot.GetLastIrreversibleBlockNum()
+	if err != nil {
+		return fmt.Errorf("failed to get last irreversible block, error: %v", err)
+	}
+	log.Infof("Bootstrapping from table snapshot, last irreversible block: %v", lastIrreversibleBlockNum)
+	// The snapshot is not part of a block, so its changes must not be undoable
+	m.doccache.SetBlock(0)
+	count, err := m.bootstrapTable(chainSnapshot, m.config.DocTableName, func(data []byte) error {
+		chainDoc := &domain.ChainDocument{}
+		err := json.Unmarshal(data, chainDoc)
+		if err != nil {
+			return fmt.Errorf("error unmarshalling doc data: %v, error: %v", string(data), err)
+		}
+		err = m.doccache.StoreDocument(chainDoc, "")
+		if err != nil {
+			return fmt.Errorf("failed to store doc: %v, error: %v", chainDoc, err)
+		}
+		metrics.CreatedDocs.Inc()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to bootstrap documents, error: %v", err)
+	}
+	log.Infof("Bootstrapped %v documents", count)
+	count, err = m.bootstrapTable(chainSnapshot, m.config.EdgeTableName, func(data []byte) error {
+		chainEdge := &domain.ChainEdge{}
+		err := json.Unmarshal(data, chainEdge)
+		if err != nil {
+			return fmt.Errorf("error unmarshalling edge data: %v, error: %v", string(data), err)
+		}
+		err = m.doccache.MutateEdge(chainEdge, false, "")
+		if err != nil {
+			return fmt.Errorf("failed to mutate edge: %v, error: %v", chainEdge, err)
+		}
+		metrics.CreatedEdges.Inc()
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to bootstrap edges, error: %v", err)
+	}
+	log.Infof("Bootstrapped %v edges", count)
+	cursor := fmt.Sprintf("__%v__0__0", lastIrreversibleBlockNum)
+	err = m.doccache.UpdateCursor(cursor)
+	if err != nil {
+		return fmt.Errorf("failed to set cursor: %v, error: %v", cursor, err)
+	}
+	metrics.BlockNumber.Set(float64(lastIrreversibleBlockNum))
+	return nil
+}
+
+// Stores the rows of the table, the changes are batched and committed once per page
+func (m *deltaStreamHandler) bootstrapTable(chainSnapshot *snapshot.ChainSnapshot, table string, storeFn func(data []byte) error) (int, error) {
+	pageSize := int(chainSnapshot.PageSize)
+	stored := 0
+	m.doccache.StartBatch()
+	count, err := chainSnapshot.ReadTable(m.config.ContractName, table, func(data []byte) error {
+		err := storeFn(data)
+		if err != nil {
+			return err
+		}
+		stored++
+		if stored%pageSize == 0 {
+			err = m.doccache.Flush()
+			if err != nil {
+				return fmt.Errorf("failed to commit changes, error: %v", err)
+			}
+			m.doccache.StartBatch()
+			log.Infof("Stored %v rows of table: %v", stored, table)
+		}
+		return nil
+	})
+	flushErr := m.doccache.Flush()
+	if err != nil {
+		return count, err
+	}
+	if flushErr != nil {
+		return count, fmt.Errorf("failed to commit changes, error: %v", flushErr)
+	}
+	return count, nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/monitoring/metrics"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/stream"
+)
+
+// Appends a record to the capture archive, capture failures are logged but do not stop the
+// processing of the stream
+func (m *deltaStreamHandler) captureRecord(record *stream.DeltaRecord) {
+	if m.capture == nil {
+		return
+	}
+	err := m.capture.Record(record)
+	if err != nil {
+		log.Errorf(err, "Failed to capture record: %v", record)
+		return
+	}
+	metrics.CapturedRecords.Inc()
+}
+
+// Writes the buffered records to the capture archive
+func (m *deltaStreamHandler) flushCapture() {
+	if m.capture == nil {
+		return
+	}
+	err := m.capture.Flush()
+	if err != nil {
+		log.Error(err, "Failed to flush capture archive")
+	}
+}
+
+// Closes the current segment of the capture archive
+func (m *deltaStreamHandler) closeCapture() {
+	if m.capture == nil {
+		return
+	}
+	err := m.capture.Close()
+	if err != nil {
+		log.Error(err, "Failed to close capture archive")
+	}
+}
+
+// Extracts the records for the block range, specified as <start>-<end>, from the capture archive
+// stored in captureDir, the records are written to outputPath or to stdout if not specified
+func extractCapture(captureDir, blockRange, outputPath string) error {
+	bounds := strings.Split(blockRange, "-")
+	if len(bounds) != 2 {
+		return fmt.Errorf("invalid block range: %v, expected format: <start>-<end>", blockRange)
+	}
+	startBlock, err := strconv.ParseUint(strings.TrimSpace(bounds[0]), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid start block in range: %v, error: %v", blockRange, err)
+	}
+	endBlock, err := strconv.ParseUint(strings.TrimSpace(bounds[1]), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid end block in range: %v, error: %v", blockRange, err)
+	}
+	if captureDir == "" {
+		return fmt.Errorf("capture-dir must be specified to extract records")
+	}
+	//The archive is not created using NewArchive, as it would finalize the segment being written
+	//by a running document cache process
+	archive := &stream.Archive{Dir: captureDir}
+	var writer io.Writer = os.Stdout
+	if outputPath != "" {
+		file, err := os.Create(outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %v, error: %v", outputPath, err)
+		}
+		defer file.Close()
+		writer = file
+	}
+	extracted, err := archive.Extract(startBlock, endBlock, writer)
+	if err != nil {
+		return fmt.Errorf("failed to extract records for blocks: %v, error: %v", blockRange, err)
+	}
+	log.Infof("Extracted %v records for blocks: %v", extracted, blockRange)
+	return nil
+}
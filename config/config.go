@@ -2,7 +2,9 @@ package config
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/iancoleman/strcase"
 	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
@@ -10,29 +12,108 @@ import (
 	"github.com/spf13/viper"
 )
 
+// Number of blocks for which undo information is kept when not specified in the configuration
+const DefaultUndoJournalBlocks uint = 1000
+
+// Defines what to do when a delta can not be processed
+const (
+	// Stops the process, this is the default
+	FailurePolicy_Panic = "panic"
+	// Records the delta in the dead letter store and continues with the next one
+	FailurePolicy_SkipAndRecord = "skip-and-record"
+	// Retries processing the delta, if it still fails records it in the dead letter store
+	// and continues with the next one
+	FailurePolicy_RetryThenRecord = "retry-then-record"
+)
+
+// Defines where the deltas are read from
+const (
+	// Dfuse firehose, this is the default
+	DeltaSource_Firehose = "firehose"
+	// JSONL file with delta records
+	DeltaSource_File = "file"
+	// Nodeos state history plugin websocket endpoint
+	DeltaSource_Ship = "ship"
+)
+
+// Maximum number of mutations sent in a single request when committing a block, when not
+// specified in the configuration
+const DefaultMaxBatchSize uint = 100
+
+// Number of table rows requested per page when bootstrapping, when not specified in the configuration
+const DefaultBootstrapPageSize uint = 100
+
+// Maximum number of records stored in each capture segment when not specified in the configuration
+const DefaultCaptureSegmentSize uint = 10000
+
+// Block production interval, used to determine the expected cadence of heart beats
+const BlockInterval = 500 * time.Millisecond
+
+// Number of heart beat intervals without deltas or heart beats after which the stream is considered stalled,
+// when the stall timeout is not specified in the configuration
+const StallTimeoutHeartBeats = 3
+
+const (
+	DefaultMinStallTimeout   = time.Minute
+	DefaultReconnectMinDelay = time.Second
+	DefaultReconnectMaxDelay = 5 * time.Minute
+)
+
+const (
+	DefaultDeadLetterFile    = "dead-letters.jsonl"
+	DefaultFailureRetries    = 3
+	DefaultFailureRetryDelay = time.Second
+)
+
 // Loads, validates and stores the initial configuration
 type Config struct {
 	ContractName        string                   `mapstructure:"contract-name"`
 	DocTableName        string                   `mapstructure:"doc-table-name"`
 	EdgeTableName       string                   `mapstructure:"edge-table-name"`
+	DeltaSource         string                   `mapstructure:"delta-source"`
+	DeltaFile           string                   `mapstructure:"delta-file"`
 	FirehoseEndpoint    string                   `mapstructure:"firehose-endpoint"`
+	ShipEndpoint        string                   `mapstructure:"ship-endpoint"`
 	EosEndpoint         string                   `mapstructure:"eos-endpoint"`
 	DgraphAlphaHost     string                   `mapstructure:"dgraph-alpha-host"`
 	DgraphAlphaGRPCPort uint                     `mapstructure:"dgraph-alpha-grpc-port"`
 	DgraphAlphaHTTPPort uint                     `mapstructure:"dgraph-alpha-http-port"`
 	PrometheusPort      uint                     `mapstructure:"prometheus-port"`
 	StartBlock          int64                    `mapstructure:"start-block"`
+	StopBlock           uint64                   `mapstructure:"stop-block"`
 	HeartBeatFrequency  uint                     `mapstructure:"heart-beat-frequency"`
+	IrreversibleOnly    bool                     `mapstructure:"irreversible-only"`
+	StallTimeout        time.Duration            `mapstructure:"stall-timeout"`
+	ReconnectMinDelay   time.Duration            `mapstructure:"reconnect-min-delay"`
+	ReconnectMaxDelay   time.Duration            `mapstructure:"reconnect-max-delay"`
 	DfuseApiKey         string                   `mapstructure:"dfuse-api-key"`
 	DfuseAuthURL        string                   `mapstructure:"dfuse-auth-url"`
 	ElasticEndpoint     string                   `mapstructure:"elastic-endpoint"`
 	ElasticApiKey       string                   `mapstructure:"elastic-api-key"`
+	UndoJournalBlocks   uint                     `mapstructure:"undo-journal-blocks"`
+	MaxBatchSize        uint                     `mapstructure:"max-batch-size"`
+	FailurePolicy       string                   `mapstructure:"failure-policy"`
+	FailureRetries      uint                     `mapstructure:"failure-retries"`
+	FailureRetryDelay   time.Duration            `mapstructure:"failure-retry-delay"`
+	DeadLetterFile      string                   `mapstructure:"dead-letter-file"`
+	CaptureDir          string                   `mapstructure:"capture-dir"`
+	CaptureSegmentSize  uint                     `mapstructure:"capture-segment-size"`
+	CaptureMaxSegments  uint                     `mapstructure:"capture-max-segments"`
+	BootstrapPageSize   uint                     `mapstructure:"bootstrap-page-size"`
 	TypeMappingsRaw     []map[string]interface{} `mapstructure:"type-mappings"`
-	TypeMappings        map[string][]string
+	TypeMappingTieBreak string                   `mapstructure:"type-mapping-tie-break"`
+	TypeMappings        *domain.TypeMappings
 	InterfacesRaw       []map[string]interface{} `mapstructure:"custom-interfaces"`
 	Interfaces          gql.SimplifiedInterfaces
 	LogicalIdsRaw       []map[string]interface{} `mapstructure:"logical-ids"`
 	LogicalIds          domain.LogicalIds
+	InverseEdgesRaw     []map[string]interface{} `mapstructure:"inverse-edges"`
+	InverseEdges        domain.InverseEdges
+	EdgeMetadata        bool     `mapstructure:"edge-metadata"`
+	HistoryTypesRaw     []string `mapstructure:"history-types"`
+	HistoryTypes        map[string]bool
+	SoftDeleteTypesRaw  []string `mapstructure:"soft-delete-types"`
+	SoftDeleteTypes     map[string]bool
 	DgraphGRPCEndpoint  string
 	DgraphHTTPURL       string
 	GQLAdminURL         string
@@ -59,8 +140,67 @@ func LoadConfig(filePath string) (*Config, error) {
 	config.DgraphHTTPURL = fmt.Sprintf("http://%v:%v", config.DgraphAlphaHost, config.DgraphAlphaHTTPPort)
 	config.GQLAdminURL = joinUrl(config.DgraphHTTPURL, "admin")
 	config.GQLClientURL = joinUrl(config.DgraphHTTPURL, "graphql")
+	if config.StopBlock > 0 && config.StartBlock > 0 && uint64(config.StartBlock) > config.StopBlock {
+		return nil, fmt.Errorf("stop-block: %v must not be lower than start-block: %v", config.StopBlock, config.StartBlock)
+	}
+	if config.StallTimeout == 0 {
+		config.StallTimeout = time.Duration(config.HeartBeatFrequency+1) * BlockInterval * StallTimeoutHeartBeats
+		if config.StallTimeout < DefaultMinStallTimeout {
+			config.StallTimeout = DefaultMinStallTimeout
+		}
+	}
+	if config.ReconnectMinDelay == 0 {
+		config.ReconnectMinDelay = DefaultReconnectMinDelay
+	}
+	if config.ReconnectMaxDelay == 0 {
+		config.ReconnectMaxDelay = DefaultReconnectMaxDelay
+	}
+	if config.ReconnectMaxDelay < config.ReconnectMinDelay {
+		return nil, fmt.Errorf("reconnect-max-delay: %v must not be lower than reconnect-min-delay: %v", config.ReconnectMaxDelay, config.ReconnectMinDelay)
+	}
+	if config.UndoJournalBlocks == 0 {
+		config.UndoJournalBlocks = DefaultUndoJournalBlocks
+	}
+	if config.DeltaSource == "" {
+		config.DeltaSource = DeltaSource_Firehose
+	}
+	if config.DeltaSource != DeltaSource_Firehose && config.DeltaSource != DeltaSource_File && config.DeltaSource != DeltaSource_Ship {
+		return nil, fmt.Errorf("invalid delta source: %v, valid values are: %v, %v, %v", config.DeltaSource, DeltaSource_Firehose, DeltaSource_File, DeltaSource_Ship)
+	}
+	if config.DeltaSource == DeltaSource_File && config.DeltaFile == "" {
+		return nil, fmt.Errorf("delta-file must be specified when using the %v delta source", DeltaSource_File)
+	}
+	if config.DeltaSource == DeltaSource_Ship && config.ShipEndpoint == "" {
+		return nil, fmt.Errorf("ship-endpoint must be specified when using the %v delta source", DeltaSource_Ship)
+	}
+	if config.MaxBatchSize == 0 {
+		config.MaxBatchSize = DefaultMaxBatchSize
+	}
+	if config.FailurePolicy == "" {
+		config.FailurePolicy = FailurePolicy_Panic
+	}
+	if config.FailurePolicy != FailurePolicy_Panic &&
+		config.FailurePolicy != FailurePolicy_SkipAndRecord &&
+		config.FailurePolicy != FailurePolicy_RetryThenRecord {
+		return nil, fmt.Errorf("invalid failure policy: %v, valid values are: %v, %v, %v", config.FailurePolicy, FailurePolicy_Panic, FailurePolicy_SkipAndRecord, FailurePolicy_RetryThenRecord)
+	}
+	if !viper.IsSet("failure-retries") {
+		config.FailureRetries = DefaultFailureRetries
+	}
+	if config.FailureRetryDelay == 0 {
+		config.FailureRetryDelay = DefaultFailureRetryDelay
+	}
+	if config.DeadLetterFile == "" {
+		config.DeadLetterFile = DefaultDeadLetterFile
+	}
+	if config.CaptureSegmentSize == 0 {
+		config.CaptureSegmentSize = DefaultCaptureSegmentSize
+	}
+	if config.BootstrapPageSize == 0 {
+		config.BootstrapPageSize = DefaultBootstrapPageSize
+	}
 	if config.TypeMappingsRaw != nil {
-		config.TypeMappings, err = processTypeMappings(config.TypeMappingsRaw)
+		config.TypeMappings, err = processTypeMappings(config.TypeMappingsRaw, config.TypeMappingTieBreak)
 		if err != nil {
 			return nil, fmt.Errorf("failed to parse type mappings configuration, error: %v", err)
 		}
@@ -77,28 +217,87 @@ func LoadConfig(filePath string) (*Config, error) {
 			return nil, fmt.Errorf("failed to parse logical ids configuration, error: %v", err)
 		}
 	}
+	if config.InverseEdgesRaw != nil {
+		config.InverseEdges, err = parseInverseEdgesConfig(config.InverseEdgesRaw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse inverse edges configuration, error: %v", err)
+		}
+	}
+	config.HistoryTypes = parseTypeNamesConfig(config.HistoryTypesRaw)
+	config.SoftDeleteTypes = parseTypeNamesConfig(config.SoftDeleteTypesRaw)
 	return &config, nil
 }
 
-// Processes configuration that allow the identification of types based on the object properties
-func processTypeMappings(raw []map[string]interface{}) (map[string][]string, error) {
-	typeMappings := make(map[string][]string)
+// Processes configuration that allow the identification of types based on the object properties,
+// verifies that the mappings do not overlap
+func processTypeMappings(raw []map[string]interface{}, tieBreak string) (*domain.TypeMappings, error) {
+	typeMappings, err := domain.NewTypeMappings(tieBreak)
+	if err != nil {
+		return nil, err
+	}
 	for _, mapping := range raw {
-		fullLabels := make([]string, 0)
-		for groupLabel, labels := range mapping["labels"].(map[interface{}]interface{}) {
-			for _, label := range labels.([]interface{}) {
-				fullLabels = append(fullLabels, fmt.Sprintf("%v_%v", groupLabel, strcase.ToLowerCamel(label.(string))))
+		typeName, _ := mapping["type"].(string)
+		if typeName == "" {
+			return nil, fmt.Errorf("type mapping: %v has no type", mapping)
+		}
+		typeName = domain.GetObjectTypeName(typeName)
+		priority, ok := mapping["priority"].(int)
+		if !ok && mapping["priority"] != nil {
+			return nil, fmt.Errorf("type mapping for type: %v has invalid priority: %v, it must be an integer", typeName, mapping["priority"])
+		}
+		match, _ := mapping["match"].(string)
+		conditions := make([]*domain.TypeMappingCondition, 0)
+		labels, _ := mapping["labels"].(map[interface{}]interface{})
+		for _, groupLabel := range sortedKeys(labels) {
+			for _, label := range labels[groupLabel].([]interface{}) {
+				conditions = append(conditions, domain.NewLabelCondition(getMappingLabel(groupLabel, label)))
+			}
+		}
+		equals, _ := mapping["equals"].(map[interface{}]interface{})
+		for _, groupLabel := range sortedKeys(equals) {
+			values := equals[groupLabel].(map[interface{}]interface{})
+			for _, label := range sortedKeys(values) {
+				conditions = append(conditions, domain.NewEqualsCondition(getMappingLabel(groupLabel, label), fmt.Sprint(values[label])))
 			}
 		}
-		typeName := domain.GetObjectTypeName(mapping["type"].(string))
-		if len(fullLabels) == 0 {
-			return nil, fmt.Errorf("type mapping for type: %v has no labels", typeName)
+		contentGroups, _ := mapping["content-groups"].([]interface{})
+		for _, contentGroup := range contentGroups {
+			conditions = append(conditions, domain.NewContentGroupCondition(contentGroup.(string)))
+		}
+		err = typeMappings.Add(&domain.TypeMapping{
+			Type:       typeName,
+			Priority:   priority,
+			Match:      match,
+			Conditions: conditions,
+		})
+		if err != nil {
+			return nil, err
 		}
-		typeMappings[typeName] = fullLabels
+	}
+	err = typeMappings.CheckOverlaps()
+	if err != nil {
+		return nil, err
 	}
 	return typeMappings, nil
 }
 
+// Returns the content label prefixed by its content group as used by the type mappings
+func getMappingLabel(groupLabel, label interface{}) string {
+	return fmt.Sprintf("%v_%v", groupLabel, strcase.ToLowerCamel(label.(string)))
+}
+
+// Returns the keys of the yaml map sorted, so that the configuration is processed in a deterministic order
+func sortedKeys(m map[interface{}]interface{}) []interface{} {
+	keys := make([]interface{}, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j])
+	})
+	return keys
+}
+
 // Processes configuration that defines custom gql interfaces
 func parseInterfaceConfig(config []map[string]interface{}) (gql.SimplifiedInterfaces, error) {
 	interfaces := gql.NewSimplifiedInterfaces()
@@ -218,6 +417,32 @@ func parseLogicalIdsConfig(config []map[string]interface{}) (domain.LogicalIds,
 	return logicalIds, nil
 }
 
+// Processes configuration that defines the edges that should be maintained in both directions
+func parseInverseEdgesConfig(config []map[string]interface{}) (domain.InverseEdges, error) {
+	inverseEdges := domain.NewInverseEdges()
+	for _, pairConfig := range config {
+		edgeName, _ := pairConfig["edge"].(string)
+		inverseName, _ := pairConfig["inverse"].(string)
+		if edgeName == "" || inverseName == "" {
+			return nil, fmt.Errorf("edge and inverse names must be specified, found edge: %v, inverse: %v", pairConfig["edge"], pairConfig["inverse"])
+		}
+		err := inverseEdges.Set(edgeName, inverseName)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return inverseEdges, nil
+}
+
+// Processes configuration that defines a set of types, i.e. the types for which history is kept
+func parseTypeNamesConfig(config []string) map[string]bool {
+	typeNames := make(map[string]bool, len(config))
+	for _, typeName := range config {
+		typeNames[domain.GetObjectTypeName(typeName)] = true
+	}
+	return typeNames
+}
+
 func (m *Config) String() string {
 	return fmt.Sprintf(
 		`
@@ -225,7 +450,10 @@ func (m *Config) String() string {
 				ContractName: %v
 				DocTableName: %v
 				EdgeTableName: %v
+				DeltaSource: %v
+				DeltaFile: %v
 				FirehoseEndpoint: %v
+				ShipEndpoint: %v
 				EosEndpoint: %v
 				DgraphAlphaHost: %v
 				DgraphAlphaGRPCPort: %v
@@ -234,21 +462,44 @@ func (m *Config) String() string {
 				DgraphHTTPURL: %v      
 				PrometheusPort: %v
 				StartBlock: %v
+				StopBlock: %v
 				HeartBeatFrequency: %v
+				IrreversibleOnly: %v
+				StallTimeout: %v
+				ReconnectMinDelay: %v
+				ReconnectMaxDelay: %v
 				DfuseApiKey: %v
 				DfuseAuthURL: %v
 				TypeMappingsRaw: %v
+				TypeMappingTieBreak: %v
 				TypeMappings: %v
 				GQLAdminURL: %v
 				GQLClientURL: %v
 				ElasticEndpoint: %v
 				ElasticApiKey: %v
+				UndoJournalBlocks: %v
+				MaxBatchSize: %v
+				FailurePolicy: %v
+				FailureRetries: %v
+				FailureRetryDelay: %v
+				DeadLetterFile: %v
+				CaptureDir: %v
+				CaptureSegmentSize: %v
+				CaptureMaxSegments: %v
+				BootstrapPageSize: %v
+				InverseEdges: %v
+				EdgeMetadata: %v
+				HistoryTypes: %v
+				SoftDeleteTypes: %v
 			}
 		`,
 		m.ContractName,
 		m.DocTableName,
 		m.EdgeTableName,
+		m.DeltaSource,
+		m.DeltaFile,
 		m.FirehoseEndpoint,
+		m.ShipEndpoint,
 		m.EosEndpoint,
 		m.DgraphAlphaHost,
 		m.DgraphAlphaGRPCPort,
@@ -257,15 +508,35 @@ func (m *Config) String() string {
 		m.DgraphHTTPURL,
 		m.PrometheusPort,
 		m.StartBlock,
+		m.StopBlock,
 		m.HeartBeatFrequency,
+		m.IrreversibleOnly,
+		m.StallTimeout,
+		m.ReconnectMinDelay,
+		m.ReconnectMaxDelay,
 		m.DfuseApiKey,
 		m.DfuseAuthURL,
 		m.TypeMappingsRaw,
+		m.TypeMappingTieBreak,
 		m.TypeMappings,
 		m.GQLAdminURL,
 		m.GQLClientURL,
 		m.ElasticEndpoint,
 		m.ElasticApiKey,
+		m.UndoJournalBlocks,
+		m.MaxBatchSize,
+		m.FailurePolicy,
+		m.FailureRetries,
+		m.FailureRetryDelay,
+		m.DeadLetterFile,
+		m.CaptureDir,
+		m.CaptureSegmentSize,
+		m.CaptureMaxSegments,
+		m.BootstrapPageSize,
+		m.InverseEdges,
+		m.EdgeMetadata,
+		m.HistoryTypes,
+		m.SoftDeleteTypes,
 	)
 }
 
@@ -2,6 +2,7 @@ package config_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/sebastianmontero/hypha-document-cache-gql-go/config"
 	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
@@ -17,27 +18,94 @@ func TestOptionalConfigsNotSet(t *testing.T) {
 	assert.Assert(t, config.TypeMappings == nil)
 	assert.Assert(t, config.Interfaces == nil)
 	assert.Assert(t, config.LogicalIds == nil)
-	assert.Equal(t, len(config.TypeMappings), 0)
+	assert.Assert(t, config.InverseEdges == nil)
+	assert.Equal(t, config.EdgeMetadata, false)
+	assert.Equal(t, len(config.HistoryTypes), 0)
+	assert.Equal(t, len(config.SoftDeleteTypes), 0)
+	assert.Equal(t, config.TypeMappings.Len(), 0)
 	assert.Equal(t, len(config.Interfaces), 0)
 	assert.Equal(t, len(config.LogicalIds), 0)
+	assert.Equal(t, config.DeltaSource, "firehose")
+	assert.Equal(t, config.MaxBatchSize, uint(100))
+	assert.Equal(t, config.FailurePolicy, "panic")
+	assert.Equal(t, config.FailureRetries, uint(3))
+	assert.Equal(t, config.FailureRetryDelay, time.Second)
+	assert.Equal(t, config.DeadLetterFile, "dead-letters.jsonl")
+	assert.Equal(t, config.CaptureDir, "")
+	assert.Equal(t, config.CaptureSegmentSize, uint(10000))
+	assert.Equal(t, config.CaptureMaxSegments, uint(0))
+	assert.Equal(t, config.BootstrapPageSize, uint(100))
+	assert.Equal(t, config.StopBlock, uint64(0))
+	assert.Equal(t, config.IrreversibleOnly, false)
+	assert.Equal(t, config.StallTimeout, 151500*time.Millisecond)
+	assert.Equal(t, config.ReconnectMinDelay, time.Second)
+	assert.Equal(t, config.ReconnectMaxDelay, 5*time.Minute)
 }
 
 func TestLoadTypeMappings(t *testing.T) {
 	config, err := config.LoadConfig("./config-type-mappings.yml")
 	assert.NilError(t, err)
-	expected := map[string][]string{
-		"VoteTally": {
-			"pass_votePower",
-			"fail_votePower",
+	assert.Equal(t, config.TypeMappings.TieBreak, domain.TypeMappingTieBreak_Error)
+	expected := []*domain.TypeMapping{
+		{
+			Type:  "VoteTally",
+			Match: domain.TypeMappingMatch_All,
+			Conditions: []*domain.TypeMappingCondition{
+				domain.NewLabelCondition("fail_votePower"),
+				domain.NewLabelCondition("pass_votePower"),
+			},
 		},
-		"Assignment": {
-			"details_assignment",
+		{
+			Type:  "Assignment",
+			Match: domain.TypeMappingMatch_All,
+			Conditions: []*domain.TypeMappingCondition{
+				domain.NewLabelCondition("details_assignment"),
+			},
 		},
 	}
 	AssertTypeMappings(t, config.TypeMappings, expected)
 
 }
 
+func TestLoadTypeMappingsWithRules(t *testing.T) {
+	config, err := config.LoadConfig("./config-type-mappings-rules.yml")
+	assert.NilError(t, err)
+	assert.Equal(t, config.TypeMappings.TieBreak, domain.TypeMappingTieBreak_First)
+	expected := []*domain.TypeMapping{
+		{
+			Type:     "Payout",
+			Priority: 10,
+			Match:    domain.TypeMappingMatch_Any,
+			Conditions: []*domain.TypeMappingCondition{
+				domain.NewLabelCondition("details_recipient"),
+				domain.NewContentGroupCondition("payout"),
+			},
+		},
+		{
+			Type:  "Assignment",
+			Match: domain.TypeMappingMatch_All,
+			Conditions: []*domain.TypeMappingCondition{
+				domain.NewLabelCondition("details_assignee"),
+				domain.NewEqualsCondition("system_kind", "assignment"),
+				domain.NewEqualsCondition("system_version", "2"),
+			},
+		},
+		{
+			Type:  "Role",
+			Match: domain.TypeMappingMatch_All,
+			Conditions: []*domain.TypeMappingCondition{
+				domain.NewLabelCondition("details_assignee"),
+			},
+		},
+	}
+	AssertTypeMappings(t, config.TypeMappings, expected)
+}
+
+func TestLoadTypeMappingsShouldFailForOverlappingMappings(t *testing.T) {
+	_, err := config.LoadConfig("./config-type-mappings-overlap.yml")
+	assert.ErrorContains(t, err, "overlap")
+}
+
 func TestLoadInterfaces(t *testing.T) {
 	config, err := config.LoadConfig("./config-interfaces.yml")
 	assert.NilError(t, err)
@@ -170,11 +238,114 @@ func TestLoadLogicalIdsShouldFailForInvalidType(t *testing.T) {
 	assert.ErrorContains(t, err, "id fields can only be of IDable types")
 }
 
-func AssertTypeMappings(t *testing.T, actual, expected map[string][]string) {
-	assert.Equal(t, len(actual), len(expected), "Different number of types actual: %v, expected: %v", actual, expected)
-	for eName, eFields := range expected {
-		aFields := actual[eName]
-		util.AssertUnorderedStrArray(t, aFields, eFields)
+func TestLoadInverseEdges(t *testing.T) {
+	config, err := config.LoadConfig("./config-inverse-edges.yml")
+	assert.NilError(t, err)
+	expected := domain.NewInverseEdges()
+	assert.NilError(t, expected.Set("assigned", "assignee"))
+	assert.NilError(t, expected.Set("ownedby", "owns"))
+	assert.DeepEqual(t, config.InverseEdges, expected)
+	assert.Equal(t, config.EdgeMetadata, true)
+}
+
+func TestLoadInverseEdgesShouldFailForEdgeWithTwoInverses(t *testing.T) {
+	_, err := config.LoadConfig("./config-inverse-edges-invalid.yml")
+	assert.ErrorContains(t, err, "edge: assigned can not be the inverse of: owner")
+}
+
+func TestLoadTypeNames(t *testing.T) {
+	config, err := config.LoadConfig("./config-type-names.yml")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, config.HistoryTypes, map[string]bool{
+		"Proposal":    true,
+		"Assignment":  true,
+		"DhoSettings": true,
+	})
+	assert.DeepEqual(t, config.SoftDeleteTypes, map[string]bool{
+		"Proposal": true,
+	})
+}
+
+func TestLoadFailurePolicy(t *testing.T) {
+	config, err := config.LoadConfig("./config-failure-policy.yml")
+	assert.NilError(t, err)
+	assert.Equal(t, config.FailurePolicy, "retry-then-record")
+	assert.Equal(t, config.FailureRetries, uint(5))
+	assert.Equal(t, config.FailureRetryDelay, 500*time.Millisecond)
+	assert.Equal(t, config.DeadLetterFile, "/tmp/doccache-dead-letters.jsonl")
+}
+
+func TestLoadFailurePolicyNoRetries(t *testing.T) {
+	config, err := config.LoadConfig("./config-failure-policy-no-retries.yml")
+	assert.NilError(t, err)
+	assert.Equal(t, config.FailurePolicy, "retry-then-record")
+	assert.Equal(t, config.FailureRetries, uint(0))
+}
+
+func TestLoadFailurePolicyShouldFailForInvalidPolicy(t *testing.T) {
+	_, err := config.LoadConfig("./config-failure-policy-invalid.yml")
+	assert.ErrorContains(t, err, "invalid failure policy: ignore")
+}
+
+func TestLoadFileDeltaSource(t *testing.T) {
+	config, err := config.LoadConfig("./config-file-delta-source.yml")
+	assert.NilError(t, err)
+	assert.Equal(t, config.DeltaSource, "file")
+	assert.Equal(t, config.DeltaFile, "./deltas.jsonl")
+}
+
+func TestLoadFileDeltaSourceShouldFailForNoFile(t *testing.T) {
+	_, err := config.LoadConfig("./config-file-delta-source-no-file.yml")
+	assert.ErrorContains(t, err, "delta-file must be specified")
+}
+
+func TestLoadShipDeltaSource(t *testing.T) {
+	config, err := config.LoadConfig("./config-ship-delta-source.yml")
+	assert.NilError(t, err)
+	assert.Equal(t, config.DeltaSource, "ship")
+	assert.Equal(t, config.ShipEndpoint, "ws://localhost:8080")
+}
+
+func TestLoadShipDeltaSourceShouldFailForNoEndpoint(t *testing.T) {
+	_, err := config.LoadConfig("./config-ship-delta-source-no-endpoint.yml")
+	assert.ErrorContains(t, err, "ship-endpoint must be specified")
+}
+
+func TestLoadStopBlock(t *testing.T) {
+	config, err := config.LoadConfig("./config-stop-block.yml")
+	assert.NilError(t, err)
+	assert.Equal(t, config.StartBlock, int64(136860100))
+	assert.Equal(t, config.StopBlock, uint64(136870100))
+}
+
+func TestLoadStopBlockShouldFailForStopBlockLowerThanStartBlock(t *testing.T) {
+	_, err := config.LoadConfig("./config-stop-block-invalid.yml")
+	assert.ErrorContains(t, err, "must not be lower than start-block")
+}
+
+func TestLoadIrreversibleOnly(t *testing.T) {
+	config, err := config.LoadConfig("./config-irreversible-only.yml")
+	assert.NilError(t, err)
+	assert.Equal(t, config.IrreversibleOnly, true)
+}
+
+func TestLoadReconnect(t *testing.T) {
+	config, err := config.LoadConfig("./config-reconnect.yml")
+	assert.NilError(t, err)
+	assert.Equal(t, config.StallTimeout, 30*time.Second)
+	assert.Equal(t, config.ReconnectMinDelay, 500*time.Millisecond)
+	assert.Equal(t, config.ReconnectMaxDelay, time.Minute)
+}
+
+func TestLoadReconnectShouldFailForMaxDelayLowerThanMinDelay(t *testing.T) {
+	_, err := config.LoadConfig("./config-reconnect-invalid.yml")
+	assert.ErrorContains(t, err, "must not be lower than reconnect-min-delay")
+}
+
+func AssertTypeMappings(t *testing.T, actual *domain.TypeMappings, expected []*domain.TypeMapping) {
+	assert.Equal(t, actual.Len(), len(expected), "Different number of type mappings actual: %v, expected: %v", actual, expected)
+	for i, eMapping := range expected {
+		assert.DeepEqual(t, actual.Mappings[i], eMapping)
 	}
 }
 
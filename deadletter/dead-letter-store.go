@@ -0,0 +1,152 @@
+package deadletter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/stream"
+)
+
+// Represents a delta that could not be processed, along with the reason of the failure
+type DeadLetter struct {
+	Delta      *stream.DeltaRecord `json:"delta"`
+	Error      string              `json:"error"`
+	Attempts   uint                `json:"attempts"`
+	RecordedAt time.Time           `json:"recordedAt"`
+}
+
+func NewDeadLetter(delta *stream.DeltaRecord, err error, attempts uint) *DeadLetter {
+	return &DeadLetter{
+		Delta:      delta,
+		Error:      err.Error(),
+		Attempts:   attempts,
+		RecordedAt: time.Now().UTC(),
+	}
+}
+
+func (m *DeadLetter) String() string {
+	return fmt.Sprintf("DeadLetter{Delta: %v, Error: %v, Attempts: %v, RecordedAt: %v}", m.Delta, m.Error, m.Attempts, m.RecordedAt)
+}
+
+// File backed store for the deltas that could not be processed, each dead letter is stored
+// as a JSON line, so that the deltas can be inspected and re-driven once the cause of the
+// failure has been fixed
+type Store struct {
+	FilePath string
+}
+
+func NewStore(filePath string) *Store {
+	return &Store{
+		FilePath: filePath,
+	}
+}
+
+// Appends a dead letter to the store
+func (m *Store) Record(deadLetter *DeadLetter) error {
+	line, err := json.Marshal(deadLetter)
+	if err != nil {
+		return fmt.Errorf("failed to encode dead letter: %v, error: %v", deadLetter, err)
+	}
+	file, err := os.OpenFile(m.FilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open dead letter store: %v, error: %v", m.FilePath, err)
+	}
+	defer file.Close()
+	_, err = file.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write dead letter: %v to store: %v, error: %v", deadLetter, m.FilePath, err)
+	}
+	return file.Sync()
+}
+
+// Returns the stored dead letters in the order in which they were recorded
+func (m *Store) Load() ([]*DeadLetter, error) {
+	deadLetters := make([]*DeadLetter, 0)
+	file, err := os.Open(m.FilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return deadLetters, nil
+		}
+		return nil, fmt.Errorf("failed to open dead letter store: %v, error: %v", m.FilePath, err)
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		deadLetter := &DeadLetter{}
+		err = json.Unmarshal(scanner.Bytes(), deadLetter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode dead letter at line: %v of store: %v, error: %v", lineNum, m.FilePath, err)
+		}
+		deadLetters = append(deadLetters, deadLetter)
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dead letter store: %v, error: %v", m.FilePath, err)
+	}
+	return deadLetters, nil
+}
+
+// Replaces the contents of the store with the provided dead letters, the store file is
+// removed if there are no dead letters
+func (m *Store) Replace(deadLetters []*DeadLetter) error {
+	if len(deadLetters) == 0 {
+		err := os.Remove(m.FilePath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove dead letter store: %v, error: %v", m.FilePath, err)
+		}
+		return nil
+	}
+	tmpPath := m.FilePath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("failed to create dead letter store: %v, error: %v", tmpPath, err)
+	}
+	err = writeDeadLetters(file, deadLetters)
+	closeErr := file.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write dead letter store: %v, error: %v", tmpPath, err)
+	}
+	err = os.Rename(tmpPath, m.FilePath)
+	if err != nil {
+		return fmt.Errorf("failed to replace dead letter store: %v, error: %v", m.FilePath, err)
+	}
+	return nil
+}
+
+// Writes the dead letters to the file one per line, the file is synced so that it can only be
+// renamed over the store once all the dead letters are on disk
+func writeDeadLetters(file *os.File, deadLetters []*DeadLetter) error {
+	writer := bufio.NewWriter(file)
+	for _, deadLetter := range deadLetters {
+		line, err := json.Marshal(deadLetter)
+		if err != nil {
+			return fmt.Errorf("failed to encode dead letter: %v, error: %v", deadLetter, err)
+		}
+		_, err = writer.Write(line)
+		if err != nil {
+			return err
+		}
+		err = writer.WriteByte('\n')
+		if err != nil {
+			return err
+		}
+	}
+	err := writer.Flush()
+	if err != nil {
+		return err
+	}
+	return file.Sync()
+}
@@ -0,0 +1,60 @@
+package deadletter_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/deadletter"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/stream"
+	"gotest.tools/assert"
+)
+
+func TestStore(t *testing.T) {
+	store := deadletter.NewStore(filepath.Join(t.TempDir(), "dead-letters.jsonl"))
+	deadLetters, err := store.Load()
+	assert.NilError(t, err)
+	assert.Equal(t, len(deadLetters), 0)
+
+	err = store.Record(deadletter.NewDeadLetter(getDeltaRecord(10, "cursor10"), fmt.Errorf("can't make scalar field an array"), 1))
+	assert.NilError(t, err)
+	err = store.Record(deadletter.NewDeadLetter(getDeltaRecord(11, "cursor11"), fmt.Errorf("failed to store doc"), 4))
+	assert.NilError(t, err)
+
+	deadLetters, err = store.Load()
+	assert.NilError(t, err)
+	assert.Equal(t, len(deadLetters), 2)
+	assert.Equal(t, deadLetters[0].Delta.BlockNum, uint64(10))
+	assert.Equal(t, deadLetters[0].Delta.Cursor, "cursor10")
+	assert.Equal(t, string(deadLetters[0].Delta.NewData), `{"id":10}`)
+	assert.Equal(t, deadLetters[0].Error, "can't make scalar field an array")
+	assert.Equal(t, deadLetters[0].Attempts, uint(1))
+	assert.Equal(t, deadLetters[1].Delta.BlockNum, uint64(11))
+	assert.Equal(t, deadLetters[1].Attempts, uint(4))
+
+	err = store.Replace(deadLetters[1:])
+	assert.NilError(t, err)
+	deadLetters, err = store.Load()
+	assert.NilError(t, err)
+	assert.Equal(t, len(deadLetters), 1)
+	assert.Equal(t, deadLetters[0].Delta.BlockNum, uint64(11))
+
+	err = store.Replace(nil)
+	assert.NilError(t, err)
+	deadLetters, err = store.Load()
+	assert.NilError(t, err)
+	assert.Equal(t, len(deadLetters), 0)
+}
+
+func getDeltaRecord(blockNum uint64, cursor string) *stream.DeltaRecord {
+	return &stream.DeltaRecord{
+		Operation: "OPERATION_INSERT",
+		Code:      "dao.hypha",
+		Scope:     "dao.hypha",
+		TableName: "documents",
+		NewData:   []byte(fmt.Sprintf(`{"id":%v}`, blockNum)),
+		BlockNum:  blockNum,
+		Cursor:    cursor,
+		ForkStep:  "STEP_NEW",
+	}
+}
@@ -0,0 +1,253 @@
+package doccache
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
+)
+
+// Generates the mutations that remove the references other documents have to the document through
+// edges and core edges, and the nodes owned by it: pending edges, pending core edges, certificates,
+// edge nodes and versions, along with the mutations that revert them, returns the number of pending
+// edges removed. Once the changes are committed it is verified that no node still references the
+// deleted node
+func (m *Doccache) cascadeDeleteMutations(parsedDoc *domain.ParsedDoc, referenceFields []*gql.ReferenceField) ([]*gql.Mutation, []*gql.Mutation, int, error) {
+	docId := parsedDoc.GetValue(DocumentIdName)
+	mutations, undoMutations, err := m.removeReferencesMutations(docId, parsedDoc.GetHash(), referenceFields)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	pendingMutations, pendingUndoMutations, removedPendingEdges, err := m.removePendingEdgesMutations(docId)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	mutations = append(mutations, pendingMutations...)
+	undoMutations = append(undoMutations, pendingUndoMutations...)
+
+	ids := make([]interface{}, 0, parsedDoc.NumCoreEdges())
+	for _, checksumField := range parsedDoc.ChecksumFields {
+		ids = append(ids, newPendingCoreEdge(docId, checksumField, "").GetValue("id"))
+	}
+	ownedMutations, ownedUndoMutations, err := m.deleteOwnedMutations(ids, gql.PendingCoreEdgeSimplifiedType)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed removing pending core edges, error: %v", err)
+	}
+	mutations = append(mutations, ownedMutations...)
+	undoMutations = append(undoMutations, ownedUndoMutations...)
+
+	ids = make([]interface{}, 0)
+	if certificates, ok := parsedDoc.GetValue("certificates").([]map[string]interface{}); ok {
+		for _, certificate := range certificates {
+			ids = append(ids, certificate["id"])
+		}
+	}
+	ownedMutations, ownedUndoMutations, err = m.deleteOwnedMutations(ids, gql.CertificateSimplifiedType)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed removing certificates, error: %v", err)
+	}
+	mutations = append(mutations, ownedMutations...)
+	undoMutations = append(undoMutations, ownedUndoMutations...)
+
+	if m.config.EdgeMetadata {
+		ownedMutations, ownedUndoMutations, err = m.deleteEdgeNodesMutations(docId)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("failed removing edge nodes, error: %v", err)
+		}
+		mutations = append(mutations, ownedMutations...)
+		undoMutations = append(undoMutations, ownedUndoMutations...)
+	}
+
+	if len(m.config.HistoryTypes) > 0 {
+		ownedMutations, ownedUndoMutations, err = m.deleteDocumentVersionsMutations(docId)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("failed removing document versions, error: %v", err)
+		}
+		mutations = append(mutations, ownedMutations...)
+		undoMutations = append(undoMutations, ownedUndoMutations...)
+	}
+	return mutations, undoMutations, removedPendingEdges, nil
+}
+
+// Generates the mutations that remove the references other documents have to the document
+// through the reference fields, the core edges that referenced the document are stored as
+// pending core edges, so that they are linked again if a document with the hash is stored
+func (m *Doccache) removeReferencesMutations(docId interface{}, hash string, referenceFields []*gql.ReferenceField) ([]*gql.Mutation, []*gql.Mutation, error) {
+	mutations := make([]*gql.Mutation, 0)
+	undoMutations := make([]*gql.Mutation, 0)
+	references, err := m.client.GetReferences(DocumentIdName, docId, referenceFields)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting references, error: %v", err)
+	}
+	for _, referenceField := range referenceFields {
+		referenceType, err := m.Schema.GetSimplifiedType(referenceField.TypeName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed getting type: %v, error: %v", referenceField.TypeName, err)
+		}
+		var ref map[string]interface{}
+		if referenceField.Field.IsArray {
+			ref = map[string]interface{}{referenceField.Field.Name: []map[string]interface{}{GetEdgeValue(docId)}}
+		} else {
+			ref = map[string]interface{}{referenceField.Field.Name: GetEdgeValue(docId)}
+		}
+		for _, referencingId := range references[referenceField] {
+			//References of the document itself are removed along with it
+			if referencingId == docId {
+				continue
+			}
+			log.Infof("Removing reference: %v of document: %v to deleted document: %v", referenceField, referencingId, docId)
+			mutation, err := referenceType.UpdateMutation(DocumentIdName, referencingId, nil, ref)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed creating remove reference mutation, error: %v", err)
+			}
+			undoMutation, err := referenceType.UpdateMutation(DocumentIdName, referencingId, ref, nil)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed creating remove reference undo mutation, error: %v", err)
+			}
+			mutations = append(mutations, mutation)
+			undoMutations = append(undoMutations, undoMutation)
+			coreEdgeSuffix := fmt.Sprintf("_%v", domain.CoreEdgeSuffix)
+			if hash != "" && !referenceField.Field.IsArray && strings.HasSuffix(referenceField.Field.Name, coreEdgeSuffix) {
+				pending := newPendingCoreEdge(referencingId, strings.TrimSuffix(referenceField.Field.Name, coreEdgeSuffix), hash)
+				undoMutation, err := pending.DeleteMutation("id")
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed generating pending core edge undo mutation, error: %v", err)
+				}
+				mutations = append(mutations, pending.AddMutation(true))
+				undoMutations = append(undoMutations, undoMutation)
+			}
+		}
+	}
+	return mutations, undoMutations, nil
+}
+
+// Generates the mutations that remove the pending edges from or to the document
+func (m *Doccache) removePendingEdgesMutations(docId interface{}) ([]*gql.Mutation, []*gql.Mutation, int, error) {
+	mutations := make([]*gql.Mutation, 0)
+	undoMutations := make([]*gql.Mutation, 0)
+	if m.pendingEdges == 0 {
+		return mutations, undoMutations, 0, nil
+	}
+	removed := make(map[interface{}]bool)
+	for _, field := range []string{"from", "to"} {
+		pendings, err := m.client.SearchBaseInstances(field, []interface{}{docId}, gql.PendingEdgeSimplifiedType.SimplifiedBaseType, nil)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("failed getting pending edges %v document: %v, error: %v", field, docId, err)
+		}
+		for _, pending := range pendings {
+			id := pending.GetValue("id")
+			if removed[id] {
+				continue
+			}
+			log.Infof("Removing pending edge: %v of deleted document: %v", id, docId)
+			pendingInstance := gql.NewSimplifiedInstance(gql.PendingEdgeSimplifiedType, nonNilValues(pending.Values))
+			mutation, err := pendingInstance.DeleteMutation("id")
+			if err != nil {
+				return nil, nil, 0, fmt.Errorf("failed generating pending edge delete mutation, error: %v", err)
+			}
+			mutations = append(mutations, mutation)
+			undoMutations = append(undoMutations, pendingInstance.AddMutation(true))
+			removed[id] = true
+		}
+	}
+	return mutations, undoMutations, len(removed), nil
+}
+
+// Generates the mutations that delete the nodes owned by the document
+func (m *Doccache) deleteOwnedMutations(ids []interface{}, simplifiedType *gql.SimplifiedType) ([]*gql.Mutation, []*gql.Mutation, error) {
+	mutations := make([]*gql.Mutation, 0)
+	undoMutations := make([]*gql.Mutation, 0)
+	if len(ids) == 0 {
+		return mutations, undoMutations, nil
+	}
+	owned, err := m.client.GetBaseInstances("id", ids, simplifiedType.SimplifiedBaseType, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting: %v instances, error: %v", simplifiedType.Name, err)
+	}
+	for _, id := range ids {
+		instance, ok := owned[id]
+		if !ok {
+			continue
+		}
+		ownedInstance := gql.NewSimplifiedInstance(simplifiedType, nonNilValues(instance.Values))
+		mutation, err := ownedInstance.DeleteMutation("id")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed generating: %v delete mutation, error: %v", simplifiedType.Name, err)
+		}
+		mutations = append(mutations, mutation)
+		undoMutations = append(undoMutations, ownedInstance.AddMutation(true))
+	}
+	return mutations, undoMutations, nil
+}
+
+// Returns the uid of the document node, an empty string if it does not exist
+func (m *Doccache) getDocumentUid(typeName string, docId interface{}) (string, error) {
+	query := fmt.Sprintf(
+		`
+			query q($docId: string){
+				q(func: eq(%v, $docId)){
+					uid
+				}
+			}
+		`,
+		m.Schema.GetPredicateName(typeName, DocumentIdName),
+	)
+	var response map[string][]map[string]interface{}
+	err := m.dgraph.Query(query, map[string]string{"$docId": fmt.Sprintf("%v", docId)}, &response)
+	if err != nil {
+		return "", fmt.Errorf("failed getting uid of document: %v, error: %v", docId, err)
+	}
+	if len(response["q"]) == 0 {
+		return "", nil
+	}
+	return response["q"][0]["uid"].(string), nil
+}
+
+// Returns the documents that still reference the deleted node through any of the reference fields
+func (m *Doccache) getDanglingReferences(typeName, uid string, referenceFields []*gql.ReferenceField) ([]string, error) {
+	predicates := make(map[string]bool)
+	for _, referenceField := range referenceFields {
+		predicates[m.Schema.GetPredicateName(referenceField.TypeName, referenceField.Field.Name)] = true
+	}
+	if len(predicates) == 0 {
+		return nil, nil
+	}
+	sorted := make([]string, 0, len(predicates))
+	for predicate := range predicates {
+		sorted = append(sorted, predicate)
+	}
+	sort.Strings(sorted)
+	q := &strings.Builder{}
+	for i, predicate := range sorted {
+		q.WriteString(fmt.Sprintf(
+			"r%v(func: has(%v)) @filter(uid_in(%v, %v)){ uid docId: %v }\n",
+			i,
+			predicate,
+			predicate,
+			uid,
+			m.Schema.GetPredicateName(typeName, DocumentIdName),
+		))
+	}
+	var response map[string][]map[string]interface{}
+	err := m.dgraph.Query(fmt.Sprintf("{\n%v}", q.String()), nil, &response)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting references to node: %v, error: %v", uid, err)
+	}
+	dangling := make([]string, 0)
+	for i, predicate := range sorted {
+		for _, node := range response[fmt.Sprintf("r%v", i)] {
+			//Soft deleted documents keep their references to themselves
+			if node["uid"] == uid {
+				continue
+			}
+			id := node["docId"]
+			if id == nil {
+				id = node["uid"]
+			}
+			dangling = append(dangling, fmt.Sprintf("%v (%v)", id, predicate))
+		}
+	}
+	return dangling, nil
+}
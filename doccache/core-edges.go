@@ -0,0 +1,273 @@
+package doccache
+
+import (
+	"fmt"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
+)
+
+// Resolves the checksum fields of the document to the documents with those hashes, setting the core
+// edges on the document instance and type, returns the checksum fields whose target documents do not
+// exist yet, for which pending core edges are stored so that the links can be made once the target
+// documents are stored
+func (m *Doccache) resolveCoreEdges(parsedDoc *domain.ParsedDoc, currentType *gql.SimplifiedType) ([]string, error) {
+	unresolved := make([]string, 0)
+	if !parsedDoc.HasCoreEdges() {
+		return unresolved, nil
+	}
+	hashes := make([]interface{}, 0, parsedDoc.NumCoreEdges())
+	for _, checksumField := range parsedDoc.ChecksumFields {
+		hashes = append(hashes, parsedDoc.GetChecksum(checksumField))
+	}
+	targets, err := m.getDocumentsByHash(hashes)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting core edge targets, error: %v", err)
+	}
+	instance := parsedDoc.Instance
+	newType := instance.SimplifiedType
+	for _, checksumField := range parsedDoc.ChecksumFields {
+		target, ok := targets[parsedDoc.GetChecksum(checksumField)]
+		if !ok {
+			unresolved = append(unresolved, checksumField)
+			continue
+		}
+		edgeName := domain.GetCoreEdgeName(checksumField)
+		//Fields defined by interfaces have already been applied to the new type
+		fieldType := newType
+		if !newType.HasField(edgeName) && currentType != nil {
+			fieldType = currentType
+		}
+		edgeType, err := m.coreEdgeType(fieldType, edgeName, target.GetValue("type").(string))
+		if err != nil {
+			return nil, err
+		}
+		if edgeType == "" {
+			log.Warnf("Core edge: %v of type: %v can not reference document: %v of type: %v, the type is defined by an interface", edgeName, newType.Name, target.GetValue(DocumentIdName), target.GetValue("type"))
+			continue
+		}
+		newType.SetField(edgeName, gql.NewCoreEdgeField(edgeName, edgeType))
+		instance.SetValue(edgeName, GetEdgeValue(target.GetValue(DocumentIdName)))
+	}
+	return unresolved, nil
+}
+
+// Generates the mutations that store the pending core edges for the unresolved checksum fields, and
+// the ones that link the pending core edges that point to the document, along with the mutations
+// that revert them
+func (m *Doccache) coreEdgeMutations(parsedDoc *domain.ParsedDoc, unresolved []string) ([]*gql.Mutation, []*gql.Mutation, error) {
+	mutations := make([]*gql.Mutation, 0)
+	undoMutations := make([]*gql.Mutation, 0)
+	docId := parsedDoc.GetValue(DocumentIdName)
+	for _, checksumField := range unresolved {
+		log.Infof("Target document of core edge: %v of document: %v not found, storing pending core edge", checksumField, docId)
+		pending := newPendingCoreEdge(docId, checksumField, parsedDoc.GetChecksum(checksumField))
+		undoMutation, err := pending.DeleteMutation("id")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed generating pending core edge undo mutation, error: %v", err)
+		}
+		mutations = append(mutations, pending.AddMutation(true))
+		undoMutations = append(undoMutations, undoMutation)
+	}
+	hash := parsedDoc.GetHash()
+	if hash == "" {
+		return mutations, undoMutations, nil
+	}
+	linkMutations, linkUndoMutations, err := m.linkPendingCoreEdges(hash, docId, parsedDoc.Instance.SimplifiedType.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(mutations, linkMutations...), append(undoMutations, linkUndoMutations...), nil
+}
+
+// Generates the mutations that link the pending core edges pointing to the hash to the target
+// document and remove the pending core edges
+func (m *Doccache) linkPendingCoreEdges(hash string, targetDocId interface{}, targetTypeName string) ([]*gql.Mutation, []*gql.Mutation, error) {
+	mutations := make([]*gql.Mutation, 0)
+	undoMutations := make([]*gql.Mutation, 0)
+	if m.batch != nil && m.batch.HasPendingCoreEdges(hash) {
+		err := m.commitBatch()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed committing batch, error: %v", err)
+		}
+	}
+	pendings, err := m.client.SearchBaseInstances("hash", []interface{}{hash}, gql.PendingCoreEdgeSimplifiedType.SimplifiedBaseType, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting pending core edges for hash: %v, error: %v", hash, err)
+	}
+	for _, pending := range pendings {
+		docId := pending.GetValue("docId")
+		checksumField := pending.GetValue("field").(string)
+		mutation, undoMutation, err := m.linkPendingCoreEdge(docId, checksumField, hash, targetDocId, targetTypeName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed linking pending core edge: %v of document: %v, error: %v", checksumField, docId, err)
+		}
+		if mutation != nil {
+			mutations = append(mutations, mutation)
+			undoMutations = append(undoMutations, undoMutation)
+		}
+		pendingInstance := gql.NewSimplifiedInstance(gql.PendingCoreEdgeSimplifiedType, pending.Values)
+		mutation, err = pendingInstance.DeleteMutation("id")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed generating pending core edge delete mutation, error: %v", err)
+		}
+		mutations = append(mutations, mutation)
+		undoMutations = append(undoMutations, pendingInstance.AddMutation(true))
+	}
+	return mutations, undoMutations, nil
+}
+
+// Generates the mutation that links the core edge of the document to the target document and the one that
+// reverts it, returns nil if the document no longer exists or its checksum field no longer points to the hash
+func (m *Doccache) linkPendingCoreEdge(docId interface{}, checksumField, hash string, targetDocId interface{}, targetTypeName string) (*gql.Mutation, *gql.Mutation, error) {
+	err := m.commitBatchIfHasDocs(docId)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed committing batch, error: %v", err)
+	}
+	docs, err := m.GetDocumentBaseInstances([]interface{}{docId}, gql.DocumentSimplifiedInterface.SimplifiedBaseType, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting document, error: %v", err)
+	}
+	doc, ok := docs[docId]
+	if !ok {
+		log.Infof("Document: %v of pending core edge: %v no longer exists, discarding it", docId, checksumField)
+		return nil, nil, nil
+	}
+	simplifiedType, err := m.Schema.GetSimplifiedType(doc.GetValue("type").(string))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting type: %v, error: %v", doc.GetValue("type"), err)
+	}
+	current, err := m.GetDocumentInstance(docId, simplifiedType, []string{checksumField})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting checksum field, error: %v", err)
+	}
+	if current == nil || current.GetValue(checksumField) != hash {
+		log.Infof("Checksum field: %v of document: %v no longer points to: %v, discarding pending core edge", checksumField, docId, hash)
+		return nil, nil, nil
+	}
+	edgeName := domain.GetCoreEdgeName(checksumField)
+	edgeType, err := m.coreEdgeType(simplifiedType, edgeName, targetTypeName)
+	if err != nil {
+		return nil, nil, err
+	}
+	if edgeType == "" {
+		log.Warnf("Core edge: %v of type: %v can not reference document: %v of type: %v, the type is defined by an interface", edgeName, simplifiedType.Name, targetDocId, targetTypeName)
+		return nil, nil, nil
+	}
+	err = m.updateSchemaCoreEdge(simplifiedType.Name, edgeName, edgeType)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Infof("Linking pending core edge: %v of document: %v to document: %v", edgeName, docId, targetDocId)
+	edgeRef := map[string]interface{}{edgeName: GetEdgeValue(targetDocId)}
+	mutation, err := simplifiedType.UpdateMutation(DocumentIdName, docId, edgeRef, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed creating link mutation, error: %v", err)
+	}
+	undoMutation, err := simplifiedType.UpdateMutation(DocumentIdName, docId, nil, edgeRef)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed creating link undo mutation, error: %v", err)
+	}
+	return mutation, undoMutation, nil
+}
+
+// Determines the type the core edge field should have to be able to reference a document of the
+// target type, returns an empty type if the field is defined by an interface with a type that is
+// not compatible with the target type
+func (m *Doccache) coreEdgeType(simplifiedType *gql.SimplifiedType, edgeName, targetTypeName string) (string, error) {
+	field := simplifiedType.GetField(edgeName)
+	if field == nil {
+		return targetTypeName, nil
+	}
+	if field.Type == targetTypeName || field.Type == gql.DocumentSimplifiedInterface.Name {
+		return field.Type, nil
+	}
+	targetType, err := m.Schema.GetSimplifiedType(targetTypeName)
+	if err != nil {
+		return "", fmt.Errorf("failed getting type: %v, error: %v", targetTypeName, err)
+	}
+	if targetType != nil && targetType.HasInterface(field.Type) {
+		return field.Type, nil
+	}
+	for _, interfaceName := range simplifiedType.Interfaces {
+		if interf, ok := m.config.Interfaces[interfaceName]; ok && interf.HasField(edgeName) {
+			return "", nil
+		}
+	}
+	return gql.DocumentSimplifiedInterface.Name, nil
+}
+
+// Returns the documents with the specified hashes by hash, the documents modified by the buffered
+// mutations are taken from the batch
+func (m *Doccache) getDocumentsByHash(hashes []interface{}) (map[interface{}]*gql.SimplifiedBaseInstance, error) {
+	documents := make(map[interface{}]*gql.SimplifiedBaseInstance)
+	toSearch := make([]interface{}, 0, len(hashes))
+	for _, hash := range hashes {
+		if m.batch != nil {
+			if docId, ok := m.batch.GetDocIdByHash(hash.(string)); ok {
+				if typeName, _ := m.batch.GetDocType(docId); typeName != "" {
+					documents[hash] = gql.NewSimplifiedBaseInstance(
+						gql.DocumentSimplifiedInterface.SimplifiedBaseType,
+						map[string]interface{}{
+							"docId": docId,
+							"type":  typeName,
+						},
+					)
+					continue
+				}
+			}
+		}
+		toSearch = append(toSearch, hash)
+	}
+	if len(toSearch) == 0 {
+		return documents, nil
+	}
+	stored, err := m.client.SearchBaseInstances(
+		"hash",
+		toSearch,
+		gql.DocumentSimplifiedInterface.SimplifiedBaseType,
+		[]string{"docId", "type"},
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, document := range stored {
+		if m.batch != nil {
+			//Deleted by the buffered mutations
+			if typeName, ok := m.batch.GetDocType(document.GetValue(DocumentIdName)); ok && typeName == "" {
+				continue
+			}
+		}
+		documents[document.GetValue("hash")] = document
+	}
+	return documents, nil
+}
+
+// Updates the schema for a core edge
+func (m *Doccache) updateSchemaCoreEdge(typeName, edgeName, edgeType string) error {
+	added, err := m.Schema.UpdateField(typeName, gql.NewCoreEdgeField(edgeName, edgeType))
+	if err != nil {
+		return fmt.Errorf("failed updating local schema, error: %v", err)
+	}
+	if added {
+		err := m.admin.UpdateSchema(m.Schema)
+		if err != nil {
+			return fmt.Errorf("failed updating remote schema, error: %v", err)
+		}
+	}
+	return nil
+}
+
+// Creates the instance that records that the checksum field of the document points to a document
+// that does not exist yet
+func newPendingCoreEdge(docId interface{}, checksumField, hash string) *gql.SimplifiedInstance {
+	return gql.NewSimplifiedInstance(
+		gql.PendingCoreEdgeSimplifiedType,
+		map[string]interface{}{
+			"id":    fmt.Sprintf("%v-%v", docId, checksumField),
+			"hash":  hash,
+			"docId": docId,
+			"field": checksumField,
+		},
+	)
+}
@@ -19,12 +19,16 @@ var log *slog.Log
 
 //Doccache Service class to store and retrieve docs from dgraph
 type Doccache struct {
-	dgraph *dgraph.Dgraph
-	admin  *gql.Admin
-	client *gql.Client
-	config *config.Config
-	Cursor *gql.SimplifiedInstance
-	Schema *gql.Schema
+	dgraph  *dgraph.Dgraph
+	admin   *gql.Admin
+	client  *gql.Client
+	config  *config.Config
+	journal *UndoJournal
+	batch   *MutationBatch
+	Cursor  *gql.SimplifiedInstance
+	Schema  *gql.Schema
+	// Number of stored pending edges
+	pendingEdges int64
 }
 
 //New creates a new doccache instance
@@ -32,10 +36,11 @@ func New(dg *dgraph.Dgraph, admin *gql.Admin, client *gql.Client, config *config
 	log = slog.New(logConfig, "doccache")
 
 	m := &Doccache{
-		dgraph: dg,
-		admin:  admin,
-		client: client,
-		config: config,
+		dgraph:  dg,
+		admin:   admin,
+		client:  client,
+		config:  config,
+		journal: NewUndoJournal(int(config.UndoJournalBlocks)),
 	}
 
 	err := m.PrepareSchema()
@@ -51,6 +56,10 @@ func New(dg *dgraph.Dgraph, admin *gql.Admin, client *gql.Client, config *config
 		return nil, err
 	}
 	m.Cursor = cursor
+	err = m.refreshPendingEdgeCount()
+	if err != nil {
+		return nil, err
+	}
 	return m, nil
 }
 
@@ -74,6 +83,11 @@ func (m *Doccache) PrepareSchema() error {
 		}
 		log.Infof("Created initial schema.")
 	}
+	schema.AddOwnedType(gql.CertificateSimplifiedType.SimplifiedBaseType)
+	err = m.upgradeBaseSchema(schema)
+	if err != nil {
+		return fmt.Errorf("failed upgrading base schema error: %v", err)
+	}
 	err = m.initializeInterfacesSchema(schema)
 	if err != nil {
 		return fmt.Errorf("failed initializing interfaces schema error: %v", err)
@@ -82,6 +96,30 @@ func (m *Doccache) PrepareSchema() error {
 	return nil
 }
 
+// Adds the base schema elements introduced after the schema was created
+func (m *Doccache) upgradeBaseSchema(schema *gql.Schema) error {
+	updated := false
+	for _, simplifiedType := range []*gql.SimplifiedType{gql.CertificateSimplifiedType, gql.PendingCoreEdgeSimplifiedType, gql.PendingEdgeSimplifiedType, gql.EdgeSimplifiedType, gql.DocumentVersionSimplifiedType} {
+		updateOp, err := schema.UpdateType(simplifiedType)
+		if err != nil {
+			return fmt.Errorf("failed adding type: %v, error: %v", simplifiedType.Name, err)
+		}
+		updated = updated || updateOp != gql.SchemaUpdateOp_None
+	}
+	addedFields, err := schema.AddInterfaceFields(gql.DocumentSimplifiedInterface)
+	if err != nil {
+		return fmt.Errorf("failed adding document interface fields, error: %v", err)
+	}
+	if updated || addedFields {
+		log.Infof("Upgrading base schema...")
+		err = m.admin.UpdateSchema(schema)
+		if err != nil {
+			return fmt.Errorf("failed updating schema, error: %v", err)
+		}
+	}
+	return nil
+}
+
 // Sets up the gql schema for the interfaces specified in the initial configuration
 func (m *Doccache) initializeInterfacesSchema(schema *gql.Schema) error {
 	log.Infof("Initializing interfaces schema...")
@@ -150,16 +188,128 @@ func (m *Doccache) updateDoccacheConfig() error {
 	return nil
 }
 
-// Executes a graphql mutation
+// Executes a graphql mutation, if batching the mutation is buffered until the batch is committed
 func (m *Doccache) mutate(mutation *gql.Mutation, cursor string) error {
+	return m.mutateAll([]*gql.Mutation{mutation}, cursor)
+}
+
+// Executes the graphql mutations along with the cursor update in a single request, if batching
+// the mutations are buffered until the batch is committed
+func (m *Doccache) mutateAll(mutations []*gql.Mutation, cursor string) error {
 	m.Cursor.SetValue("cursor", cursor)
+	if m.batch != nil {
+		m.batch.Add(cursor, mutations...)
+		return nil
+	}
 	cursorMutation := m.Cursor.AddMutation(true)
-	return m.client.Mutate(mutation, cursorMutation)
+	return m.client.Mutate(append(mutations, cursorMutation)...)
+}
+
+// Starts buffering the mutations until Flush is called, enabling the changes of a whole block
+// to be committed using a bounded number of requests
+func (m *Doccache) StartBatch() {
+	if m.batch == nil {
+		m.batch = NewMutationBatch(int(m.config.MaxBatchSize))
+	}
+}
+
+// Indicates whether mutations are being buffered
+func (m *Doccache) IsBatching() bool {
+	return m.batch != nil
+}
+
+// Commits the buffered mutations and stops buffering
+func (m *Doccache) Flush() error {
+	err := m.commitBatch()
+	if err != nil {
+		return err
+	}
+	m.batch = nil
+	if m.pendingEdges > 0 {
+		return m.refreshPendingEdgeCount()
+	}
+	return nil
+}
+
+// Discards the buffered mutations that could not be committed along with the undo information
+// of the block, as it no longer reflects what was stored, and stops buffering
+func (m *Doccache) DiscardBatch(blockNum uint64) {
+	log.Warnf("Discarding batch: %v of block: %v", m.batch, blockNum)
+	m.batch = nil
+	m.journal.Pop(blockNum)
+}
+
+// Commits the buffered mutations, buffering continues with an empty batch. If a request fails
+// the mutations that have not been applied are kept in the batch, so that they are not lost if
+// the operation is retried or skipped
+func (m *Doccache) commitBatch() error {
+	if m.batch == nil || m.batch.IsEmpty() {
+		return nil
+	}
+	requests := m.batch.Requests()
+	log.Infof("Committing batch: %v using %v requests", m.batch, len(requests))
+	for i, request := range requests {
+		err := m.client.Mutate(request...)
+		if err != nil {
+			return fmt.Errorf("failed committing batch: %v, request %v of %v failed, error: %v", m.batch, i+1, len(requests), err)
+		}
+		m.batch.Committed(request)
+	}
+	m.batch = NewMutationBatch(m.batch.maxMutations)
+	return nil
+}
+
+// Commits the buffered mutations if any of them modifies the documents, required before reading
+// the documents from the db
+func (m *Doccache) commitBatchIfHasDocs(docIds ...interface{}) error {
+	if m.batch != nil && m.batch.HasDocs(docIds...) {
+		return m.commitBatch()
+	}
+	return nil
+}
+
+// Sets the block to which subsequent changes belong, the changes are recorded in the
+// undo journal so that they can be reverted if the block is undone, a blockNum of 0
+// disables the recording of changes
+func (m *Doccache) SetBlock(blockNum uint64) {
+	m.journal.SetBlock(blockNum)
+}
+
+// Restores the state previous to the processing of the specified block using the
+// undo journal, returns false if there is no undo information for the block
+func (m *Doccache) UndoBlock(blockNum uint64, cursor string) (bool, error) {
+	journal := m.journal.Pop(blockNum)
+	if journal == nil {
+		return false, nil
+	}
+	err := m.commitBatch()
+	if err != nil {
+		return false, fmt.Errorf("failed undoing block: %v, error: %v", blockNum, err)
+	}
+	log.Infof("Undoing block: %v, reverting %v changes", blockNum, len(journal.Mutations))
+	err = m.mutateAll(journal.UndoMutations(), cursor)
+	if err != nil {
+		return false, fmt.Errorf("failed undoing block: %v, error: %v", blockNum, err)
+	}
+	//The documents modified by the undo mutations are not tracked, so they are committed right away
+	err = m.commitBatch()
+	if err != nil {
+		return false, fmt.Errorf("failed undoing block: %v, error: %v", blockNum, err)
+	}
+	err = m.refreshPendingEdgeCount()
+	if err != nil {
+		return false, fmt.Errorf("failed undoing block: %v, error: %v", blockNum, err)
+	}
+	return true, nil
 }
 
 // Updates the cursor stored on the db
 func (m *Doccache) UpdateCursor(cursor string) error {
 	m.Cursor.Values["cursor"] = cursor
+	if m.batch != nil {
+		m.batch.Add(cursor)
+		return nil
+	}
 	err := m.client.Mutate(m.Cursor.AddMutation(true))
 	if err != nil {
 		return fmt.Errorf("failed to update cursor, value: %v, error: %v", cursor, err)
@@ -225,6 +375,9 @@ func (m *Doccache) StoreDocument(chainDoc *domain.ChainDocument, cursor string)
 	if err != nil {
 		return fmt.Errorf("failed to store document with docId: %v, error building instance from chain doc: %v", chainDoc.ID, err)
 	}
+	for name, err := range parsedDoc.SkippedCompanions {
+		log.Warnf("Skipping companion fields of asset content: %v of document: %v, failed to parse asset, error: %v", name, chainDoc.ID, err)
+	}
 	instance := parsedDoc.Instance
 	newSimplifiedType := instance.SimplifiedType
 	currentSimplifiedType, err := m.Schema.GetSimplifiedType(newSimplifiedType.Name)
@@ -241,84 +394,496 @@ func (m *Doccache) StoreDocument(chainDoc *domain.ChainDocument, cursor string)
 		return fmt.Errorf("failed to store document with docId: %v of type: %v, unable to apply interfaces, error: %v", chainDoc.ID, instance.GetValue("type"), err)
 	}
 
+	unresolved, err := m.resolveCoreEdges(parsedDoc, currentSimplifiedType)
+	if err != nil {
+		return fmt.Errorf("failed to store document with docId: %v of type: %v, error resolving core edges: %v", chainDoc.ID, instance.GetValue("type"), err)
+	}
+
 	updateOp, err := m.updateSchemaType(newSimplifiedType)
 	if err != nil {
 		return fmt.Errorf("failed to store document with docId: %v of type: %v, error updating schema: %v", chainDoc.ID, instance.GetValue("type"), err)
 	}
 	var oldInstance *gql.SimplifiedInstance
 	if updateOp != gql.SchemaUpdateOp_Created {
+		err = m.commitBatchIfHasDocs(instance.GetValue(DocumentIdName))
+		if err != nil {
+			return fmt.Errorf("failed to store document with docId: %v of type: %v, error committing batch: %v", chainDoc.ID, instance.GetValue("type"), err)
+		}
 		oldInstance, err = m.GetDocumentInstance(instance.GetValue(DocumentIdName), currentSimplifiedType, currentSimplifiedType.GetCoreFields())
 		if err != nil {
 			return fmt.Errorf("failed to store document with docId: %v of type: %v, error fetching old instance: %v", chainDoc.ID, instance.GetValue("type"), err)
 		}
 	}
+	var change *typeChange
+	if oldInstance == nil {
+		//The document could be stored with another type
+		err = m.commitBatchIfHasDocs(instance.GetValue(DocumentIdName))
+		if err != nil {
+			return fmt.Errorf("failed to store document with docId: %v of type: %v, error committing batch: %v", chainDoc.ID, instance.GetValue("type"), err)
+		}
+		storedType, err := m.getStoredType(instance.GetValue(DocumentIdName))
+		if err != nil {
+			return fmt.Errorf("failed to store document with docId: %v of type: %v, error getting stored type: %v", chainDoc.ID, instance.GetValue("type"), err)
+		}
+		if storedType != "" && storedType != newSimplifiedType.Name {
+			log.Warnf("Document: %v changed type from: %v to: %v, moving it to the new type", chainDoc.ID, storedType, newSimplifiedType.Name)
+			change, err = m.typeChangeMutations(instance, storedType)
+			if err != nil {
+				return fmt.Errorf("failed to store document with docId: %v of type: %v, error changing type from: %v, error: %v", chainDoc.ID, instance.GetValue("type"), storedType, err)
+			}
+		}
+	}
 
+	var mutation, undoMutation *gql.Mutation
 	if oldInstance == nil {
 		log.Infof("Creating document: %v of type: %v", chainDoc.ID, instance.GetValue("type"))
-		err = m.mutate(instance.AddMutation(false), cursor)
+		if change != nil {
+			keepCreatedBlock(instance, change.previous)
+		}
+		mutation = instance.AddMutation(false)
+		undoMutation, err = instance.DeleteMutation(DocumentIdName)
 		if err != nil {
-			return fmt.Errorf("failed to create document with docId: %v of type: %v, error inserting instance: %v", chainDoc.ID, instance.GetValue("type"), err)
+			return fmt.Errorf("failed to create document with docId: %v of type: %v, error generating undo mutation: %v", chainDoc.ID, instance.GetValue("type"), err)
 		}
 	} else {
 		log.Infof("Updating document: %v of type: %v", chainDoc.ID, instance.GetValue("type"))
-		mutation, err := instance.UpdateMutation(DocumentIdName, oldInstance)
+		keepCreatedBlock(instance, oldInstance)
+		mutation, err = instance.UpdateMutation(DocumentIdName, oldInstance)
 		fmt.Println("Update mutation: ", mutation)
 		if err != nil {
 			return fmt.Errorf("failed to update document with docId: %v of type: %v, error generating update mutation: %v", chainDoc.ID, instance.GetValue("type"), err)
 		}
-		err = m.mutate(mutation, cursor)
+		undoMutation, err = restoreMutation(oldInstance, instance)
+		if err != nil {
+			return fmt.Errorf("failed to update document with docId: %v of type: %v, error generating undo mutation: %v", chainDoc.ID, instance.GetValue("type"), err)
+		}
+	}
+	mutations := []*gql.Mutation{mutation}
+	undoMutations := []*gql.Mutation{undoMutation}
+	previous := oldInstance
+	if change != nil {
+		mutations = append(append(change.preMutations, mutation), change.postMutations...)
+		undoMutations = append(append(change.preUndoMutations, undoMutation), change.postUndoMutations...)
+		previous = change.previous
+	}
+
+	if previous != nil && m.keepsHistory(newSimplifiedType.Name) {
+		versionMutation, versionUndoMutation, err := m.documentVersionMutation(previous, chainDoc, instance.GetValue("updatedDate"))
 		if err != nil {
-			return fmt.Errorf("failed to update document with docId: %v of type: %v, error updating instance: %v", chainDoc.ID, instance.GetValue("type"), err)
+			return fmt.Errorf("failed to update document with docId: %v of type: %v, error generating version mutation: %v", chainDoc.ID, instance.GetValue("type"), err)
 		}
+		mutations = append(mutations, versionMutation)
+		undoMutations = append(undoMutations, versionUndoMutation)
 	}
 
+	coreEdgeMutations, coreEdgeUndoMutations, err := m.coreEdgeMutations(parsedDoc, unresolved)
+	if err != nil {
+		return fmt.Errorf("failed to store document with docId: %v of type: %v, error generating core edge mutations: %v", chainDoc.ID, instance.GetValue("type"), err)
+	}
+	mutations = append(mutations, coreEdgeMutations...)
+	undoMutations = append(undoMutations, coreEdgeUndoMutations...)
+
+	appliedPendingEdges := 0
+	if previous == nil {
+		var pendingEdgeMutations, pendingEdgeUndoMutations []*gql.Mutation
+		pendingEdgeMutations, pendingEdgeUndoMutations, appliedPendingEdges, err = m.applyPendingEdgesMutations(instance.GetValue(DocumentIdName), newSimplifiedType.Name)
+		if err != nil {
+			return fmt.Errorf("failed to store document with docId: %v of type: %v, error generating pending edge mutations: %v", chainDoc.ID, instance.GetValue("type"), err)
+		}
+		mutations = append(mutations, pendingEdgeMutations...)
+		undoMutations = append(undoMutations, pendingEdgeUndoMutations...)
+	}
+
+	err = m.mutateAll(mutations, cursor)
+	if err != nil {
+		return fmt.Errorf("failed to store document with docId: %v of type: %v, error storing instance: %v", chainDoc.ID, instance.GetValue("type"), err)
+	}
+	for _, undoMutation := range undoMutations {
+		m.journal.Record(undoMutation)
+	}
+	m.pendingEdges -= int64(appliedPendingEdges)
+	if m.batch != nil {
+		m.batch.StoredDoc(instance.GetValue(DocumentIdName), newSimplifiedType.Name)
+		if hash := parsedDoc.GetHash(); hash != "" {
+			m.batch.StoredHash(hash, instance.GetValue(DocumentIdName))
+		}
+		for _, checksumField := range unresolved {
+			m.batch.AddedPendingCoreEdge(parsedDoc.GetChecksum(checksumField))
+		}
+	}
+	//The documents that reference the moved document are not tracked, so the changes are committed right away
+	if change != nil {
+		err = m.commitBatch()
+		if err != nil {
+			return fmt.Errorf("failed to store document with docId: %v of type: %v, error committing batch: %v", chainDoc.ID, instance.GetValue("type"), err)
+		}
+	}
 	return nil
 }
 
+// Keeps the block in which the document was created, it is unknown for documents stored before
+// blocks were recorded
+func keepCreatedBlock(instance, previous *gql.SimplifiedInstance) {
+	if createdBlock := previous.GetValue("createdBlock"); createdBlock != nil {
+		instance.SetValue("createdBlock", createdBlock)
+	} else {
+		delete(instance.Values, "createdBlock")
+	}
+}
+
+// Generates the mutation that restores the document to the state it had before being
+// updated, including the values the update removed
+func restoreMutation(oldInstance, instance *gql.SimplifiedInstance) (*gql.Mutation, error) {
+	previous := gql.NewSimplifiedInstance(oldInstance.SimplifiedType, nonNilValues(oldInstance.Values))
+	return previous.UpdateMutation(DocumentIdName, instance)
+}
+
+// Returns the values that are set, the db returns nil for the fields without value
+func nonNilValues(values map[string]interface{}) map[string]interface{} {
+	nonNil := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		if value != nil {
+			nonNil[name] = value
+		}
+	}
+	return nonNil
+}
+
 func GetEdgeValue(docId interface{}) map[string]interface{} {
 	return map[string]interface{}{"docId": docId}
 }
 
-// Deletes the document represented by the chainDoc parameter
+// Deletes the document represented by the chainDoc parameter, along with the references other
+// documents have to it and the nodes it owns, documents of the soft delete types are marked as
+// deleted instead
 func (m *Doccache) DeleteDocument(chainDoc *domain.ChainDocument, cursor string) error {
 	parsedDoc, err := chainDoc.ToParsedDoc(m.config.TypeMappings)
 	if err != nil {
 		return fmt.Errorf("failed to delete document with docId: %v, error building instance from chain doc: %v", chainDoc.ID, err)
 	}
 	instance := parsedDoc.Instance
+	docId := instance.GetValue(DocumentIdName)
 	log.Infof("Deleting Node: %v of type: %v", chainDoc.ID, instance.GetValue("type"))
 	mutation, err := instance.DeleteMutation(DocumentIdName)
 	if err != nil {
 		return fmt.Errorf("failed to delete document with docId: %v of type: %v, error creating delete mutation: %v", chainDoc.ID, instance.GetValue("type"), err)
 	}
-	err = m.mutate(mutation, cursor)
+	//References to the document could have been added by any of the buffered mutations
+	err = m.commitBatch()
+	if err != nil {
+		return fmt.Errorf("failed to delete document with docId: %v of type: %v, error committing batch: %v", chainDoc.ID, instance.GetValue("type"), err)
+	}
+	currentType, err := m.Schema.GetSimplifiedType(instance.SimplifiedType.Name)
+	if err != nil {
+		return fmt.Errorf("failed to delete document with docId: %v of type: %v, error getting simplified type: %v", chainDoc.ID, instance.GetValue("type"), err)
+	}
+	mutations := make([]*gql.Mutation, 0)
+	undoMutations := make([]*gql.Mutation, 0)
+	removedPendingEdges := 0
+	var (
+		uid             string
+		referenceFields []*gql.ReferenceField
+	)
+	softDelete := currentType != nil && m.softDeletes(currentType.Name)
+	if currentType != nil {
+		uid, err = m.getDocumentUid(currentType.Name, docId)
+		if err != nil {
+			return fmt.Errorf("failed to delete document with docId: %v of type: %v, error: %v", chainDoc.ID, instance.GetValue("type"), err)
+		}
+	}
+	if uid != "" {
+		referenceFields, err = m.Schema.GetReferenceFields(currentType)
+		if err != nil {
+			return fmt.Errorf("failed to delete document with docId: %v of type: %v, error getting reference fields: %v", chainDoc.ID, instance.GetValue("type"), err)
+		}
+		if softDelete {
+			log.Infof("Marking document: %v of type: %v as deleted", chainDoc.ID, instance.GetValue("type"))
+			mutations, undoMutations, removedPendingEdges, err = m.softDeleteMutations(chainDoc, parsedDoc, currentType, referenceFields)
+			if err != nil {
+				return fmt.Errorf("failed to delete document with docId: %v of type: %v, error generating soft delete mutations: %v", chainDoc.ID, instance.GetValue("type"), err)
+			}
+		} else {
+			mutations, undoMutations, removedPendingEdges, err = m.cascadeDeleteMutations(parsedDoc, referenceFields)
+			if err != nil {
+				return fmt.Errorf("failed to delete document with docId: %v of type: %v, error generating cascade mutations: %v", chainDoc.ID, instance.GetValue("type"), err)
+			}
+			if m.journal.IsRecording() {
+				undoMutation, err := m.recreateMutation(instance)
+				if err != nil {
+					return fmt.Errorf("failed to delete document with docId: %v of type: %v, error generating undo mutation: %v", chainDoc.ID, instance.GetValue("type"), err)
+				}
+				if undoMutation != nil {
+					undoMutations = append(undoMutations, undoMutation)
+				}
+			}
+		}
+	}
+	if !softDelete {
+		mutations = append(mutations, mutation)
+	}
+	err = m.mutateAll(mutations, cursor)
 	if err != nil {
 		return fmt.Errorf("failed to delete document with docId: %v of type: %v, error deleting instance: %v", chainDoc.ID, instance.GetValue("type"), err)
 	}
+	for _, undoMutation := range undoMutations {
+		m.journal.Record(undoMutation)
+	}
+	m.pendingEdges -= int64(removedPendingEdges)
+	if m.batch != nil {
+		if softDelete {
+			m.batch.StoredDoc(docId, currentType.Name)
+		} else {
+			m.batch.DeletedDoc(docId)
+		}
+	}
+	if uid == "" {
+		return nil
+	}
+	//The changes have to be stored to verify there are no references left
+	err = m.commitBatch()
+	if err != nil {
+		return fmt.Errorf("failed to delete document with docId: %v of type: %v, error committing batch: %v", chainDoc.ID, instance.GetValue("type"), err)
+	}
+	//The nodes owned by soft deleted documents are kept
+	if !softDelete && m.config.EdgeMetadata {
+		referenceFields = append(referenceFields, edgeNodeReferenceFields()...)
+	}
+	if !softDelete && len(m.config.HistoryTypes) > 0 {
+		referenceFields = append(referenceFields, documentVersionReferenceFields()...)
+	}
+	dangling, err := m.getDanglingReferences(currentType.Name, uid, referenceFields)
+	if err != nil {
+		return fmt.Errorf("failed to delete document with docId: %v of type: %v, error verifying references: %v", chainDoc.ID, instance.GetValue("type"), err)
+	}
+	if len(dangling) > 0 {
+		log.Errorf(nil, "Deleted document: %v of type: %v with uid: %v is still referenced by: %v", chainDoc.ID, instance.GetValue("type"), uid, dangling)
+	}
 	return nil
 }
 
-//MutateEdge Creates/Deletes an edge
+// Generates the mutation that recreates the document as it is currently stored, including
+// its edges, returns nil if the document does not exist
+func (m *Doccache) recreateMutation(instance *gql.SimplifiedInstance) (*gql.Mutation, error) {
+	currentType, err := m.Schema.GetSimplifiedType(instance.SimplifiedType.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting simplified type: %v, error: %v", instance.SimplifiedType.Name, err)
+	}
+	if currentType == nil {
+		return nil, nil
+	}
+	current, err := m.GetDocumentInstance(instance.GetValue(DocumentIdName), currentType, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting current instance, error: %v", err)
+	}
+	if current == nil {
+		return nil, nil
+	}
+	return gql.NewSimplifiedInstance(currentType, nonNilValues(current.Values)).AddMutation(false), nil
+}
+
+//MutateEdge Creates/Deletes an edge, along with its inverse if one is configured
 func (m *Doccache) MutateEdge(chainEdge *domain.ChainEdge, deleteOp bool, cursor string) error {
-	instances, err := m.GetDocumentBaseInstances(
-		[]interface{}{chainEdge.From, chainEdge.To},
+	instances, err := m.getEdgeInstances(chainEdge)
+	if err != nil {
+		return fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, failed getting instances, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, err)
+	}
+	mutations, undoMutations, err := m.edgeMutations(chainEdge, instances, deleteOp)
+	if err != nil {
+		return err
+	}
+	if mutations == nil {
+		return m.mutatePendingEdge(chainEdge, deleteOp, cursor)
+	}
+	log.Infof("Mutating [Edge: %v (%v), From: %v, To: %v] Delete Op: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp)
+	err = m.mutateAll(mutations, cursor)
+	if err != nil {
+		return fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, failed storing edge, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, err)
+	}
+	for _, undoMutation := range undoMutations {
+		m.journal.Record(undoMutation)
+	}
+	return nil
+}
+
+// Generates the mutations that add or remove the edge and its inverse if one is configured, along
+// with the mutations that revert them, returns nil mutations if any of the nodes does not exist
+func (m *Doccache) edgeMutations(chainEdge *domain.ChainEdge, instances map[interface{}]*gql.SimplifiedBaseInstance, deleteOp bool) ([]*gql.Mutation, []*gql.Mutation, error) {
+	chainEdges := []*domain.ChainEdge{chainEdge}
+	if inverse := m.config.InverseEdges.GetInverse(chainEdge); inverse != nil && !inverse.Equal(chainEdge) {
+		chainEdges = append(chainEdges, inverse)
+	}
+	mutations := make([]*gql.Mutation, 0, len(chainEdges))
+	undoMutations := make([]*gql.Mutation, 0, len(chainEdges))
+	for _, chainEdge := range chainEdges {
+		fromType, edgeRef, err := m.prepareEdge(chainEdge, instances, deleteOp)
+		if err != nil {
+			return nil, nil, err
+		}
+		if fromType == nil {
+			return nil, nil, nil
+		}
+		var set, remove map[string]interface{}
+		if deleteOp {
+			remove = edgeRef
+		} else {
+			set = edgeRef
+		}
+		mutation, err := fromType.UpdateMutation(DocumentIdName, chainEdge.From, set, remove)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, failed creating edge mutation, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, err)
+		}
+		undoMutation, err := fromType.UpdateMutation(DocumentIdName, chainEdge.From, remove, set)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, failed creating undo mutation, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, err)
+		}
+		mutations = append(mutations, mutation)
+		undoMutations = append(undoMutations, undoMutation)
+	}
+	if m.config.EdgeMetadata {
+		mutation, undoMutation, err := m.edgeNodeMutation(chainEdge, deleteOp)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, err)
+		}
+		if mutation != nil {
+			mutations = append(mutations, mutation)
+			undoMutations = append(undoMutations, undoMutation)
+		}
+		if m.batch != nil && !deleteOp {
+			m.batch.AddedEdgeNode(chainEdge.GetId())
+		}
+	}
+	return mutations, undoMutations, nil
+}
+
+// Stores or removes the edge from the pending edges, used when any of its nodes does not exist
+func (m *Doccache) mutatePendingEdge(chainEdge *domain.ChainEdge, deleteOp bool, cursor string) error {
+	mutation, undoMutation, countChanged, err := m.pendingEdgeMutation(chainEdge, deleteOp)
+	if err != nil {
+		return fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, err)
+	}
+	if mutation == nil {
+		return m.UpdateCursor(cursor)
+	}
+	err = m.mutate(mutation, cursor)
+	if err != nil {
+		return fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, failed storing pending edge, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, err)
+	}
+	m.journal.Record(undoMutation)
+	m.trackPendingEdge(chainEdge, deleteOp, countChanged)
+	return nil
+}
+
+//UpdateEdge Updates an edge, removes the reference defined by the old edge and adds the
+//one defined by the new edge, the inverse edges are updated accordingly
+func (m *Doccache) UpdateEdge(oldEdge, newEdge *domain.ChainEdge, cursor string) error {
+	if oldEdge.Equal(newEdge) {
+		log.Infof("Edge update does not change the relationship: %v, only updating cursor", newEdge)
+		return m.UpdateCursor(cursor)
+	}
+	instances, err := m.getEdgeInstances(oldEdge, newEdge)
+	if err != nil {
+		return fmt.Errorf("failed updating edge from: %v to: %v, failed getting instances, error: %v", oldEdge, newEdge, err)
+	}
+	mutations, undoMutations, err := m.edgeMutations(oldEdge, instances, true)
+	if err != nil {
+		return fmt.Errorf("failed updating edge from: %v to: %v, error: %v", oldEdge, newEdge, err)
+	}
+	pendingEdges := make([]*pendingEdgeChange, 0, 2)
+	if mutations == nil {
+		mutation, undoMutation, countChanged, err := m.pendingEdgeMutation(oldEdge, true)
+		if err != nil {
+			return fmt.Errorf("failed updating edge from: %v to: %v, error: %v", oldEdge, newEdge, err)
+		}
+		if mutation != nil {
+			mutations = append(mutations, mutation)
+			undoMutations = append(undoMutations, undoMutation)
+			pendingEdges = append(pendingEdges, &pendingEdgeChange{oldEdge, true, countChanged})
+		}
+	}
+	setMutations, setUndoMutations, err := m.edgeMutations(newEdge, instances, false)
+	if err != nil {
+		return fmt.Errorf("failed updating edge from: %v to: %v, error: %v", oldEdge, newEdge, err)
+	}
+	if setMutations == nil {
+		mutation, undoMutation, countChanged, err := m.pendingEdgeMutation(newEdge, false)
+		if err != nil {
+			return fmt.Errorf("failed updating edge from: %v to: %v, error: %v", oldEdge, newEdge, err)
+		}
+		setMutations = []*gql.Mutation{mutation}
+		setUndoMutations = []*gql.Mutation{undoMutation}
+		pendingEdges = append(pendingEdges, &pendingEdgeChange{newEdge, false, countChanged})
+	}
+	mutations = append(mutations, setMutations...)
+	undoMutations = append(undoMutations, setUndoMutations...)
+	log.Infof("Updating edge from: %v to: %v", oldEdge, newEdge)
+	err = m.mutateAll(mutations, cursor)
+	if err != nil {
+		return fmt.Errorf("failed updating edge from: %v to: %v, failed storing edge, error: %v", oldEdge, newEdge, err)
+	}
+	for _, undoMutation := range undoMutations {
+		m.journal.Record(undoMutation)
+	}
+	for _, change := range pendingEdges {
+		m.trackPendingEdge(change.chainEdge, change.deleteOp, change.countChanged)
+	}
+	return nil
+}
+
+// Returns the instances of the nodes that are part of the edges, the documents modified by the
+// buffered mutations are taken from the batch
+func (m *Doccache) getEdgeInstances(chainEdges ...*domain.ChainEdge) (map[interface{}]*gql.SimplifiedBaseInstance, error) {
+	instances := make(map[interface{}]*gql.SimplifiedBaseInstance)
+	ids := make([]interface{}, 0, len(chainEdges)*2)
+	for _, chainEdge := range chainEdges {
+		for _, id := range []interface{}{chainEdge.From, chainEdge.To} {
+			if m.batch != nil {
+				if typeName, ok := m.batch.GetDocType(id); ok {
+					if typeName != "" {
+						instances[id] = gql.NewSimplifiedBaseInstance(
+							gql.DocumentSimplifiedInterface.SimplifiedBaseType,
+							map[string]interface{}{
+								"docId": id,
+								"type":  typeName,
+							},
+						)
+					}
+					continue
+				}
+			}
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return instances, nil
+	}
+	stored, err := m.GetDocumentBaseInstances(
+		ids,
 		gql.DocumentSimplifiedInterface.SimplifiedBaseType,
 		nil,
 	)
 	if err != nil {
-		return fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, failed getting instances, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, err)
+		return nil, err
+	}
+	for id, instance := range stored {
+		instances[id] = instance
 	}
+	return instances, nil
+}
 
+// Makes sure the schema of the FROM node type has the edge field, returns the FROM node type
+// and the reference to the TO node, returns a nil type if any of the nodes does not exist, in
+// which case the edge is handled as a pending edge
+func (m *Doccache) prepareEdge(chainEdge *domain.ChainEdge, instances map[interface{}]*gql.SimplifiedBaseInstance, deleteOp bool) (*gql.SimplifiedType, map[string]interface{}, error) {
 	fromInstance, ok := instances[chainEdge.From]
 	if !ok {
-		log.Errorf(nil, "FROM node of the relationship: [Edge: %v (%v), From: %v, To: %v] does not exist, Delete Op: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp)
-		return nil
+		log.Infof("FROM node of the relationship: [Edge: %v (%v), From: %v, To: %v] does not exist, Delete Op: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp)
+		return nil, nil, nil
 	}
 
 	toInstance, ok := instances[chainEdge.To]
 	if !ok {
-		log.Errorf(nil, "TO node of the relationship: [Edge: %v (%v), From: %v, To: %v] does not exist, Delete Op: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp)
-		return nil
+		log.Infof("TO node of the relationship: [Edge: %v (%v), From: %v, To: %v] does not exist, Delete Op: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp)
+		return nil, nil, nil
 	}
 
 	fromTypeName := fromInstance.GetValue("type").(string)
@@ -326,7 +891,7 @@ func (m *Doccache) MutateEdge(chainEdge *domain.ChainEdge, deleteOp bool, cursor
 
 	fromType, err := m.Schema.GetSimplifiedType(fromTypeName)
 	if err != nil {
-		return fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, failed getting type: %v, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, fromInstance.SimplifiedBaseType.Name, err)
+		return nil, nil, fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, failed getting type: %v, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, fromInstance.SimplifiedBaseType.Name, err)
 	}
 	edgeType := toTypeName
 	currentEdgeField := fromType.GetField(chainEdge.DocEdgeName)
@@ -335,23 +900,7 @@ func (m *Doccache) MutateEdge(chainEdge *domain.ChainEdge, deleteOp bool, cursor
 	}
 	err = m.updateSchemaEdge(fromTypeName, chainEdge.DocEdgeName, edgeType)
 	if err != nil {
-		return fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, failed updating schema, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, err)
-	}
-
-	var set, remove map[string]interface{}
-	if deleteOp {
-		remove = chainEdge.GetEdgeRef(toInstance.GetValue("docId"))
-	} else {
-		set = chainEdge.GetEdgeRef(toInstance.GetValue("docId"))
-	}
-	mutation, err := fromType.UpdateMutation(DocumentIdName, chainEdge.From, set, remove)
-	if err != nil {
-		return fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, failed creating edge mutation, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, err)
+		return nil, nil, fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, failed updating schema, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, err)
 	}
-	log.Infof("Mutating [Edge: %v (%v), From: %v, To: %v] Delete Op: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp)
-	err = m.mutate(mutation, cursor)
-	if err != nil {
-		return fmt.Errorf("failed mutating edge [Edge: %v (%v), From: %v, To: %v], Delete Op: %v, failed storing edge, error: %v", chainEdge.Name, chainEdge.DocEdgeName, chainEdge.From, chainEdge.To, deleteOp, err)
-	}
-	return nil
+	return fromType, chainEdge.GetEdgeRef(toInstance.GetValue("docId")), nil
 }
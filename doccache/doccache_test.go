@@ -1,6 +1,7 @@
 package doccache_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -169,6 +170,7 @@ func TestNameFieldIndexesUpdateFromExistingSchema(t *testing.T) {
 			"system_originalApprovedDate_t":  "2021-04-12T05:09:36.5Z",
 		},
 	)
+	setAssetCompanions(t, dhoInstance, "details_hvoiceSalaryPerPhase_a")
 
 	updateOp, err := cache.Schema.UpdateType(dhoType)
 	assert.NilError(t, err)
@@ -519,6 +521,7 @@ func TestOpCycle(t *testing.T) {
 			"system_originalApprovedDate_t":  "2021-04-12T05:09:36.5Z",
 		},
 	)
+	setAssetCompanions(t, expectedDHOInstance, "details_hvoiceSalaryPerPhase_a")
 	cursor = "cursor1"
 	err = cache.StoreDocument(dhoDoc, cursor)
 	assert.NilError(t, err)
@@ -742,6 +745,7 @@ func TestOpCycle(t *testing.T) {
 	expectedDHOInstance.SetValue("details_strToInt_i", int64(60))
 	expectedDHOInstance.SetValue("system_originalApprovedDate_t", "2021-05-12T05:09:36.5Z")
 	expectedDHOInstance.SetValue("details_hvoiceSalaryPerPhase_a", "4233.04 HVOICE")
+	setAssetCompanions(t, expectedDHOInstance, "details_hvoiceSalaryPerPhase_a")
 	expectedDHOInstance.SetValue("system_endPeriod_c", period2Hash)
 
 	cursor = "cursor6"
@@ -1088,6 +1092,7 @@ func TestDocumentCreationDeduceType(t *testing.T) {
 			"fail_votePower_a": "1.00 HVOICE",
 		},
 	)
+	setAssetCompanions(t, expectedInstance, "pass_votePower_a", "fail_votePower_a")
 
 	cursor := "cursor0"
 	err := cache.StoreDocument(chainDoc1, cursor)
@@ -1191,13 +1196,20 @@ func TestMissingCoreEdge(t *testing.T) {
 	t.Log("Store core edge")
 
 	period1Doc := getPeriodDoc(period1IdI, 1)
+	period1Doc.Hash = period1Hash
 	period1Instance := getPeriodInstance(period1IdI, 1)
+	period1Instance.SetValue("hash", period1Hash)
 	cursor = "cursor1"
 	err = cache.StoreDocument(period1Doc, cursor)
 	assert.NilError(t, err)
 	assertInstance(t, period1Instance)
 	assertCursor(t, cursor)
 
+	t.Log("Pending core edge of assignment 1 should be linked")
+	expectedType.SetField("details_startPeriod_c_edge", gql.NewCoreEdgeField("details_startPeriod_c_edge", "Period"))
+	expectedInstance.SetValue("details_startPeriod_c_edge", doccache.GetEdgeValue(period1Id))
+	assertInstance(t, expectedInstance)
+
 	t.Log("Store assignment 2 with related core edge")
 	assignment2Id := "2"
 	assignment2IdI, _ := strconv.ParseUint(assignment2Id, 10, 64)
@@ -1246,13 +1258,14 @@ func TestMissingCoreEdge(t *testing.T) {
 	expectedInstance2 := gql.NewSimplifiedInstance(
 		expectedType,
 		map[string]interface{}{
-			"docId":                 assignment2Id,
-			"createdDate":           "2020-11-12T18:27:47.000Z",
-			"updatedDate":           "2020-11-12T19:27:47.000Z",
-			"creator":               "dao.hypha",
-			"contract":              "contract1",
-			"type":                  "Assignment",
-			"details_startPeriod_c": period1Hash,
+			"docId":                      assignment2Id,
+			"createdDate":                "2020-11-12T18:27:47.000Z",
+			"updatedDate":                "2020-11-12T19:27:47.000Z",
+			"creator":                    "dao.hypha",
+			"contract":                   "contract1",
+			"type":                       "Assignment",
+			"details_startPeriod_c":      period1Hash,
+			"details_startPeriod_c_edge": doccache.GetEdgeValue(period1Id),
 		},
 	)
 
@@ -1264,18 +1277,26 @@ func TestMissingCoreEdge(t *testing.T) {
 
 	cursor = "cursor4"
 
-	t.Log("Delete core edge document")
+	t.Log("Delete core edge document, core edges should be removed")
 	err = cache.DeleteDocument(period1Doc, cursor)
 	assert.NilError(t, err)
 	assertInstanceNotExists(t, period1Id, "Period")
 	assertCursor(t, cursor)
+	expectedInstance.SetValue("details_startPeriod_c_edge", nil)
+	assertInstance(t, expectedInstance)
+	expectedInstance2.SetValue("details_startPeriod_c_edge", nil)
+	assertInstance(t, expectedInstance2)
 
-	t.Log("Store core edge again")
+	t.Log("Store core edge again, core edges should be linked again")
 	cursor = "cursor5"
 	err = cache.StoreDocument(period1Doc, cursor)
 	assert.NilError(t, err)
 	assertInstance(t, period1Instance)
 	assertCursor(t, cursor)
+	expectedInstance.SetValue("details_startPeriod_c_edge", doccache.GetEdgeValue(period1Id))
+	assertInstance(t, expectedInstance)
+	expectedInstance2.SetValue("details_startPeriod_c_edge", doccache.GetEdgeValue(period1Id))
+	assertInstance(t, expectedInstance2)
 
 	t.Log("Update assignment 2, should relink core edge")
 	cursor = "cursor6"
@@ -1391,6 +1412,7 @@ func TestLogicalIds(t *testing.T) {
 			"details_hvoiceSalaryPerPhase_a": "4133.04 HVOICE",
 		},
 	)
+	setAssetCompanions(t, expectedDHOInstance, "details_hvoiceSalaryPerPhase_a")
 	cursor := "cursor1"
 	err := cache.StoreDocument(dhoDoc, cursor)
 	assert.NilError(t, err)
@@ -1461,6 +1483,7 @@ func TestLogicalIds(t *testing.T) {
 			"details_hvoiceSalaryPerPhase_a": "4133.14 HVOICE",
 		},
 	)
+	setAssetCompanions(t, expectedDHOInstance, "details_hvoiceSalaryPerPhase_a")
 	cursor = "cursor2"
 	err = cache.StoreDocument(dhoDoc, cursor)
 	assert.NilError(t, err)
@@ -3968,6 +3991,756 @@ func TestCustomInterfacesShouldFailForAddingInvalidTypeEdge(t *testing.T) {
 
 }
 
+func TestUndoBlock(t *testing.T) {
+	setUp("./config-no-special-config.yml")
+
+	member1Id := "31"
+	member1IdI, _ := strconv.ParseUint(member1Id, 10, 64)
+	period1Id := "21"
+	period1IdI, _ := strconv.ParseUint(period1Id, 10, 64)
+
+	expectedPeriodType := periodType.Clone()
+	expectedPeriodType.SetField("details_label_s", &gql.SimplifiedField{
+		Name:    "details_label_s",
+		Type:    "String",
+		Indexes: gql.NewIndexes("regexp"),
+	})
+	expectedPeriodType.SetField("member", &gql.SimplifiedField{
+		Name:    "member",
+		Type:    "Member",
+		IsArray: true,
+		NonNull: false,
+	})
+
+	t.Log("Block 10: Storing member, period and member edge")
+	cache.SetBlock(10)
+	memberDoc := getMemberDoc(member1IdI, "member1")
+	expectedMemberInstance := getMemberInstance(member1IdI, "member1")
+	err := cache.StoreDocument(memberDoc, "cursor10_1")
+	assert.NilError(t, err)
+	assertInstance(t, expectedMemberInstance)
+
+	periodDoc := getPeriodDoc(period1IdI, 1)
+	periodDoc.ContentGroups[0] = append(periodDoc.ContentGroups[0], &domain.ChainContent{
+		Label: "label",
+		Value: []interface{}{
+			"string",
+			"Period 1",
+		},
+	})
+	err = cache.StoreDocument(periodDoc, "cursor10_2")
+	assert.NilError(t, err)
+
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member1Id), false, "cursor10_3")
+	assert.NilError(t, err)
+
+	block10PeriodInstance := gql.NewSimplifiedInstance(
+		expectedPeriodType,
+		map[string]interface{}{
+			"docId":            period1Id,
+			"createdDate":      "2020-11-12T18:27:47.000Z",
+			"updatedDate":      "2020-11-12T19:27:47.000Z",
+			"creator":          "dao.hypha",
+			"contract":         "contract1",
+			"type":             "Period",
+			"details_number_i": int64(1),
+			"details_label_s":  "Period 1",
+			"member": []map[string]interface{}{
+				{"docId": member1Id},
+			},
+		},
+	)
+	assertInstance(t, block10PeriodInstance)
+	assertCursor(t, "cursor10_3")
+
+	t.Log("Block 11: Updating period, removing member edge and deleting member")
+	cache.SetBlock(11)
+	periodDoc = getPeriodDoc(period1IdI, 2)
+	periodDoc.UpdatedDate = "2020-11-13T19:27:47.000"
+	err = cache.StoreDocument(periodDoc, "cursor11_1")
+	assert.NilError(t, err)
+
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member1Id), true, "cursor11_2")
+	assert.NilError(t, err)
+
+	err = cache.DeleteDocument(memberDoc, "cursor11_3")
+	assert.NilError(t, err)
+
+	block11PeriodInstance := gql.NewSimplifiedInstance(
+		expectedPeriodType,
+		map[string]interface{}{
+			"docId":            period1Id,
+			"createdDate":      "2020-11-12T18:27:47.000Z",
+			"updatedDate":      "2020-11-13T19:27:47.000Z",
+			"creator":          "dao.hypha",
+			"contract":         "contract1",
+			"type":             "Period",
+			"details_number_i": int64(2),
+			"details_label_s":  nil,
+			"member":           []map[string]interface{}{},
+		},
+	)
+	assertInstance(t, block11PeriodInstance)
+	assertInstanceNotExists(t, member1Id, "Member")
+
+	t.Log("Undoing block 11, should restore member, edge and period values")
+	undone, err := cache.UndoBlock(11, "cursor11_undo")
+	assert.NilError(t, err)
+	assert.Assert(t, undone)
+	assertInstance(t, expectedMemberInstance)
+	assertInstance(t, block10PeriodInstance)
+	assertCursor(t, "cursor11_undo")
+
+	t.Log("Undoing block 11 again, should not find undo information")
+	undone, err = cache.UndoBlock(11, "cursor11_undo2")
+	assert.NilError(t, err)
+	assert.Assert(t, !undone)
+
+	t.Log("Undoing block 10, should remove all documents")
+	undone, err = cache.UndoBlock(10, "cursor10_undo")
+	assert.NilError(t, err)
+	assert.Assert(t, undone)
+	assertInstanceNotExists(t, member1Id, "Member")
+	assertInstanceNotExists(t, period1Id, "Period")
+	assertCursor(t, "cursor10_undo")
+}
+
+func TestUpdateEdge(t *testing.T) {
+	setUp("./config-no-special-config.yml")
+
+	member1Id := "31"
+	member1IdI, _ := strconv.ParseUint(member1Id, 10, 64)
+	member2Id := "32"
+	member2IdI, _ := strconv.ParseUint(member2Id, 10, 64)
+	period1Id := "21"
+	period1IdI, _ := strconv.ParseUint(period1Id, 10, 64)
+
+	err := cache.StoreDocument(getMemberDoc(member1IdI, "member1"), "cursor1")
+	assert.NilError(t, err)
+	err = cache.StoreDocument(getMemberDoc(member2IdI, "member2"), "cursor2")
+	assert.NilError(t, err)
+	err = cache.StoreDocument(getPeriodDoc(period1IdI, 1), "cursor3")
+	assert.NilError(t, err)
+
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member1Id), false, "cursor4")
+	assert.NilError(t, err)
+
+	expectedPeriodType := periodType.Clone()
+	expectedPeriodType.SetField("member", &gql.SimplifiedField{
+		Name:    "member",
+		Type:    "Member",
+		IsArray: true,
+		NonNull: false,
+	})
+	expectedPeriodInstance := getPeriodInstance(period1IdI, 1)
+	expectedPeriodInstance.SimplifiedType = expectedPeriodType
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{
+		{"docId": member1Id},
+	})
+	assertInstance(t, expectedPeriodInstance)
+
+	t.Log("Updating edge TO node")
+	err = cache.UpdateEdge(
+		domain.NewChainEdge("member", period1Id, member1Id),
+		domain.NewChainEdge("member", period1Id, member2Id),
+		"cursor5",
+	)
+	assert.NilError(t, err)
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{
+		{"docId": member2Id},
+	})
+	assertInstance(t, expectedPeriodInstance)
+	assertCursor(t, "cursor5")
+
+	t.Log("Updating edge name")
+	err = cache.UpdateEdge(
+		domain.NewChainEdge("member", period1Id, member2Id),
+		domain.NewChainEdge("assignee", period1Id, member2Id),
+		"cursor6",
+	)
+	assert.NilError(t, err)
+	expectedPeriodType.SetField("assignee", &gql.SimplifiedField{
+		Name:    "assignee",
+		Type:    "Member",
+		IsArray: true,
+		NonNull: false,
+	})
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{})
+	expectedPeriodInstance.SetValue("assignee", []map[string]interface{}{
+		{"docId": member2Id},
+	})
+	assertInstance(t, expectedPeriodInstance)
+	assertCursor(t, "cursor6")
+
+	t.Log("Updating edge without changing the relationship")
+	err = cache.UpdateEdge(
+		domain.NewChainEdge("assignee", period1Id, member2Id),
+		domain.NewChainEdge("assignee", period1Id, member2Id),
+		"cursor7",
+	)
+	assert.NilError(t, err)
+	assertInstance(t, expectedPeriodInstance)
+	assertCursor(t, "cursor7")
+}
+
+func TestPendingEdge(t *testing.T) {
+	setUp("./config-no-special-config.yml")
+
+	member1Id := "31"
+	member1IdI, _ := strconv.ParseUint(member1Id, 10, 64)
+	member2Id := "32"
+	period1Id := "21"
+	period1IdI, _ := strconv.ParseUint(period1Id, 10, 64)
+
+	err := cache.StoreDocument(getPeriodDoc(period1IdI, 1), "cursor1")
+	assert.NilError(t, err)
+
+	t.Log("Storing edges whose TO node does not exist")
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member1Id), false, "cursor2")
+	assert.NilError(t, err)
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member2Id), false, "cursor3")
+	assert.NilError(t, err)
+	assert.Equal(t, cache.PendingEdgeCount(), int64(2))
+	pendingEdges, err := cache.GetPendingEdges()
+	assert.NilError(t, err)
+	assert.Equal(t, len(pendingEdges), 2)
+	assertCursor(t, "cursor3")
+
+	t.Log("Storing an existing pending edge again does not change the count")
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member2Id), false, "cursor3")
+	assert.NilError(t, err)
+	assert.Equal(t, cache.PendingEdgeCount(), int64(2))
+
+	t.Log("Deleting pending edge")
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member2Id), true, "cursor4")
+	assert.NilError(t, err)
+	assert.Equal(t, cache.PendingEdgeCount(), int64(1))
+	pendingEdges, err = cache.GetPendingEdges()
+	assert.NilError(t, err)
+	assert.Equal(t, len(pendingEdges), 1)
+	assert.Assert(t, pendingEdges[0].Equal(domain.NewChainEdge("member", period1Id, member1Id)))
+
+	t.Log("Storing TO node applies pending edge")
+	err = cache.StoreDocument(getMemberDoc(member1IdI, "member1"), "cursor5")
+	assert.NilError(t, err)
+	assert.Equal(t, cache.PendingEdgeCount(), int64(0))
+	pendingEdges, err = cache.GetPendingEdges()
+	assert.NilError(t, err)
+	assert.Equal(t, len(pendingEdges), 0)
+
+	expectedPeriodType := periodType.Clone()
+	expectedPeriodType.SetField("member", &gql.SimplifiedField{
+		Name:    "member",
+		Type:    "Member",
+		IsArray: true,
+		NonNull: false,
+	})
+	expectedPeriodInstance := getPeriodInstance(period1IdI, 1)
+	expectedPeriodInstance.SimplifiedType = expectedPeriodType
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{
+		{"docId": member1Id},
+	})
+	assertInstance(t, expectedPeriodInstance)
+	assertCursor(t, "cursor5")
+}
+
+func TestInverseEdges(t *testing.T) {
+	setUp("./config-inverse-edges.yml")
+
+	member1Id := "31"
+	member1IdI, _ := strconv.ParseUint(member1Id, 10, 64)
+	member2Id := "32"
+	member2IdI, _ := strconv.ParseUint(member2Id, 10, 64)
+	period1Id := "21"
+	period1IdI, _ := strconv.ParseUint(period1Id, 10, 64)
+
+	err := cache.StoreDocument(getMemberDoc(member1IdI, "member1"), "cursor1")
+	assert.NilError(t, err)
+	err = cache.StoreDocument(getPeriodDoc(period1IdI, 1), "cursor2")
+	assert.NilError(t, err)
+
+	t.Log("Creating edge should create its inverse")
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member1Id), false, "cursor3")
+	assert.NilError(t, err)
+
+	expectedPeriodType := periodType.Clone()
+	expectedPeriodType.SetField("member", &gql.SimplifiedField{
+		Name:    "member",
+		Type:    "Member",
+		IsArray: true,
+		NonNull: false,
+	})
+	expectedPeriodInstance := getPeriodInstance(period1IdI, 1)
+	expectedPeriodInstance.SimplifiedType = expectedPeriodType
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{
+		{"docId": member1Id},
+	})
+	assertInstance(t, expectedPeriodInstance)
+
+	expectedMemberType := memberType.Clone()
+	expectedMemberType.SetField("memberof", &gql.SimplifiedField{
+		Name:    "memberof",
+		Type:    "Period",
+		IsArray: true,
+		NonNull: false,
+	})
+	expectedMember1Instance := getMemberInstance(member1IdI, "member1")
+	expectedMember1Instance.SimplifiedType = expectedMemberType
+	expectedMember1Instance.SetValue("memberof", []map[string]interface{}{
+		{"docId": period1Id},
+	})
+	assertInstance(t, expectedMember1Instance)
+	assertCursor(t, "cursor3")
+
+	t.Log("Storing pending edge should create its inverse once applied")
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member2Id), false, "cursor4")
+	assert.NilError(t, err)
+	err = cache.StoreDocument(getMemberDoc(member2IdI, "member2"), "cursor5")
+	assert.NilError(t, err)
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{
+		{"docId": member1Id},
+		{"docId": member2Id},
+	})
+	assertInstance(t, expectedPeriodInstance)
+	expectedMember2Instance := getMemberInstance(member2IdI, "member2")
+	expectedMember2Instance.SimplifiedType = expectedMemberType
+	expectedMember2Instance.SetValue("memberof", []map[string]interface{}{
+		{"docId": period1Id},
+	})
+	assertInstance(t, expectedMember2Instance)
+
+	t.Log("Deleting edge should delete its inverse")
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member1Id), true, "cursor6")
+	assert.NilError(t, err)
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{
+		{"docId": member2Id},
+	})
+	assertInstance(t, expectedPeriodInstance)
+	expectedMember1Instance.SetValue("memberof", []map[string]interface{}{})
+	assertInstance(t, expectedMember1Instance)
+	assertCursor(t, "cursor6")
+}
+
+func TestEdgeMetadata(t *testing.T) {
+	setUp("./config-edge-metadata.yml")
+
+	member1Id := "31"
+	member1IdI, _ := strconv.ParseUint(member1Id, 10, 64)
+	period1Id := "21"
+	period1IdI, _ := strconv.ParseUint(period1Id, 10, 64)
+
+	err := cache.StoreDocument(getMemberDoc(member1IdI, "member1"), "cursor1")
+	assert.NilError(t, err)
+	err = cache.StoreDocument(getPeriodDoc(period1IdI, 1), "cursor2")
+	assert.NilError(t, err)
+
+	t.Log("Creating edge should store edge node with metadata")
+	chainEdge := domain.NewChainEdge("member", period1Id, member1Id)
+	chainEdge.Creator = "member1"
+	chainEdge.Contract = "dao.hypha"
+	chainEdge.CreatedDate = "2020-11-12T18:27:47.000"
+	chainEdge.CreatedBlock = 100
+	err = cache.MutateEdge(chainEdge, false, "cursor3")
+	assert.NilError(t, err)
+	edgeId := chainEdge.GetId()
+	edgeNodes, err := client.GetBaseInstances("id", []interface{}{edgeId}, gql.EdgeSimplifiedType.SimplifiedBaseType, nil)
+	assert.NilError(t, err)
+	edgeNode, ok := edgeNodes[edgeId]
+	assert.Assert(t, ok)
+	assert.Equal(t, edgeNode.GetValue("name"), "member")
+	assert.Equal(t, edgeNode.GetValue("creator"), "member1")
+	assert.Equal(t, edgeNode.GetValue("contract"), "dao.hypha")
+	assert.Equal(t, edgeNode.GetValue("createdDate"), "2020-11-12T18:27:47Z")
+	assert.DeepEqual(t, edgeNode.GetValue("from"), map[string]interface{}{"docId": period1Id})
+	assert.DeepEqual(t, edgeNode.GetValue("to"), map[string]interface{}{"docId": member1Id})
+	assertCursor(t, "cursor3")
+
+	t.Log("Deleting edge should delete edge node")
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member1Id), true, "cursor4")
+	assert.NilError(t, err)
+	edgeNodes, err = client.GetBaseInstances("id", []interface{}{edgeId}, gql.EdgeSimplifiedType.SimplifiedBaseType, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(edgeNodes), 0)
+
+	t.Log("Deleting document should delete its edge nodes")
+	err = cache.MutateEdge(chainEdge, false, "cursor5")
+	assert.NilError(t, err)
+	err = cache.DeleteDocument(getMemberDoc(member1IdI, "member1"), "cursor6")
+	assert.NilError(t, err)
+	edgeNodes, err = client.GetBaseInstances("id", []interface{}{edgeId}, gql.EdgeSimplifiedType.SimplifiedBaseType, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(edgeNodes), 0)
+}
+
+func TestDocumentHistory(t *testing.T) {
+	setUp("./config-history-types.yml")
+
+	periodId := "41"
+	periodIdI, _ := strconv.ParseUint(periodId, 10, 64)
+	memberId := "42"
+	memberIdI, _ := strconv.ParseUint(memberId, 10, 64)
+
+	t.Log("Creating document should not store a version")
+	err := cache.StoreDocument(getPeriodDoc(periodIdI, 1), "cursor1")
+	assert.NilError(t, err)
+	assertDocumentVersions(t, periodId, 0)
+
+	t.Log("Updating document should store its previous values as a version")
+	periodDoc := getPeriodDoc(periodIdI, 2)
+	periodDoc.UpdatedDate = "2020-11-13T19:27:47.000"
+	periodDoc.Block = 101
+	err = cache.StoreDocument(periodDoc, "cursor2")
+	assert.NilError(t, err)
+	expectedPeriodInstance := getPeriodInstance(periodIdI, 2)
+	expectedPeriodInstance.SetValue("updatedDate", "2020-11-13T19:27:47.000Z")
+	expectedPeriodInstance.SetValue("updatedBlock", int64(101))
+	assertInstance(t, expectedPeriodInstance)
+	version := assertDocumentVersions(t, periodId, 1)[doccache.GetDocumentVersionId(periodId, 1)]
+	assert.Equal(t, version.GetValue("version"), float64(1))
+	assert.Equal(t, version.GetValue("block"), float64(101))
+	assert.Equal(t, version.GetValue("date"), "2020-11-13T19:27:47Z")
+	assert.DeepEqual(t, version.GetValue("document"), map[string]interface{}{"docId": periodId})
+	var values map[string]interface{}
+	err = json.Unmarshal([]byte(version.GetValue("values").(string)), &values)
+	assert.NilError(t, err)
+	assert.Equal(t, values["details_number_i"], float64(1))
+	assert.Equal(t, values["updatedDate"], "2020-11-12T19:27:47Z")
+
+	t.Log("Updating document again should store the next version")
+	periodDoc = getPeriodDoc(periodIdI, 3)
+	periodDoc.UpdatedDate = "2020-11-14T19:27:47.000"
+	periodDoc.Block = 102
+	err = cache.StoreDocument(periodDoc, "cursor3")
+	assert.NilError(t, err)
+	version = assertDocumentVersions(t, periodId, 2)[doccache.GetDocumentVersionId(periodId, 2)]
+	assert.Equal(t, version.GetValue("version"), float64(2))
+	assert.Equal(t, version.GetValue("block"), float64(102))
+	err = json.Unmarshal([]byte(version.GetValue("values").(string)), &values)
+	assert.NilError(t, err)
+	assert.Equal(t, values["details_number_i"], float64(2))
+
+	t.Log("Updating document of type without history should not store a version")
+	err = cache.StoreDocument(getMemberDoc(memberIdI, "member1"), "cursor4")
+	assert.NilError(t, err)
+	err = cache.StoreDocument(getMemberDoc(memberIdI, "member2"), "cursor5")
+	assert.NilError(t, err)
+	assertDocumentVersions(t, memberId, 0)
+
+	t.Log("Deleting document should delete its versions")
+	err = cache.DeleteDocument(getPeriodDoc(periodIdI, 3), "cursor6")
+	assert.NilError(t, err)
+	assertDocumentVersions(t, periodId, 0)
+	assertCursor(t, "cursor6")
+}
+
+func TestDocumentBlockMetadata(t *testing.T) {
+	setUp("./config-no-special-config.yml")
+
+	periodId := "41"
+	periodIdI, _ := strconv.ParseUint(periodId, 10, 64)
+
+	t.Log("Creating document should record the block and transaction that created it")
+	periodDoc := getPeriodDoc(periodIdI, 1)
+	periodDoc.Block = 100
+	periodDoc.BlockTime = time.Date(2020, 11, 12, 19, 27, 47, 0, time.UTC)
+	periodDoc.TrxId = "trx1"
+	err := cache.StoreDocument(periodDoc, "cursor1")
+	assert.NilError(t, err)
+	expectedPeriodInstance := getPeriodInstance(periodIdI, 1)
+	expectedPeriodInstance.SetValue("createdBlock", int64(100))
+	expectedPeriodInstance.SetValue("updatedBlock", int64(100))
+	expectedPeriodInstance.SetValue("updatedTrxId", "trx1")
+	expectedPeriodInstance.SetValue("updatedBlockTime", "2020-11-12T19:27:47Z")
+	assertInstance(t, expectedPeriodInstance)
+
+	t.Log("Updating document should record the block and transaction of the update and keep the created block")
+	periodDoc = getPeriodDoc(periodIdI, 2)
+	periodDoc.Block = 101
+	periodDoc.BlockTime = time.Date(2020, 11, 13, 19, 27, 47, 0, time.UTC)
+	periodDoc.TrxId = "trx2"
+	err = cache.StoreDocument(periodDoc, "cursor2")
+	assert.NilError(t, err)
+	expectedPeriodInstance = getPeriodInstance(periodIdI, 2)
+	expectedPeriodInstance.SetValue("createdBlock", int64(100))
+	expectedPeriodInstance.SetValue("updatedBlock", int64(101))
+	expectedPeriodInstance.SetValue("updatedTrxId", "trx2")
+	expectedPeriodInstance.SetValue("updatedBlockTime", "2020-11-13T19:27:47Z")
+	assertInstance(t, expectedPeriodInstance)
+
+	t.Log("Updating document without block information should remove the values of the previous change")
+	err = cache.StoreDocument(getPeriodDoc(periodIdI, 3), "cursor3")
+	assert.NilError(t, err)
+	expectedPeriodInstance = getPeriodInstance(periodIdI, 3)
+	expectedPeriodInstance.SetValue("createdBlock", int64(100))
+	assertInstance(t, expectedPeriodInstance)
+	assertCursor(t, "cursor3")
+}
+
+func TestSoftDelete(t *testing.T) {
+	setUp("./config-soft-delete.yml")
+
+	member1Id := "31"
+	member1IdI, _ := strconv.ParseUint(member1Id, 10, 64)
+	period1Id := "21"
+	period1IdI, _ := strconv.ParseUint(period1Id, 10, 64)
+
+	err := cache.StoreDocument(getMemberDoc(member1IdI, "member1"), "cursor1")
+	assert.NilError(t, err)
+	err = cache.StoreDocument(getPeriodDoc(period1IdI, 1), "cursor2")
+	assert.NilError(t, err)
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member1Id), false, "cursor3")
+	assert.NilError(t, err)
+	err = cache.MutateEdge(domain.NewChainEdge("period", member1Id, period1Id), false, "cursor4")
+	assert.NilError(t, err)
+
+	expectedPeriodType := periodType.Clone()
+	expectedPeriodType.SetField("member", &gql.SimplifiedField{
+		Name:    "member",
+		Type:    "Member",
+		IsArray: true,
+		NonNull: false,
+	})
+	expectedPeriodInstance := getPeriodInstance(period1IdI, 1)
+	expectedPeriodInstance.SimplifiedType = expectedPeriodType
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{
+		{"docId": member1Id},
+	})
+	expectedMemberType := memberType.Clone()
+	expectedMemberType.SetField("period", &gql.SimplifiedField{
+		Name:    "period",
+		Type:    "Period",
+		IsArray: true,
+		NonNull: false,
+	})
+	expectedMemberInstance := getMemberInstance(member1IdI, "member1")
+	expectedMemberInstance.SimplifiedType = expectedMemberType
+	expectedMemberInstance.SetValue("period", []map[string]interface{}{
+		{"docId": period1Id},
+	})
+	assertInstance(t, expectedPeriodInstance)
+	assertInstance(t, expectedMemberInstance)
+
+	t.Log("Deleting document of soft delete type should mark it as deleted and remove the references to it")
+	cache.SetBlock(10)
+	periodDoc := getPeriodDoc(period1IdI, 1)
+	periodDoc.Block = 10
+	periodDoc.BlockTime = time.Date(2020, 11, 13, 19, 27, 47, 0, time.UTC)
+	periodDoc.TrxId = "trx1"
+	err = cache.DeleteDocument(periodDoc, "cursor5")
+	assert.NilError(t, err)
+	deletedPeriodInstance := getPeriodInstance(period1IdI, 1)
+	deletedPeriodInstance.SimplifiedType = expectedPeriodType
+	deletedPeriodInstance.SetValue("member", []map[string]interface{}{
+		{"docId": member1Id},
+	})
+	deletedPeriodInstance.SetValue("deleted", true)
+	deletedPeriodInstance.SetValue("deletedDate", "2020-11-13T19:27:47Z")
+	deletedPeriodInstance.SetValue("updatedBlock", int64(10))
+	deletedPeriodInstance.SetValue("updatedTrxId", "trx1")
+	deletedPeriodInstance.SetValue("updatedBlockTime", "2020-11-13T19:27:47Z")
+	assertInstance(t, deletedPeriodInstance)
+	expectedMemberInstance.SetValue("period", []map[string]interface{}{})
+	assertInstance(t, expectedMemberInstance)
+	assertCursor(t, "cursor5")
+
+	t.Log("Undoing block should restore the document and the references to it")
+	undone, err := cache.UndoBlock(10, "cursor6")
+	assert.NilError(t, err)
+	assert.Assert(t, undone)
+	cache.SetBlock(0)
+	assertInstance(t, expectedPeriodInstance)
+	expectedMemberInstance.SetValue("period", []map[string]interface{}{
+		{"docId": period1Id},
+	})
+	assertInstance(t, expectedMemberInstance)
+
+	t.Log("Deleting document of type without soft delete should remove it")
+	err = cache.DeleteDocument(getMemberDoc(member1IdI, "member1"), "cursor7")
+	assert.NilError(t, err)
+	assertInstanceNotExists(t, member1Id, "Member")
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{})
+	assertInstance(t, expectedPeriodInstance)
+}
+
+func TestDocumentTypeChange(t *testing.T) {
+	setUp("./config-no-special-config.yml")
+
+	member1Id := "31"
+	member1IdI, _ := strconv.ParseUint(member1Id, 10, 64)
+	docId := "21"
+	docIdI, _ := strconv.ParseUint(docId, 10, 64)
+
+	err := cache.StoreDocument(getMemberDoc(member1IdI, "member1"), "cursor1")
+	assert.NilError(t, err)
+	err = cache.StoreDocument(getPeriodDoc(docIdI, 1), "cursor2")
+	assert.NilError(t, err)
+	err = cache.MutateEdge(domain.NewChainEdge("member", docId, member1Id), false, "cursor3")
+	assert.NilError(t, err)
+	err = cache.MutateEdge(domain.NewChainEdge("period", member1Id, docId), false, "cursor4")
+	assert.NilError(t, err)
+
+	expectedPeriodType := periodType.Clone()
+	expectedPeriodType.SetField("member", &gql.SimplifiedField{
+		Name:    "member",
+		Type:    "Member",
+		IsArray: true,
+		NonNull: false,
+	})
+	expectedPeriodInstance := getPeriodInstance(docIdI, 1)
+	expectedPeriodInstance.SimplifiedType = expectedPeriodType
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{
+		{"docId": member1Id},
+	})
+	assertInstance(t, expectedPeriodInstance)
+
+	t.Log("Storing document with a different type should move it to the new type preserving its edges")
+	cache.SetBlock(10)
+	err = cache.StoreDocument(getUserDoc(docIdI, "user1"), "cursor5")
+	assert.NilError(t, err)
+	assertInstanceNotExists(t, docId, "Period")
+	expectedUserType := userType.Clone()
+	expectedUserType.SetField("member", &gql.SimplifiedField{
+		Name:    "member",
+		Type:    "Member",
+		IsArray: true,
+		NonNull: false,
+	})
+	expectedUserInstance := getUserInstance(docIdI, "user1")
+	expectedUserInstance.SimplifiedType = expectedUserType
+	expectedUserInstance.SetValue("member", []map[string]interface{}{
+		{"docId": member1Id},
+	})
+	assertInstance(t, expectedUserInstance)
+
+	t.Log("References to the document should be generalized to reference any document")
+	expectedMemberType := memberType.Clone()
+	expectedMemberType.SetField("period", &gql.SimplifiedField{
+		Name:    "period",
+		Type:    gql.DocumentSimplifiedInterface.Name,
+		IsArray: true,
+		NonNull: false,
+	})
+	expectedMemberInstance := getMemberInstance(member1IdI, "member1")
+	expectedMemberInstance.SimplifiedType = expectedMemberType
+	expectedMemberInstance.SetValue("period", []map[string]interface{}{
+		{"docId": docId},
+	})
+	assertInstance(t, expectedMemberInstance)
+	assertCursor(t, "cursor5")
+
+	t.Log("Undoing block should restore the document with its old type")
+	undone, err := cache.UndoBlock(10, "cursor6")
+	assert.NilError(t, err)
+	assert.Assert(t, undone)
+	cache.SetBlock(0)
+	assertInstanceNotExists(t, docId, "User")
+	assertInstance(t, expectedPeriodInstance)
+	assertInstance(t, expectedMemberInstance)
+}
+
+func TestDeleteDocumentRemovesReferences(t *testing.T) {
+	setUp("./config-no-special-config.yml")
+
+	member1Id := "31"
+	member1IdI, _ := strconv.ParseUint(member1Id, 10, 64)
+	member2Id := "32"
+	period1Id := "21"
+	period1IdI, _ := strconv.ParseUint(period1Id, 10, 64)
+
+	err := cache.StoreDocument(getMemberDoc(member1IdI, "member1"), "cursor1")
+	assert.NilError(t, err)
+	err = cache.StoreDocument(getPeriodDoc(period1IdI, 1), "cursor2")
+	assert.NilError(t, err)
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member1Id), false, "cursor3")
+	assert.NilError(t, err)
+	err = cache.MutateEdge(domain.NewChainEdge("member", member1Id, member2Id), false, "cursor4")
+	assert.NilError(t, err)
+	assert.Equal(t, cache.PendingEdgeCount(), int64(1))
+
+	t.Log("Deleting member should remove the references to it and its pending edges")
+	err = cache.DeleteDocument(getMemberDoc(member1IdI, "member1"), "cursor5")
+	assert.NilError(t, err)
+	assertInstanceNotExists(t, member1Id, "Member")
+	assertCursor(t, "cursor5")
+
+	expectedPeriodType := periodType.Clone()
+	expectedPeriodType.SetField("member", &gql.SimplifiedField{
+		Name:    "member",
+		Type:    "Member",
+		IsArray: true,
+		NonNull: false,
+	})
+	expectedPeriodInstance := getPeriodInstance(period1IdI, 1)
+	expectedPeriodInstance.SimplifiedType = expectedPeriodType
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{})
+	assertInstance(t, expectedPeriodInstance)
+	assert.Equal(t, cache.PendingEdgeCount(), int64(0))
+	pendingEdges, err := cache.GetPendingEdges()
+	assert.NilError(t, err)
+	assert.Equal(t, len(pendingEdges), 0)
+}
+
+func TestBatch(t *testing.T) {
+	setUp("./config-no-special-config.yml")
+
+	member1Id := "31"
+	member1IdI, _ := strconv.ParseUint(member1Id, 10, 64)
+	period1Id := "21"
+	period1IdI, _ := strconv.ParseUint(period1Id, 10, 64)
+
+	expectedPeriodType := periodType.Clone()
+	expectedPeriodType.SetField("member", &gql.SimplifiedField{
+		Name:    "member",
+		Type:    "Member",
+		IsArray: true,
+		NonNull: false,
+	})
+
+	t.Log("Batching creation of member, period and member edge")
+	cache.StartBatch()
+	memberDoc := getMemberDoc(member1IdI, "member1")
+	err := cache.StoreDocument(memberDoc, "cursor1")
+	assert.NilError(t, err)
+	err = cache.StoreDocument(getPeriodDoc(period1IdI, 1), "cursor2")
+	assert.NilError(t, err)
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member1Id), false, "cursor3")
+	assert.NilError(t, err)
+	assertInstanceNotExists(t, member1Id, "Member")
+
+	err = cache.Flush()
+	assert.NilError(t, err)
+	assert.Assert(t, !cache.IsBatching())
+	expectedPeriodInstance := getPeriodInstance(period1IdI, 1)
+	expectedPeriodInstance.SimplifiedType = expectedPeriodType
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{
+		{"docId": member1Id},
+	})
+	assertInstance(t, getMemberInstance(member1IdI, "member1"))
+	assertInstance(t, expectedPeriodInstance)
+	assertCursor(t, "cursor3")
+
+	t.Log("Batching update of period that is modified twice, and member deletion")
+	cache.StartBatch()
+	err = cache.StoreDocument(getPeriodDoc(period1IdI, 2), "cursor4")
+	assert.NilError(t, err)
+	err = cache.StoreDocument(getPeriodDoc(period1IdI, 3), "cursor5")
+	assert.NilError(t, err)
+	err = cache.MutateEdge(domain.NewChainEdge("member", period1Id, member1Id), true, "cursor6")
+	assert.NilError(t, err)
+	err = cache.DeleteDocument(memberDoc, "cursor7")
+	assert.NilError(t, err)
+	err = cache.UpdateCursor("cursor8")
+	assert.NilError(t, err)
+	err = cache.Flush()
+	assert.NilError(t, err)
+
+	expectedPeriodInstance.SetValue("details_number_i", int64(3))
+	expectedPeriodInstance.SetValue("member", []map[string]interface{}{})
+	assertInstance(t, expectedPeriodInstance)
+	assertInstanceNotExists(t, member1Id, "Member")
+	assertCursor(t, "cursor8")
+}
+
 func assertCursor(t *testing.T, cursor string) {
 	expected := gql.NewCursorInstance(doccache.CursorIdValue, cursor)
 	actual, err := cache.GetCursorInstance(doccache.CursorIdValue, gql.CursorSimplifiedType, nil)
@@ -3993,6 +4766,32 @@ func assertDoccacheConfig(t *testing.T, cache *doccache.Doccache, cfg *config.Co
 	tutil.AssertSimplifiedInstance(t, actual, expected)
 }
 
+func assertDocumentVersions(t *testing.T, docId string, expected int) map[interface{}]*gql.SimplifiedBaseInstance {
+	versions, err := client.SearchBaseInstances("docId", []interface{}{docId}, gql.DocumentVersionSimplifiedType.SimplifiedBaseType, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, len(versions), expected)
+	byId := make(map[interface{}]*gql.SimplifiedBaseInstance, len(versions))
+	for _, version := range versions {
+		byId[version.GetValue("id")] = version
+	}
+	return byId
+}
+
+// Adds the companion fields of the asset contents with the specified names to the expected instance,
+// the companion values are derived from the asset stored in each content
+func setAssetCompanions(t *testing.T, expected *gql.SimplifiedInstance, names ...string) {
+	for _, name := range names {
+		asset, err := domain.ParseAsset(expected.GetValue(name).(string))
+		assert.NilError(t, err)
+		for _, field := range domain.GetAssetCompanionFields(name) {
+			expected.SimplifiedType.SetField(field.Name, field)
+		}
+		for companionName, value := range asset.GetCompanionValues(name) {
+			expected.SetValue(companionName, value)
+		}
+	}
+}
+
 func assertInstance(t *testing.T, expected *gql.SimplifiedInstance) {
 	actualType, err := cache.Schema.GetSimplifiedType(expected.SimplifiedType.Name)
 	assert.NilError(t, err)
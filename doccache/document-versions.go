@@ -0,0 +1,78 @@
+package doccache
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
+)
+
+// Indicates whether the previous versions of the documents of the type are kept
+func (m *Doccache) keepsHistory(typeName string) bool {
+	return m.config.HistoryTypes[typeName]
+}
+
+// Generates the mutation that stores the values the document had before being updated as a new
+// DocumentVersion node that references the document, along with the mutation that reverts it, the
+// date is the updated date of the new values. Versions are numbered starting from 1 in the order in
+// which they were replaced
+func (m *Doccache) documentVersionMutation(oldInstance *gql.SimplifiedInstance, chainDoc *domain.ChainDocument, date interface{}) (*gql.Mutation, *gql.Mutation, error) {
+	docId := oldInstance.GetValue(DocumentIdName)
+	versions, err := m.client.SearchBaseInstances(DocumentIdName, []interface{}{docId}, gql.DocumentVersionSimplifiedType.SimplifiedBaseType, []string{"id"})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting versions of document: %v, error: %v", docId, err)
+	}
+	values, err := json.Marshal(nonNilValues(oldInstance.Values))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed encoding values of document: %v, error: %v", docId, err)
+	}
+	versionNum := len(versions) + 1
+	versionValues := map[string]interface{}{
+		"id":       GetDocumentVersionId(docId, versionNum),
+		"docId":    docId,
+		"version":  versionNum,
+		"document": GetEdgeValue(docId),
+		"date":     date,
+		"values":   string(values),
+	}
+	if chainDoc.Block != 0 {
+		versionValues["block"] = chainDoc.Block
+	}
+	log.Infof("Storing version: %v of document: %v", versionNum, docId)
+	version := gql.NewSimplifiedInstance(gql.DocumentVersionSimplifiedType, versionValues)
+	undoMutation, err := version.DeleteMutation("id")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed generating document version undo mutation, error: %v", err)
+	}
+	return version.AddMutation(true), undoMutation, nil
+}
+
+// Returns the fields through which the document versions reference the documents
+func documentVersionReferenceFields() []*gql.ReferenceField {
+	return []*gql.ReferenceField{
+		{
+			TypeName: gql.DocumentVersionSimplifiedType.Name,
+			IdName:   "id",
+			Field:    gql.DocumentVersionSimplifiedType.GetField("document"),
+		},
+	}
+}
+
+// Generates the mutations that delete the versions of the document
+func (m *Doccache) deleteDocumentVersionsMutations(docId interface{}) ([]*gql.Mutation, []*gql.Mutation, error) {
+	versions, err := m.client.SearchBaseInstances(DocumentIdName, []interface{}{docId}, gql.DocumentVersionSimplifiedType.SimplifiedBaseType, []string{"id"})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting versions of document: %v, error: %v", docId, err)
+	}
+	ids := make([]interface{}, 0, len(versions))
+	for _, version := range versions {
+		ids = append(ids, version.GetValue("id"))
+	}
+	return m.deleteOwnedMutations(ids, gql.DocumentVersionSimplifiedType)
+}
+
+// Returns the id of the document version
+func GetDocumentVersionId(docId interface{}, version int) string {
+	return fmt.Sprintf("%v-v%v", docId, version)
+}
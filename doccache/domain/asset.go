@@ -0,0 +1,100 @@
+package domain
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
+)
+
+// Suffixes of the companion fields stored along with each asset content, so that assets can be
+// sorted and filtered by amount, i.e. for details_annualUsdSalary_a with value "190000.00 USD"
+// details_annualUsdSalary_a_amount: 190000, details_annualUsdSalary_a_symbol: "USD",
+// details_annualUsdSalary_a_precision: 2 and details_annualUsdSalary_a_fullAmount: 19000000 are stored
+const (
+	AssetSuffix_Amount     = "amount"
+	AssetSuffix_Symbol     = "symbol"
+	AssetSuffix_Precision  = "precision"
+	AssetSuffix_FullAmount = "fullAmount"
+)
+
+// Represents an on chain asset, i.e. "190000.00 USD", the full amount is the amount as it is
+// stored on chain, an integer from which the amount is obtained using the precision
+type Asset struct {
+	Amount     float64
+	Symbol     string
+	Precision  int64
+	FullAmount int64
+}
+
+// Parses an on chain asset, the precision is the number of decimals of the amount
+func ParseAsset(value string) (*Asset, error) {
+	parts := strings.Fields(value)
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid asset: %v, expected format: <amount> <symbol>", value)
+	}
+	amount, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset amount: %v, error: %v", parts[0], err)
+	}
+	var precision int64
+	if pos := strings.Index(parts[0], "."); pos >= 0 {
+		precision = int64(len(parts[0]) - pos - 1)
+	}
+	fullAmount, err := strconv.ParseInt(strings.Replace(parts[0], ".", "", 1), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid asset amount: %v, error: %v", parts[0], err)
+	}
+	return &Asset{
+		Amount:     amount,
+		Symbol:     parts[1],
+		Precision:  precision,
+		FullAmount: fullAmount,
+	}, nil
+}
+
+// Returns the values of the companion fields of the asset content with the name
+func (m *Asset) GetCompanionValues(name string) map[string]interface{} {
+	return map[string]interface{}{
+		GetAssetCompanionName(name, AssetSuffix_Amount):     m.Amount,
+		GetAssetCompanionName(name, AssetSuffix_Symbol):     m.Symbol,
+		GetAssetCompanionName(name, AssetSuffix_Precision):  m.Precision,
+		GetAssetCompanionName(name, AssetSuffix_FullAmount): m.FullAmount,
+	}
+}
+
+// Returns the companion fields of the asset content with the name
+func GetAssetCompanionFields(name string) []*gql.SimplifiedField {
+	return []*gql.SimplifiedField{
+		{
+			Name:    GetAssetCompanionName(name, AssetSuffix_Amount),
+			Type:    gql.GQLType_Float,
+			Indexes: gql.NewIndexes("float"),
+		},
+		{
+			Name:    GetAssetCompanionName(name, AssetSuffix_Symbol),
+			Type:    gql.GQLType_String,
+			Indexes: gql.NewIndexes("exact"),
+		},
+		{
+			Name:    GetAssetCompanionName(name, AssetSuffix_Precision),
+			Type:    gql.GQLType_Int64,
+			Indexes: gql.NewIndexes("int64"),
+		},
+		{
+			Name:    GetAssetCompanionName(name, AssetSuffix_FullAmount),
+			Type:    gql.GQLType_Int64,
+			Indexes: gql.NewIndexes("int64"),
+		},
+	}
+}
+
+// Generates the name of a companion field of the asset content with the name
+func GetAssetCompanionName(name, suffix string) string {
+	return fmt.Sprintf("%v_%v", name, suffix)
+}
+
+func (m *Asset) String() string {
+	return fmt.Sprintf("Asset{Amount: %v, Symbol: %v, Precision: %v, FullAmount: %v}", m.Amount, m.Symbol, m.Precision, m.FullAmount)
+}
@@ -0,0 +1,48 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
+	"gotest.tools/assert"
+)
+
+func TestParseAsset(t *testing.T) {
+	asset, err := domain.ParseAsset("190000.00 USD")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, asset, &domain.Asset{
+		Amount:     190000,
+		Symbol:     "USD",
+		Precision:  2,
+		FullAmount: 19000000,
+	})
+	assert.DeepEqual(t, asset.GetCompanionValues("details_annualUsdSalary_a"), map[string]interface{}{
+		"details_annualUsdSalary_a_amount":     float64(190000),
+		"details_annualUsdSalary_a_symbol":     "USD",
+		"details_annualUsdSalary_a_precision":  int64(2),
+		"details_annualUsdSalary_a_fullAmount": int64(19000000),
+	})
+
+	asset, err = domain.ParseAsset("-12 SEEDS")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, asset, &domain.Asset{
+		Amount:     -12,
+		Symbol:     "SEEDS",
+		Precision:  0,
+		FullAmount: -12,
+	})
+
+	asset, err = domain.ParseAsset("0.0100 HVOICE")
+	assert.NilError(t, err)
+	assert.DeepEqual(t, asset, &domain.Asset{
+		Amount:     0.01,
+		Symbol:     "HVOICE",
+		Precision:  4,
+		FullAmount: 100,
+	})
+
+	_, err = domain.ParseAsset("190000.00")
+	assert.ErrorContains(t, err, "invalid asset")
+	_, err = domain.ParseAsset("1a.00 USD")
+	assert.ErrorContains(t, err, "invalid asset amount")
+}
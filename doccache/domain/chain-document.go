@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/iancoleman/strcase"
 	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
@@ -60,6 +61,9 @@ const CoreEdgeSuffix = "edge"
 type ParsedDoc struct {
 	Instance       *gql.SimplifiedInstance
 	ChecksumFields []string
+	// The asset contents whose companion fields were skipped because the asset could not be parsed,
+	// along with the parsing error
+	SkippedCompanions map[string]error
 }
 
 // Gets the value for the specified document property
@@ -67,6 +71,17 @@ func (m *ParsedDoc) GetValue(name string) interface{} {
 	return m.Instance.GetValue(name)
 }
 
+// Returns the hash of the document, empty if the document does not have one
+func (m *ParsedDoc) GetHash() string {
+	hash, _ := m.GetValue("hash").(string)
+	return hash
+}
+
+// Returns the hash the checksum field points to
+func (m *ParsedDoc) GetChecksum(checksumField string) string {
+	return m.GetValue(checksumField).(string)
+}
+
 func (m *ParsedDoc) HasCoreEdges() bool {
 	return m.NumCoreEdges() > 0
 }
@@ -123,18 +138,60 @@ func (m *ChainContent) GetGQLValue() (interface{}, error) {
 	}
 }
 
+// Returns the fields derived from the content that are stored along with it and their values,
+// only asset contents have companion fields, returns an error if the asset can not be parsed
+func (m *ChainContent) GetCompanionValues(name string) ([]*gql.SimplifiedField, map[string]interface{}, error) {
+	if m.GetType() != ContentType_Asset {
+		return nil, nil, nil
+	}
+	asset, err := ParseAsset(m.GetValue())
+	if err != nil {
+		return nil, nil, err
+	}
+	return GetAssetCompanionFields(name), asset.GetCompanionValues(name), nil
+}
+
 func (m *ChainContent) String() string {
 	return fmt.Sprintf("ChainContent{Label: %v, Value: %v}", m.Label, m.Value)
 }
 
+// Represents a certification of an on chain document
+type ChainCertificate struct {
+	Certifier         string `json:"certifier"`
+	Notes             string `json:"notes"`
+	CertificationDate string `json:"certification_date"`
+}
+
+// Returns the certificate values as they are going to be stored in the db
+func (m *ChainCertificate) GetValues(id string) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                id,
+		"certifier":         m.Certifier,
+		"notes":             m.Notes,
+		"certificationDate": FormatDateTime(m.CertificationDate),
+	}
+}
+
+func (m *ChainCertificate) String() string {
+	return fmt.Sprintf("ChainCertificate{Certifier: %v, Notes: %v, CertificationDate: %v}", m.Certifier, m.Notes, m.CertificationDate)
+}
+
 // Represents an on chain document
 type ChainDocument struct {
-	ID            uint64            `json:"id"`
-	CreatedDate   string            `json:"created_date,omitempty"`
-	UpdatedDate   string            `json:"updated_date,omitempty"`
-	Creator       string            `json:"creator,omitempty"`
-	Contract      string            `json:"contract,omitempty"`
-	ContentGroups [][]*ChainContent `json:"content_groups,omitempty"`
+	ID            uint64              `json:"id"`
+	Hash          string              `json:"hash,omitempty"`
+	CreatedDate   string              `json:"created_date,omitempty"`
+	UpdatedDate   string              `json:"updated_date,omitempty"`
+	Creator       string              `json:"creator,omitempty"`
+	Contract      string              `json:"contract,omitempty"`
+	ContentGroups [][]*ChainContent   `json:"content_groups,omitempty"`
+	Certificates  []*ChainCertificate `json:"certificates,omitempty"`
+	// Block in which the document was stored or deleted, 0 if unknown
+	Block uint64 `json:"-"`
+	// Time of the block in which the document was stored or deleted, zero if unknown
+	BlockTime time.Time `json:"-"`
+	// Transaction that stored or deleted the document, empty if unknown
+	TrxId string `json:"-"`
 }
 
 // Returns the document Id
@@ -145,7 +202,7 @@ func (m *ChainDocument) GetDocId() string {
 // Transforms an on chain document into a struct that better resembles the format as its going to be
 // stored in the db, the typeMappings is used to try to determine the type of an object based on
 // its fields in case it does not have the type property
-func (m *ChainDocument) ToParsedDoc(typeMappings map[string][]string) (*ParsedDoc, error) {
+func (m *ChainDocument) ToParsedDoc(typeMappings *TypeMappings) (*ParsedDoc, error) {
 
 	fields := make(map[string]*gql.SimplifiedField)
 	checksumFields := make([]string, 0)
@@ -161,12 +218,29 @@ func (m *ChainDocument) ToParsedDoc(typeMappings map[string][]string) (*ParsedDo
 		"updatedDate": updatedDate,
 		"contract":    m.Contract,
 	}
+	if m.Hash != "" {
+		values["hash"] = m.Hash
+	}
+	for name, value := range m.GetBlockValues() {
+		values[name] = value
+	}
+	if len(m.Certificates) > 0 {
+		certificates := make([]map[string]interface{}, 0, len(m.Certificates))
+		for i, certificate := range m.Certificates {
+			certificates = append(certificates, certificate.GetValues(GetCertificateId(m.GetDocId(), i)))
+		}
+		values["certificates"] = certificates
+	}
 
+	companionValues := make(map[string]interface{})
+	var skippedCompanions map[string]error
+	contentGroupLabels := make(map[string]bool, len(m.ContentGroups))
 	for i, contentGroup := range m.ContentGroups {
 		contentGroupLabel, err := GetContentGroupLabel(contentGroup)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get content_group_label for content group: %v in document with ID: %v, err: %v", i, m.ID, err)
 		}
+		contentGroupLabels[contentGroupLabel] = true
 		prefix := GetFieldPrefix(contentGroupLabel)
 		for _, content := range contentGroup {
 			if content.Label != CGL_ContentGroup {
@@ -184,17 +258,38 @@ func (m *ChainDocument) ToParsedDoc(typeMappings map[string][]string) (*ParsedDo
 					return nil, fmt.Errorf("failed to get gql value content: %v name for doc with ID: %v, error: %v", name, m.ID, err)
 				}
 				values[name] = value
+				//Assets that can not be parsed have no companion fields so that the document can still be stored
+				companionFields, companions, err := content.GetCompanionValues(name)
+				if err != nil {
+					if skippedCompanions == nil {
+						skippedCompanions = make(map[string]error)
+					}
+					skippedCompanions[name] = err
+				}
+				for _, companionField := range companionFields {
+					fields[companionField.Name] = companionField
+				}
+				for companionName, companionValue := range companions {
+					companionValues[companionName] = companionValue
+				}
 			}
 		}
 	}
 	typeName, ok := values[CL_type].(string)
 	if !ok {
-		typeName = deduceDocType(toUntypedMap(fields), typeMappings)
+		var err error
+		typeName, err = typeMappings.Deduce(toUntypedValues(values), contentGroupLabels)
+		if err != nil {
+			return nil, fmt.Errorf("failed to deduce type of document with ID: %v, error: %v", m.ID, err)
+		}
 		if typeName == "" {
 			return nil, fmt.Errorf("document with ID: %v does not have a type, and couldn't deduce from typeMappings", m.ID)
 		}
 	}
 
+	for name, value := range companionValues {
+		values[name] = value
+	}
 	typeName = GetObjectTypeName(typeName)
 	delete(values, CL_type)
 	delete(fields, CL_type)
@@ -204,11 +299,35 @@ func (m *ChainDocument) ToParsedDoc(typeMappings map[string][]string) (*ParsedDo
 		values,
 	)
 	return &ParsedDoc{
-		Instance:       instance,
-		ChecksumFields: checksumFields,
+		Instance:          instance,
+		ChecksumFields:    checksumFields,
+		SkippedCompanions: skippedCompanions,
 	}, nil
 }
 
+// Returns the values that record the block and transaction of the change that are known, the
+// document is assumed to have been created in the block, the doccache keeps the created block
+// of existing documents
+func (m *ChainDocument) GetBlockValues() map[string]interface{} {
+	values := make(map[string]interface{})
+	if m.Block != 0 {
+		values["createdBlock"] = m.Block
+		values["updatedBlock"] = m.Block
+	}
+	if m.TrxId != "" {
+		values["updatedTrxId"] = m.TrxId
+	}
+	if !m.BlockTime.IsZero() {
+		values["updatedBlockTime"] = m.BlockTime.UTC().Format(time.RFC3339)
+	}
+	return values
+}
+
+// Generates the id of a certificate based on the document id and the position of the certificate
+func GetCertificateId(docId string, position int) string {
+	return fmt.Sprintf("%v-%v", docId, position)
+}
+
 func GetFieldPrefix(contentGroupLabel string) string {
 	return fmt.Sprintf("%v", strcase.ToLowerCamel(contentGroupLabel))
 }
@@ -267,26 +386,10 @@ func GetContentGroupLabel(contents []*ChainContent) (string, error) {
 	return contentGroupLabel.GetValue(), nil
 }
 
-// Tries to determine the type of a document based on its fields
-func deduceDocType(contentMap map[string]*gql.SimplifiedField, typeMappings map[string][]string) string {
-	for typeName, labels := range typeMappings {
-		if containsLabels(contentMap, labels) {
-			return typeName
-		}
-	}
-	return ""
-}
-func containsLabels(contentMap map[string]*gql.SimplifiedField, labels []string) bool {
-	for _, label := range labels {
-		if _, ok := contentMap[label]; !ok {
-			return false
-		}
-	}
-	return true
-}
-
-func toUntypedMap(typed map[string]*gql.SimplifiedField) map[string]*gql.SimplifiedField {
-	untyped := make(map[string]*gql.SimplifiedField, len(typed))
+// Removes the type suffix from the names of the values, the names without it are the content labels
+// prefixed by their content group used by the type mappings
+func toUntypedValues(typed map[string]interface{}) map[string]interface{} {
+	untyped := make(map[string]interface{}, len(typed))
 	for label, value := range typed {
 		pos := strings.LastIndex(label, "_")
 		untypedLabel := label
@@ -299,7 +402,7 @@ func toUntypedMap(typed map[string]*gql.SimplifiedField) map[string]*gql.Simplif
 }
 
 func (m *ChainDocument) String() string {
-	return fmt.Sprintf("ChainDocument{ID: %v, CreatedDate: %v, UpdatedDate: %v, Creator: %v, Contents: %v}", m.ID, m.CreatedDate, m.UpdatedDate, m.Creator, m.ContentGroups)
+	return fmt.Sprintf("ChainDocument{ID: %v, Hash: %v, CreatedDate: %v, UpdatedDate: %v, Creator: %v, Contents: %v, Certificates: %v}", m.ID, m.Hash, m.CreatedDate, m.UpdatedDate, m.Creator, m.ContentGroups, m.Certificates)
 }
 
 func FormatDateTime(datetime string) string {
@@ -3,6 +3,7 @@ package domain_test
 import (
 	"encoding/json"
 	"testing"
+	"time"
 
 	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
 	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
@@ -90,7 +91,7 @@ func TestToParsedDoc(t *testing.T) {
 			},
 		},
 	}
-	parsedDoc, err := chainDoc1.ToParsedDoc(make(map[string][]string))
+	parsedDoc, err := chainDoc1.ToParsedDoc(nil)
 	assert.NilError(t, err)
 
 	expectedSimplifiedInstance := gql.NewSimplifiedInstance(
@@ -112,6 +113,26 @@ func TestToParsedDoc(t *testing.T) {
 					Type:    "String",
 					Indexes: gql.NewIndexes("term"),
 				},
+				"details_hvoiceSalaryPerPhase_a_amount": {
+					Name:    "details_hvoiceSalaryPerPhase_a_amount",
+					Type:    gql.GQLType_Float,
+					Indexes: gql.NewIndexes("float"),
+				},
+				"details_hvoiceSalaryPerPhase_a_symbol": {
+					Name:    "details_hvoiceSalaryPerPhase_a_symbol",
+					Type:    "String",
+					Indexes: gql.NewIndexes("exact"),
+				},
+				"details_hvoiceSalaryPerPhase_a_precision": {
+					Name:    "details_hvoiceSalaryPerPhase_a_precision",
+					Type:    "Int64",
+					Indexes: gql.NewIndexes("int64"),
+				},
+				"details_hvoiceSalaryPerPhase_a_fullAmount": {
+					Name:    "details_hvoiceSalaryPerPhase_a_fullAmount",
+					Type:    "Int64",
+					Indexes: gql.NewIndexes("int64"),
+				},
 				"details_timeShareX100_i": {
 					Name:    "details_timeShareX100_i",
 					Type:    "Int64",
@@ -131,18 +152,22 @@ func TestToParsedDoc(t *testing.T) {
 			gql.DocumentSimplifiedInterface,
 		),
 		map[string]interface{}{
-			"docId":                          "0",
-			"createdDate":                    "2020-11-12T18:27:47.000Z",
-			"updatedDate":                    "2020-11-12T20:37:47.000Z",
-			"creator":                        "dao.hypha",
-			"contract":                       "contract1",
-			"type":                           "Dho",
-			"details_rootNode_n":             "dao.hypha",
-			"details_role_c":                 "b7cf9e60a6c33e79b32c2eeb4575857f3f2c4166e737c6b3863da62a2cfcf1cf",
-			"details_hvoiceSalaryPerPhase_a": "4133.04 HVOICE",
-			"details_timeShareX100_i":        int64(-1032100),
-			"system_originalApprovedDate_t":  "2021-04-12T05:09:36.5Z",
-			"system_period_c":                "f7cf9e60a6c33e79b32c2eeb4575857f3f2c4166e737c6b3863da62a2cfcf1cf",
+			"docId":                                 "0",
+			"createdDate":                           "2020-11-12T18:27:47.000Z",
+			"updatedDate":                           "2020-11-12T20:37:47.000Z",
+			"creator":                               "dao.hypha",
+			"contract":                              "contract1",
+			"type":                                  "Dho",
+			"details_rootNode_n":                    "dao.hypha",
+			"details_role_c":                        "b7cf9e60a6c33e79b32c2eeb4575857f3f2c4166e737c6b3863da62a2cfcf1cf",
+			"details_hvoiceSalaryPerPhase_a":        "4133.04 HVOICE",
+			"details_hvoiceSalaryPerPhase_a_amount": 4133.04,
+			"details_hvoiceSalaryPerPhase_a_symbol": "HVOICE",
+			"details_hvoiceSalaryPerPhase_a_precision":  int64(2),
+			"details_hvoiceSalaryPerPhase_a_fullAmount": int64(413304),
+			"details_timeShareX100_i":                   int64(-1032100),
+			"system_originalApprovedDate_t":             "2021-04-12T05:09:36.5Z",
+			"system_period_c":                           "f7cf9e60a6c33e79b32c2eeb4575857f3f2c4166e737c6b3863da62a2cfcf1cf",
 		},
 	)
 
@@ -259,12 +284,7 @@ func TestToParsedDocDeduceType(t *testing.T) {
 			},
 		},
 	}
-	typeMappings := map[string][]string{
-		"VoteTally": {
-			"pass_votePower",
-			"fail_votePower",
-		},
-	}
+	typeMappings := getVoteTallyTypeMappings(t)
 	parsedDoc, err := chainDoc1.ToParsedDoc(typeMappings)
 	assert.NilError(t, err)
 
@@ -277,23 +297,175 @@ func TestToParsedDocDeduceType(t *testing.T) {
 					Type:    "String",
 					Indexes: gql.NewIndexes("term"),
 				},
+				"pass_votePower_a_amount": {
+					Name:    "pass_votePower_a_amount",
+					Type:    gql.GQLType_Float,
+					Indexes: gql.NewIndexes("float"),
+				},
+				"pass_votePower_a_symbol": {
+					Name:    "pass_votePower_a_symbol",
+					Type:    "String",
+					Indexes: gql.NewIndexes("exact"),
+				},
+				"pass_votePower_a_precision": {
+					Name:    "pass_votePower_a_precision",
+					Type:    "Int64",
+					Indexes: gql.NewIndexes("int64"),
+				},
+				"pass_votePower_a_fullAmount": {
+					Name:    "pass_votePower_a_fullAmount",
+					Type:    "Int64",
+					Indexes: gql.NewIndexes("int64"),
+				},
 				"fail_votePower_a": {
 					Name:    "fail_votePower_a",
 					Type:    "String",
 					Indexes: gql.NewIndexes("term"),
 				},
+				"fail_votePower_a_amount": {
+					Name:    "fail_votePower_a_amount",
+					Type:    gql.GQLType_Float,
+					Indexes: gql.NewIndexes("float"),
+				},
+				"fail_votePower_a_symbol": {
+					Name:    "fail_votePower_a_symbol",
+					Type:    "String",
+					Indexes: gql.NewIndexes("exact"),
+				},
+				"fail_votePower_a_precision": {
+					Name:    "fail_votePower_a_precision",
+					Type:    "Int64",
+					Indexes: gql.NewIndexes("int64"),
+				},
+				"fail_votePower_a_fullAmount": {
+					Name:    "fail_votePower_a_fullAmount",
+					Type:    "Int64",
+					Indexes: gql.NewIndexes("int64"),
+				},
+			},
+			gql.DocumentSimplifiedInterface,
+		),
+		map[string]interface{}{
+			"docId":                       "0",
+			"createdDate":                 "2020-11-12T18:27:47.000Z",
+			"updatedDate":                 "2020-11-12T20:37:47.000Z",
+			"creator":                     "dao.hypha",
+			"contract":                    "contract1",
+			"type":                        "VoteTally",
+			"pass_votePower_a":            "0.00 HVOICE",
+			"pass_votePower_a_amount":     0.0,
+			"pass_votePower_a_symbol":     "HVOICE",
+			"pass_votePower_a_precision":  int64(2),
+			"pass_votePower_a_fullAmount": int64(0),
+			"fail_votePower_a":            "0.00 HVOICE",
+			"fail_votePower_a_amount":     0.0,
+			"fail_votePower_a_symbol":     "HVOICE",
+			"fail_votePower_a_precision":  int64(2),
+			"fail_votePower_a_fullAmount": int64(0),
+		},
+	)
+
+	expectedParsedDoc := &domain.ParsedDoc{
+		Instance:       expectedSimplifiedInstance,
+		ChecksumFields: []string{},
+	}
+
+	assertParsedDoc(t, parsedDoc, expectedParsedDoc)
+}
+
+func TestToParsedDocWithHashAndCertificates(t *testing.T) {
+
+	chainDoc1 := &domain.ChainDocument{
+		ID:          3,
+		Hash:        "4190fc69b4f88f23ae45828a2df64f79bd687a3cdba8c84fa5a89ce9b88de8ff",
+		CreatedDate: "2020-11-12T18:27:47.000",
+		Creator:     "dao.hypha",
+		Contract:    "contract1",
+		ContentGroups: [][]*domain.ChainContent{
+			{
+				{
+					Label: "content_group_label",
+					Value: []interface{}{
+						"string",
+						"details",
+					},
+				},
+				{
+					Label: "member",
+					Value: []interface{}{
+						"name",
+						"1onefiftyfor",
+					},
+				},
+			},
+			{
+				{
+					Label: "content_group_label",
+					Value: []interface{}{
+						"string",
+						"system",
+					},
+				},
+				{
+					Label: "type",
+					Value: []interface{}{
+						"name",
+						"member",
+					},
+				},
+			},
+		},
+		Certificates: []*domain.ChainCertificate{
+			{
+				Certifier:         "sebastian",
+				Notes:             "Sebastian's Notes",
+				CertificationDate: "2020-11-12T20:27:47.000",
+			},
+			{
+				Certifier:         "dao.hypha",
+				Notes:             "",
+				CertificationDate: "2020-11-13T20:27:47.000",
+			},
+		},
+	}
+	parsedDoc, err := chainDoc1.ToParsedDoc(nil)
+	assert.NilError(t, err)
+
+	expectedSimplifiedInstance := gql.NewSimplifiedInstance(
+		gql.NewSimplifiedType(
+			"Member",
+			map[string]*gql.SimplifiedField{
+				"details_member_n": {
+					Name:    "details_member_n",
+					Type:    "String",
+					Indexes: gql.NewIndexes("exact", "regexp"),
+				},
 			},
 			gql.DocumentSimplifiedInterface,
 		),
 		map[string]interface{}{
-			"docId":            "0",
+			"docId":            "3",
+			"hash":             "4190fc69b4f88f23ae45828a2df64f79bd687a3cdba8c84fa5a89ce9b88de8ff",
 			"createdDate":      "2020-11-12T18:27:47.000Z",
-			"updatedDate":      "2020-11-12T20:37:47.000Z",
+			"updatedDate":      "2020-11-12T18:27:47.000Z",
 			"creator":          "dao.hypha",
 			"contract":         "contract1",
-			"type":             "VoteTally",
-			"pass_votePower_a": "0.00 HVOICE",
-			"fail_votePower_a": "0.00 HVOICE",
+			"type":             "Member",
+			"details_member_n": "1onefiftyfor",
+			"certificates": []map[string]interface{}{
+				{
+					"id":                "3-0",
+					"certifier":         "sebastian",
+					"notes":             "Sebastian's Notes",
+					"certificationDate": "2020-11-12T20:27:47.000Z",
+				},
+				{
+					"id":                "3-1",
+					"certifier":         "dao.hypha",
+					"notes":             "",
+					"certificationDate": "2020-11-13T20:27:47.000Z",
+				},
+			},
 		},
 	)
 
@@ -303,6 +475,7 @@ func TestToParsedDocDeduceType(t *testing.T) {
 	}
 
 	assertParsedDoc(t, parsedDoc, expectedParsedDoc)
+	assert.Equal(t, parsedDoc.GetHash(), "4190fc69b4f88f23ae45828a2df64f79bd687a3cdba8c84fa5a89ce9b88de8ff")
 }
 
 func TestToParsedDocDeduceTypeFailsForMissingFields(t *testing.T) {
@@ -334,12 +507,7 @@ func TestToParsedDocDeduceTypeFailsForMissingFields(t *testing.T) {
 			},
 		},
 	}
-	typeMappings := map[string][]string{
-		"VoteTally": {
-			"pass_votePower",
-			"fail_votePower",
-		},
-	}
+	typeMappings := getVoteTallyTypeMappings(t)
 	_, err := chainDoc1.ToParsedDoc(typeMappings)
 	assert.ErrorContains(t, err, "document with ID: 0 does not have a type, and couldn't deduce from typeMappings")
 
@@ -390,12 +558,7 @@ func TestToParsedDocDotNamedType(t *testing.T) {
 			},
 		},
 	}
-	typeMappings := map[string][]string{
-		"VoteTally": {
-			"pass_votePower",
-			"fail_votePower",
-		},
-	}
+	typeMappings := getVoteTallyTypeMappings(t)
 	parsedDoc, err := chainDoc1.ToParsedDoc(typeMappings)
 	assert.NilError(t, err)
 
@@ -408,17 +571,41 @@ func TestToParsedDocDotNamedType(t *testing.T) {
 					Type:    "String",
 					Indexes: gql.NewIndexes("term"),
 				},
+				"fail_votePower_a_amount": {
+					Name:    "fail_votePower_a_amount",
+					Type:    gql.GQLType_Float,
+					Indexes: gql.NewIndexes("float"),
+				},
+				"fail_votePower_a_symbol": {
+					Name:    "fail_votePower_a_symbol",
+					Type:    "String",
+					Indexes: gql.NewIndexes("exact"),
+				},
+				"fail_votePower_a_precision": {
+					Name:    "fail_votePower_a_precision",
+					Type:    "Int64",
+					Indexes: gql.NewIndexes("int64"),
+				},
+				"fail_votePower_a_fullAmount": {
+					Name:    "fail_votePower_a_fullAmount",
+					Type:    "Int64",
+					Indexes: gql.NewIndexes("int64"),
+				},
 			},
 			gql.DocumentSimplifiedInterface,
 		),
 		map[string]interface{}{
-			"docId":            "0",
-			"createdDate":      "2020-11-12T18:27:47.000Z",
-			"updatedDate":      "2020-11-12T20:37:47.000Z",
-			"creator":          "dao.hypha",
-			"contract":         "contract1",
-			"type":             "VoteTally",
-			"fail_votePower_a": "0.00 HVOICE",
+			"docId":                       "0",
+			"createdDate":                 "2020-11-12T18:27:47.000Z",
+			"updatedDate":                 "2020-11-12T20:37:47.000Z",
+			"creator":                     "dao.hypha",
+			"contract":                    "contract1",
+			"type":                        "VoteTally",
+			"fail_votePower_a":            "0.00 HVOICE",
+			"fail_votePower_a_amount":     0.0,
+			"fail_votePower_a_symbol":     "HVOICE",
+			"fail_votePower_a_precision":  int64(2),
+			"fail_votePower_a_fullAmount": int64(0),
 		},
 	)
 
@@ -459,7 +646,7 @@ func TestToParsedDocShouldFailForNoContentGroupLabel(t *testing.T) {
 			},
 		},
 	}
-	_, err := chainDoc1.ToParsedDoc(make(map[string][]string))
+	_, err := chainDoc1.ToParsedDoc(nil)
 	assert.ErrorContains(t, err, "failed to get content_group_label for content group: 0 in document with ID: 0, err: content group not found")
 
 }
@@ -493,7 +680,7 @@ func TestToParsedDocShouldFailForInvalidInt(t *testing.T) {
 			},
 		},
 	}
-	_, err := chainDoc1.ToParsedDoc(make(map[string][]string))
+	_, err := chainDoc1.ToParsedDoc(nil)
 	assert.ErrorContains(t, err, "failed to parse content value to float64 before casting to int64")
 }
 
@@ -526,15 +713,10 @@ func TestToParsedDocShouldFailForNoType(t *testing.T) {
 			},
 		},
 	}
-	_, err := chainDoc1.ToParsedDoc(make(map[string][]string))
+	_, err := chainDoc1.ToParsedDoc(nil)
 	assert.ErrorContains(t, err, "document with ID: 0 does not have a type, and couldn't deduce from typeMappings")
 
-	typeMappings := map[string][]string{
-		"VoteTally": {
-			"pass_votePower",
-			"fail_votePower",
-		},
-	}
+	typeMappings := getVoteTallyTypeMappings(t)
 	_, err = chainDoc1.ToParsedDoc(typeMappings)
 	assert.ErrorContains(t, err, "document with ID: 0 does not have a type, and couldn't deduce from typeMappings")
 }
@@ -558,14 +740,110 @@ func TestChainDocUnmarshallAndParse(t *testing.T) {
 	assert.Equal(t, chainDoc.GetDocId(), "18446744073709000000")
 	assert.Equal(t, chainDoc.ID, uint64(18446744073709000000))
 
-	doc, err := chainDoc.ToParsedDoc(make(map[string][]string))
+	doc, err := chainDoc.ToParsedDoc(nil)
 	assert.NilError(t, err)
 
 	assert.Equal(t, doc.Instance.GetValue("docId"), "18446744073709000000")
+	assert.Equal(t, doc.GetHash(), "c5d76231f3193edfa4ab23214a00384d523c363757bdf9634da3c92b6c3a948f")
 
 }
 
+func TestToParsedDocBlockValues(t *testing.T) {
+	chainDoc := &domain.ChainDocument{
+		ID:          1,
+		CreatedDate: "2020-11-12T18:27:47.000",
+		Creator:     "dao.hypha",
+		Contract:    "dao.hypha",
+		ContentGroups: [][]*domain.ChainContent{
+			{
+				{
+					Label: "content_group_label",
+					Value: []interface{}{"name", "system"},
+				},
+				{
+					Label: "type",
+					Value: []interface{}{"name", "period"},
+				},
+			},
+		},
+	}
+	assert.DeepEqual(t, chainDoc.GetBlockValues(), map[string]interface{}{})
+	doc, err := chainDoc.ToParsedDoc(nil)
+	assert.NilError(t, err)
+	assert.Assert(t, doc.Instance.GetValue("updatedBlock") == nil)
+
+	chainDoc.Block = 88665100
+	chainDoc.BlockTime = time.Date(2020, 11, 13, 19, 27, 47, 500000000, time.UTC)
+	chainDoc.TrxId = "trx1"
+	assert.DeepEqual(t, chainDoc.GetBlockValues(), map[string]interface{}{
+		"createdBlock":     uint64(88665100),
+		"updatedBlock":     uint64(88665100),
+		"updatedTrxId":     "trx1",
+		"updatedBlockTime": "2020-11-13T19:27:47Z",
+	})
+	doc, err = chainDoc.ToParsedDoc(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, doc.Instance.GetValue("createdBlock"), int64(88665100))
+	assert.Equal(t, doc.Instance.GetValue("updatedBlock"), int64(88665100))
+	assert.Equal(t, doc.Instance.GetValue("updatedTrxId"), "trx1")
+}
+
 func assertParsedDoc(t *testing.T, actual, expected *domain.ParsedDoc) {
 	util.AssertSimplifiedInstance(t, actual.Instance, expected.Instance)
 	assert.DeepEqual(t, actual.ChecksumFields, expected.ChecksumFields)
 }
+
+func getVoteTallyTypeMappings(t *testing.T) *domain.TypeMappings {
+	typeMappings, err := domain.NewTypeMappings("")
+	assert.NilError(t, err)
+	err = typeMappings.Add(&domain.TypeMapping{
+		Type: "VoteTally",
+		Conditions: []*domain.TypeMappingCondition{
+			domain.NewLabelCondition("pass_votePower"),
+			domain.NewLabelCondition("fail_votePower"),
+		},
+	})
+	assert.NilError(t, err)
+	return typeMappings
+}
+
+func TestToParsedDocInvalidAsset(t *testing.T) {
+	chainDoc := &domain.ChainDocument{
+		ID:          1,
+		CreatedDate: "2020-11-12T18:27:47.000",
+		Creator:     "dao.hypha",
+		Contract:    "dao.hypha",
+		ContentGroups: [][]*domain.ChainContent{
+			{
+				{
+					Label: "content_group_label",
+					Value: []interface{}{"name", "details"},
+				},
+				{
+					Label: "salary",
+					Value: []interface{}{"asset", "invalid"},
+				},
+			},
+			{
+				{
+					Label: "content_group_label",
+					Value: []interface{}{"name", "system"},
+				},
+				{
+					Label: "type",
+					Value: []interface{}{"name", "period"},
+				},
+			},
+		},
+	}
+	doc, err := chainDoc.ToParsedDoc(nil)
+	assert.NilError(t, err)
+	assert.Equal(t, doc.Instance.GetValue("details_salary_a"), "invalid")
+	assert.Equal(t, len(doc.SkippedCompanions), 1)
+	assert.ErrorContains(t, doc.SkippedCompanions["details_salary_a"], "invalid asset")
+	for _, suffix := range []string{domain.AssetSuffix_Amount, domain.AssetSuffix_Symbol, domain.AssetSuffix_Precision, domain.AssetSuffix_FullAmount} {
+		assert.Assert(t, doc.Instance.GetValue(domain.GetAssetCompanionName("details_salary_a", suffix)) == nil)
+		_, ok := doc.Instance.SimplifiedType.Fields[domain.GetAssetCompanionName("details_salary_a", suffix)]
+		assert.Assert(t, !ok)
+	}
+}
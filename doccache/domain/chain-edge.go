@@ -14,7 +14,14 @@ type ChainEdge struct {
 	Name        string `json:"edge_name,omitempty"`
 	From        string `json:"from_node,omitempty"`
 	To          string `json:"to_node,omitempty"`
-	DocEdgeName string
+	CreatedDate string `json:"created_date,omitempty"`
+	Creator     string `json:"creator,omitempty"`
+	Contract    string `json:"contract,omitempty"`
+	// Block in which the edge was created, 0 if unknown
+	CreatedBlock uint64
+	// Transaction that created the edge, empty if unknown
+	CreatedTrxId string
+	DocEdgeName  string
 }
 
 func NewChainEdge(name, from, to string) *ChainEdge {
@@ -34,16 +41,68 @@ func (m *ChainEdge) UnmarshalJSON(b []byte) error {
 	m.Name = data["edge_name"].(string)
 	m.From = strconv.FormatUint(uint64(data["from_node"].(float64)), 10)
 	m.To = strconv.FormatUint(uint64(data["to_node"].(float64)), 10)
+	m.CreatedDate, _ = data["created_date"].(string)
+	m.Creator, _ = data["creator"].(string)
+	m.Contract, _ = data["contract"].(string)
 	m.DocEdgeName = getDocEdgeName(m.Name)
 	return nil
 }
 
+// Returns the id that identifies the relationship
+func (m *ChainEdge) GetId() string {
+	return fmt.Sprintf("%v-%v-%v", m.From, m.Name, m.To)
+}
+
+// Returns the values of the edge metadata that are known
+func (m *ChainEdge) GetMetadata() map[string]interface{} {
+	metadata := make(map[string]interface{})
+	if m.Creator != "" {
+		metadata["creator"] = m.Creator
+	}
+	if m.Contract != "" {
+		metadata["contract"] = m.Contract
+	}
+	if m.CreatedDate != "" {
+		metadata["createdDate"] = FormatDateTime(m.CreatedDate)
+	}
+	if m.CreatedBlock != 0 {
+		metadata["createdBlock"] = m.CreatedBlock
+	}
+	if m.CreatedTrxId != "" {
+		metadata["createdTrxId"] = m.CreatedTrxId
+	}
+	return metadata
+}
+
+// Sets the edge metadata from the stored values
+func (m *ChainEdge) SetMetadata(values map[string]interface{}) {
+	m.Creator, _ = values["creator"].(string)
+	m.Contract, _ = values["contract"].(string)
+	m.CreatedTrxId, _ = values["createdTrxId"].(string)
+	if createdDate, ok := values["createdDate"].(string); ok {
+		m.CreatedDate = strings.TrimSuffix(createdDate, "Z")
+	}
+	switch createdBlock := values["createdBlock"].(type) {
+	case float64:
+		m.CreatedBlock = uint64(createdBlock)
+	case string:
+		m.CreatedBlock, _ = strconv.ParseUint(createdBlock, 10, 64)
+	case uint64:
+		m.CreatedBlock = createdBlock
+	}
+}
+
 func (m *ChainEdge) GetEdgeRef(docId interface{}) map[string]interface{} {
 	return map[string]interface{}{
 		m.DocEdgeName: []map[string]interface{}{{"docId": docId}},
 	}
 }
 
+// Indicates whether both edges define the same relationship
+func (m *ChainEdge) Equal(edge *ChainEdge) bool {
+	return m.Name == edge.Name && m.From == edge.From && m.To == edge.To
+}
+
 func (m *ChainEdge) String() string {
 	return fmt.Sprintf("ChainEdge{Name: %v, From: %v, To: %v}", m.Name, m.From, m.To)
 }
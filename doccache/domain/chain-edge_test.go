@@ -18,4 +18,37 @@ func TestChainEdgeUnmarshall(t *testing.T) {
 	assert.Equal(t, chainEdge.Name, "settings")
 	assert.Equal(t, chainEdge.From, "1")
 	assert.Equal(t, chainEdge.To, "2")
+	assert.Equal(t, chainEdge.CreatedDate, "2021-01-11T21:52:32")
+	assert.Equal(t, chainEdge.Creator, "dao.hypha")
+	assert.Equal(t, chainEdge.Contract, "dao.hypha")
+}
+
+func TestChainEdgeMetadata(t *testing.T) {
+	chainEdge := domain.NewChainEdge("member", "1", "2")
+	assert.Equal(t, chainEdge.GetId(), "1-member-2")
+	assert.DeepEqual(t, chainEdge.GetMetadata(), map[string]interface{}{})
+
+	chainEdge.Creator = "member1"
+	chainEdge.Contract = "dao.hypha"
+	chainEdge.CreatedDate = "2021-01-11T21:52:32"
+	chainEdge.CreatedBlock = 88665100
+	chainEdge.CreatedTrxId = "trx1"
+	metadata := chainEdge.GetMetadata()
+	assert.DeepEqual(t, metadata, map[string]interface{}{
+		"creator":      "member1",
+		"contract":     "dao.hypha",
+		"createdDate":  "2021-01-11T21:52:32Z",
+		"createdBlock": uint64(88665100),
+		"createdTrxId": "trx1",
+	})
+
+	stored := domain.NewChainEdge("member", "1", "2")
+	stored.SetMetadata(map[string]interface{}{
+		"creator":      "member1",
+		"contract":     "dao.hypha",
+		"createdDate":  "2021-01-11T21:52:32Z",
+		"createdBlock": float64(88665100),
+		"createdTrxId": "trx1",
+	})
+	assert.DeepEqual(t, stored, chainEdge)
 }
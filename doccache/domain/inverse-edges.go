@@ -0,0 +1,32 @@
+package domain
+
+import "fmt"
+
+// Provides the functionality to maintain edges in both directions based on the initial configuration,
+// maps each edge name to the name of its inverse edge
+type InverseEdges map[string]string
+
+func NewInverseEdges() InverseEdges {
+	return make(InverseEdges)
+}
+
+// Declares the edges as inverses of each other, an edge can be its own inverse
+func (m InverseEdges) Set(edgeName, inverseName string) error {
+	for _, pair := range [][]string{{edgeName, inverseName}, {inverseName, edgeName}} {
+		if current, ok := m[pair[0]]; ok && current != pair[1] {
+			return fmt.Errorf("edge: %v can not be the inverse of: %v, it is already the inverse of: %v", pair[0], pair[1], current)
+		}
+	}
+	m[edgeName] = inverseName
+	m[inverseName] = edgeName
+	return nil
+}
+
+// Returns the inverse of the edge, nil if the edge does not have an inverse
+func (m InverseEdges) GetInverse(chainEdge *ChainEdge) *ChainEdge {
+	inverseName, ok := m[chainEdge.Name]
+	if !ok {
+		return nil
+	}
+	return NewChainEdge(inverseName, chainEdge.To, chainEdge.From)
+}
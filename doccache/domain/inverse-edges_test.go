@@ -0,0 +1,27 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
+	"gotest.tools/assert"
+)
+
+func TestInverseEdges(t *testing.T) {
+	inverseEdges := domain.NewInverseEdges()
+	assert.NilError(t, inverseEdges.Set("assigned", "assignee"))
+	assert.NilError(t, inverseEdges.Set("related", "related"))
+	assert.NilError(t, inverseEdges.Set("assignee", "assigned"))
+
+	inverse := inverseEdges.GetInverse(domain.NewChainEdge("assigned", "1", "2"))
+	assert.Assert(t, inverse.Equal(domain.NewChainEdge("assignee", "2", "1")))
+	assert.Equal(t, inverse.DocEdgeName, "assignee")
+	inverse = inverseEdges.GetInverse(domain.NewChainEdge("assignee", "2", "1"))
+	assert.Assert(t, inverse.Equal(domain.NewChainEdge("assigned", "1", "2")))
+	inverse = inverseEdges.GetInverse(domain.NewChainEdge("related", "1", "2"))
+	assert.Assert(t, inverse.Equal(domain.NewChainEdge("related", "2", "1")))
+	assert.Assert(t, inverseEdges.GetInverse(domain.NewChainEdge("member", "1", "2")) == nil)
+
+	err := inverseEdges.Set("assigned", "owner")
+	assert.ErrorContains(t, err, "already the inverse of: assignee")
+}
@@ -0,0 +1,251 @@
+package domain
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// The document has to meet all the conditions of the mapping
+	TypeMappingMatch_All = "all"
+	// The document has to meet at least one of the conditions of the mapping
+	TypeMappingMatch_Any = "any"
+)
+
+const (
+	// Documents that match mappings of different types with the same priority fail to be stored
+	TypeMappingTieBreak_Error = "error"
+	// Documents that match mappings of different types with the same priority get the type of the
+	// mapping defined first
+	TypeMappingTieBreak_First = "first"
+)
+
+// Condition a document has to meet for a type mapping to match it
+type TypeMappingCondition struct {
+	// Label of the content group the document must have, set for content group conditions
+	ContentGroup string
+	// Label of the content the document must have prefixed by its content group, i.e. details_assignment
+	Label string
+	// Value the content must have, only checked if HasValue is true
+	Value    string
+	HasValue bool
+}
+
+// Creates a condition that requires the document to have the content
+func NewLabelCondition(label string) *TypeMappingCondition {
+	return &TypeMappingCondition{
+		Label: label,
+	}
+}
+
+// Creates a condition that requires the document to have the content with the value
+func NewEqualsCondition(label, value string) *TypeMappingCondition {
+	return &TypeMappingCondition{
+		Label:    label,
+		Value:    value,
+		HasValue: true,
+	}
+}
+
+// Creates a condition that requires the document to have the content group
+func NewContentGroupCondition(contentGroup string) *TypeMappingCondition {
+	return &TypeMappingCondition{
+		ContentGroup: contentGroup,
+	}
+}
+
+// Indicates whether the document contents and content groups meet the condition
+func (m *TypeMappingCondition) Matches(contents map[string]interface{}, contentGroups map[string]bool) bool {
+	if m.ContentGroup != "" {
+		return contentGroups[m.ContentGroup]
+	}
+	value, ok := contents[m.Label]
+	if !ok {
+		return false
+	}
+	return !m.HasValue || fmt.Sprint(value) == m.Value
+}
+
+// Indicates whether any document that meets this condition also meets the other condition
+func (m *TypeMappingCondition) Implies(other *TypeMappingCondition) bool {
+	if other.ContentGroup != "" {
+		return m.ContentGroup == other.ContentGroup ||
+			(m.ContentGroup == "" && strings.HasPrefix(m.Label, other.ContentGroup+"_"))
+	}
+	if m.Label != other.Label {
+		return false
+	}
+	return !other.HasValue || (m.HasValue && m.Value == other.Value)
+}
+
+func (m *TypeMappingCondition) String() string {
+	if m.ContentGroup != "" {
+		return fmt.Sprintf("content group: %v", m.ContentGroup)
+	}
+	if m.HasValue {
+		return fmt.Sprintf("%v = %v", m.Label, m.Value)
+	}
+	return m.Label
+}
+
+// Defines the conditions under which a document without a type property is of the type
+type TypeMapping struct {
+	Type       string
+	Priority   int
+	Match      string
+	Conditions []*TypeMappingCondition
+}
+
+// Indicates whether the document contents and content groups match the mapping
+func (m *TypeMapping) Matches(contents map[string]interface{}, contentGroups map[string]bool) bool {
+	return m.meets(func(condition *TypeMappingCondition) bool {
+		return condition.Matches(contents, contentGroups)
+	})
+}
+
+// Indicates whether any document that matches the other mapping also matches this mapping
+func (m *TypeMapping) Covers(other *TypeMapping) bool {
+	if other.Match == TypeMappingMatch_All {
+		return m.meets(func(condition *TypeMappingCondition) bool {
+			for _, otherCondition := range other.Conditions {
+				if otherCondition.Implies(condition) {
+					return true
+				}
+			}
+			return false
+		})
+	}
+	for _, otherCondition := range other.Conditions {
+		if !m.meets(otherCondition.Implies) {
+			return false
+		}
+	}
+	return true
+}
+
+// Indicates whether the conditions of the mapping are met according to its match rule, when the
+// check function is used to determine if each condition is met
+func (m *TypeMapping) meets(check func(condition *TypeMappingCondition) bool) bool {
+	for _, condition := range m.Conditions {
+		met := check(condition)
+		if m.Match == TypeMappingMatch_Any && met {
+			return true
+		}
+		if m.Match == TypeMappingMatch_All && !met {
+			return false
+		}
+	}
+	return m.Match == TypeMappingMatch_All
+}
+
+func (m *TypeMapping) String() string {
+	return fmt.Sprintf("TypeMapping{Type: %v, Priority: %v, Match: %v, Conditions: %v}", m.Type, m.Priority, m.Match, m.Conditions)
+}
+
+// Provides the functionality to deduce the type of the documents that do not have a type property,
+// the mappings are evaluated from the highest to the lowest priority, and in the order in which they
+// were added for the same priority
+type TypeMappings struct {
+	Mappings []*TypeMapping
+	TieBreak string
+}
+
+func NewTypeMappings(tieBreak string) (*TypeMappings, error) {
+	if tieBreak == "" {
+		tieBreak = TypeMappingTieBreak_Error
+	}
+	if tieBreak != TypeMappingTieBreak_Error && tieBreak != TypeMappingTieBreak_First {
+		return nil, fmt.Errorf("invalid type mapping tie break: %v, valid values are: %v, %v", tieBreak, TypeMappingTieBreak_Error, TypeMappingTieBreak_First)
+	}
+	return &TypeMappings{
+		Mappings: make([]*TypeMapping, 0),
+		TieBreak: tieBreak,
+	}, nil
+}
+
+// Adds a mapping, the match rule defaults to all
+func (m *TypeMappings) Add(mapping *TypeMapping) error {
+	if mapping.Type == "" {
+		return fmt.Errorf("type mapping: %v has no type", mapping)
+	}
+	if len(mapping.Conditions) == 0 {
+		return fmt.Errorf("type mapping for type: %v has no conditions", mapping.Type)
+	}
+	if mapping.Match == "" {
+		mapping.Match = TypeMappingMatch_All
+	}
+	if mapping.Match != TypeMappingMatch_All && mapping.Match != TypeMappingMatch_Any {
+		return fmt.Errorf("type mapping for type: %v has invalid match: %v, valid values are: %v, %v", mapping.Type, mapping.Match, TypeMappingMatch_All, TypeMappingMatch_Any)
+	}
+	m.Mappings = append(m.Mappings, mapping)
+	sort.SliceStable(m.Mappings, func(i, j int) bool {
+		return m.Mappings[i].Priority > m.Mappings[j].Priority
+	})
+	return nil
+}
+
+// Returns the number of mappings
+func (m *TypeMappings) Len() int {
+	if m == nil {
+		return 0
+	}
+	return len(m.Mappings)
+}
+
+// Verifies that the mappings of different types with the same priority do not overlap in a way that
+// makes the deduced type ambiguous, or with the first tie break, that makes a mapping unreachable.
+// Only full overlaps are reported, where every document matched by one mapping is also matched by
+// the other, mappings that match some of the same documents, i.e. all{details_a} and all{details_b},
+// are not reported, the documents they both match are handled by Deduce according to the tie break
+func (m *TypeMappings) CheckOverlaps() error {
+	for i, mapping := range m.Mappings {
+		for _, other := range m.Mappings[i+1:] {
+			if mapping.Priority != other.Priority {
+				break
+			}
+			if mapping.Type == other.Type {
+				continue
+			}
+			if mapping.Covers(other) {
+				if m.TieBreak == TypeMappingTieBreak_First {
+					return fmt.Errorf("type mapping: %v is never used, the documents it matches also match the type mapping defined before it: %v", other, mapping)
+				}
+				return fmt.Errorf("type mappings: %v and %v overlap, the documents matched by the second one also match the first one, set different priorities", mapping, other)
+			}
+			if m.TieBreak == TypeMappingTieBreak_Error && other.Covers(mapping) {
+				return fmt.Errorf("type mappings: %v and %v overlap, the documents matched by the first one also match the second one, set different priorities", mapping, other)
+			}
+		}
+	}
+	return nil
+}
+
+// Returns the type of the document with the contents and content groups, an empty string if no
+// mapping matches, contents are keyed by their label prefixed by their content group
+func (m *TypeMappings) Deduce(contents map[string]interface{}, contentGroups map[string]bool) (string, error) {
+	if m == nil {
+		return "", nil
+	}
+	var matched *TypeMapping
+	for _, mapping := range m.Mappings {
+		if matched != nil && (mapping.Priority < matched.Priority || m.TieBreak == TypeMappingTieBreak_First) {
+			break
+		}
+		if !mapping.Matches(contents, contentGroups) {
+			continue
+		}
+		if matched != nil && matched.Type != mapping.Type {
+			return "", fmt.Errorf("ambiguous type, type mappings: %v and %v match with the same priority", matched, mapping)
+		}
+		matched = mapping
+	}
+	if matched == nil {
+		return "", nil
+	}
+	return matched.Type, nil
+}
+
+func (m *TypeMappings) String() string {
+	return fmt.Sprintf("TypeMappings{TieBreak: %v, Mappings: %v}", m.TieBreak, m.Mappings)
+}
@@ -0,0 +1,166 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
+	"gotest.tools/assert"
+)
+
+func TestTypeMappingsDeduce(t *testing.T) {
+	typeMappings, err := domain.NewTypeMappings("")
+	assert.NilError(t, err)
+	assert.NilError(t, typeMappings.Add(&domain.TypeMapping{
+		Type: "Role",
+		Conditions: []*domain.TypeMappingCondition{
+			domain.NewLabelCondition("details_title"),
+		},
+	}))
+	assert.NilError(t, typeMappings.Add(&domain.TypeMapping{
+		Type:     "Assignment",
+		Priority: 10,
+		Conditions: []*domain.TypeMappingCondition{
+			domain.NewLabelCondition("details_title"),
+			domain.NewEqualsCondition("system_kind", "assignment"),
+		},
+	}))
+	assert.NilError(t, typeMappings.Add(&domain.TypeMapping{
+		Type:  "Payout",
+		Match: domain.TypeMappingMatch_Any,
+		Conditions: []*domain.TypeMappingCondition{
+			domain.NewLabelCondition("details_recipient"),
+			domain.NewContentGroupCondition("payout"),
+		},
+	}))
+	assert.NilError(t, typeMappings.CheckOverlaps())
+
+	t.Log("Mapping with higher priority should be chosen")
+	typeName, err := typeMappings.Deduce(map[string]interface{}{
+		"details_title": "Developer",
+		"system_kind":   "assignment",
+	}, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, typeName, "Assignment")
+
+	t.Log("Value should be compared for equals conditions")
+	typeName, err = typeMappings.Deduce(map[string]interface{}{
+		"details_title": "Developer",
+		"system_kind":   "role",
+	}, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, typeName, "Role")
+
+	t.Log("Any condition should be enough for any match rule")
+	typeName, err = typeMappings.Deduce(map[string]interface{}{}, map[string]bool{"payout": true})
+	assert.NilError(t, err)
+	assert.Equal(t, typeName, "Payout")
+
+	t.Log("Mappings of different types with the same priority should be ambiguous")
+	_, err = typeMappings.Deduce(map[string]interface{}{
+		"details_title":     "Developer",
+		"details_recipient": "member1",
+	}, nil)
+	assert.ErrorContains(t, err, "ambiguous type")
+
+	typeName, err = typeMappings.Deduce(map[string]interface{}{"details_name": "name"}, nil)
+	assert.NilError(t, err)
+	assert.Equal(t, typeName, "")
+}
+
+func TestTypeMappingsDeduceFirstTieBreak(t *testing.T) {
+	typeMappings, err := domain.NewTypeMappings(domain.TypeMappingTieBreak_First)
+	assert.NilError(t, err)
+	assert.NilError(t, typeMappings.Add(&domain.TypeMapping{
+		Type: "Role",
+		Conditions: []*domain.TypeMappingCondition{
+			domain.NewLabelCondition("details_title"),
+		},
+	}))
+	assert.NilError(t, typeMappings.Add(&domain.TypeMapping{
+		Type: "Payout",
+		Conditions: []*domain.TypeMappingCondition{
+			domain.NewLabelCondition("details_recipient"),
+		},
+	}))
+	assert.NilError(t, typeMappings.CheckOverlaps())
+	for i := 0; i < 10; i++ {
+		typeName, err := typeMappings.Deduce(map[string]interface{}{
+			"details_title":     "Developer",
+			"details_recipient": "member1",
+		}, nil)
+		assert.NilError(t, err)
+		assert.Equal(t, typeName, "Role")
+	}
+}
+
+func TestTypeMappingsCheckOverlaps(t *testing.T) {
+	role := &domain.TypeMapping{
+		Type: "Role",
+		Conditions: []*domain.TypeMappingCondition{
+			domain.NewLabelCondition("details_title"),
+		},
+	}
+	assignment := &domain.TypeMapping{
+		Type: "Assignment",
+		Conditions: []*domain.TypeMappingCondition{
+			domain.NewEqualsCondition("details_title", "Developer"),
+			domain.NewContentGroupCondition("system"),
+		},
+	}
+	payout := &domain.TypeMapping{
+		Type:  "Payout",
+		Match: domain.TypeMappingMatch_Any,
+		Conditions: []*domain.TypeMappingCondition{
+			domain.NewContentGroupCondition("details"),
+			domain.NewLabelCondition("system_recipient"),
+		},
+	}
+
+	t.Log("Overlapping mappings with the same priority should fail with the error tie break")
+	typeMappings, err := domain.NewTypeMappings(domain.TypeMappingTieBreak_Error)
+	assert.NilError(t, err)
+	assert.NilError(t, typeMappings.Add(assignment))
+	assert.NilError(t, typeMappings.Add(role))
+	assert.ErrorContains(t, typeMappings.CheckOverlaps(), "overlap")
+
+	t.Log("More specific mapping defined first should be valid with the first tie break")
+	typeMappings, err = domain.NewTypeMappings(domain.TypeMappingTieBreak_First)
+	assert.NilError(t, err)
+	assert.NilError(t, typeMappings.Add(assignment))
+	assert.NilError(t, typeMappings.Add(role))
+	assert.NilError(t, typeMappings.CheckOverlaps())
+
+	t.Log("Mapping that is never used should fail with the first tie break")
+	typeMappings, err = domain.NewTypeMappings(domain.TypeMappingTieBreak_First)
+	assert.NilError(t, err)
+	assert.NilError(t, typeMappings.Add(payout))
+	assert.NilError(t, typeMappings.Add(role))
+	assert.ErrorContains(t, typeMappings.CheckOverlaps(), "is never used")
+
+	t.Log("Overlapping mappings with different priorities should be valid")
+	role.Priority = 1
+	typeMappings, err = domain.NewTypeMappings(domain.TypeMappingTieBreak_Error)
+	assert.NilError(t, err)
+	assert.NilError(t, typeMappings.Add(assignment))
+	assert.NilError(t, typeMappings.Add(role))
+	assert.NilError(t, typeMappings.CheckOverlaps())
+}
+
+func TestTypeMappingsShouldFailForInvalidConfiguration(t *testing.T) {
+	_, err := domain.NewTypeMappings("last")
+	assert.ErrorContains(t, err, "invalid type mapping tie break")
+	typeMappings, err := domain.NewTypeMappings("")
+	assert.NilError(t, err)
+	err = typeMappings.Add(&domain.TypeMapping{
+		Type: "Role",
+	})
+	assert.ErrorContains(t, err, "has no conditions")
+	err = typeMappings.Add(&domain.TypeMapping{
+		Type:  "Role",
+		Match: "none",
+		Conditions: []*domain.TypeMappingCondition{
+			domain.NewLabelCondition("details_title"),
+		},
+	})
+	assert.ErrorContains(t, err, "invalid match")
+}
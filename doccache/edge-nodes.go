@@ -0,0 +1,81 @@
+package doccache
+
+import (
+	"fmt"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
+)
+
+// Generates the mutation that stores the edge node, or deletes it for delete operations, along with
+// the mutation that reverts it, returns nil if there is nothing to change. When the edge metadata mode
+// is enabled, each edge is also stored as an Edge node that references the FROM and TO documents and
+// carries the edge metadata: creator, contract, creation date and block
+func (m *Doccache) edgeNodeMutation(chainEdge *domain.ChainEdge, deleteOp bool) (*gql.Mutation, *gql.Mutation, error) {
+	if !deleteOp {
+		edgeNode := newEdgeNode(chainEdge)
+		undoMutation, err := edgeNode.DeleteMutation("id")
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed generating edge node undo mutation, error: %v", err)
+		}
+		return edgeNode.AddMutation(true), undoMutation, nil
+	}
+	id := chainEdge.GetId()
+	if m.batch != nil && m.batch.HasEdgeNode(id) {
+		err := m.commitBatch()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed committing batch, error: %v", err)
+		}
+	}
+	ids := []interface{}{id}
+	mutations, undoMutations, err := m.deleteOwnedMutations(ids, gql.EdgeSimplifiedType)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed generating edge node delete mutation, error: %v", err)
+	}
+	if len(mutations) == 0 {
+		return nil, nil, nil
+	}
+	return mutations[0], undoMutations[0], nil
+}
+
+// Returns the fields through which the edge nodes reference the documents
+func edgeNodeReferenceFields() []*gql.ReferenceField {
+	fields := make([]*gql.ReferenceField, 0, 2)
+	for _, fieldName := range []string{"from", "to"} {
+		fields = append(fields, &gql.ReferenceField{
+			TypeName: gql.EdgeSimplifiedType.Name,
+			IdName:   "id",
+			Field:    gql.EdgeSimplifiedType.GetField(fieldName),
+		})
+	}
+	return fields
+}
+
+// Generates the mutations that delete the edge nodes from or to the document
+func (m *Doccache) deleteEdgeNodesMutations(docId interface{}) ([]*gql.Mutation, []*gql.Mutation, error) {
+	references, err := m.client.GetReferences(DocumentIdName, docId, edgeNodeReferenceFields())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed getting edge nodes, error: %v", err)
+	}
+	ids := make([]interface{}, 0)
+	added := make(map[interface{}]bool)
+	for _, fieldIds := range references {
+		for _, id := range fieldIds {
+			if !added[id] {
+				ids = append(ids, id)
+				added[id] = true
+			}
+		}
+	}
+	return m.deleteOwnedMutations(ids, gql.EdgeSimplifiedType)
+}
+
+// Creates the edge node instance for the edge
+func newEdgeNode(chainEdge *domain.ChainEdge) *gql.SimplifiedInstance {
+	values := chainEdge.GetMetadata()
+	values["id"] = chainEdge.GetId()
+	values["name"] = chainEdge.Name
+	values["from"] = GetEdgeValue(chainEdge.From)
+	values["to"] = GetEdgeValue(chainEdge.To)
+	return gql.NewSimplifiedInstance(gql.EdgeSimplifiedType, values)
+}
@@ -0,0 +1,205 @@
+package doccache
+
+import (
+	"fmt"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
+)
+
+// Mutations generated by a single operation, along with the cursor that should be stored once
+// they have been applied
+type batchEntry struct {
+	mutations []*gql.Mutation
+	cursor    string
+}
+
+// Buffers the mutations generated while processing a block so that they can be committed using
+// a bounded number of requests, it also keeps track of the documents modified by the buffered
+// mutations to enable reads to take the pending changes into account
+type MutationBatch struct {
+	entries      []*batchEntry
+	maxMutations int
+	// Type of the documents created or updated by the buffered mutations, an empty type
+	// indicates the document was deleted
+	docs map[interface{}]string
+	// Ids of the documents created or updated by the buffered mutations, by hash
+	hashes map[string]interface{}
+	// Hashes pointed to by the pending core edges created by the buffered mutations
+	pendingCoreEdges map[string]bool
+	// Ids of the documents that are part of the pending edges created by the buffered mutations
+	pendingEdges map[interface{}]bool
+	// Ids of the edge nodes created by the buffered mutations
+	edgeNodes map[string]bool
+}
+
+func NewMutationBatch(maxMutations int) *MutationBatch {
+	return &MutationBatch{
+		entries:          make([]*batchEntry, 0),
+		maxMutations:     maxMutations,
+		docs:             make(map[interface{}]string),
+		hashes:           make(map[string]interface{}),
+		pendingCoreEdges: make(map[string]bool),
+		pendingEdges:     make(map[interface{}]bool),
+		edgeNodes:        make(map[string]bool),
+	}
+}
+
+// Buffers the mutations of an operation along with the cursor to store once they are applied
+func (m *MutationBatch) Add(cursor string, mutations ...*gql.Mutation) {
+	m.entries = append(m.entries, &batchEntry{
+		mutations: mutations,
+		cursor:    cursor,
+	})
+}
+
+// Records that the document was created or updated by the buffered mutations
+func (m *MutationBatch) StoredDoc(docId interface{}, typeName string) {
+	m.docs[docId] = typeName
+}
+
+// Records that the document with the hash was created or updated by the buffered mutations
+func (m *MutationBatch) StoredHash(hash string, docId interface{}) {
+	m.hashes[hash] = docId
+}
+
+// Returns the id of the document with the hash if it has been created or updated by the
+// buffered mutations
+func (m *MutationBatch) GetDocIdByHash(hash string) (interface{}, bool) {
+	docId, ok := m.hashes[hash]
+	return docId, ok
+}
+
+// Records that a pending core edge pointing to the hash was created by the buffered mutations
+func (m *MutationBatch) AddedPendingCoreEdge(hash string) {
+	m.pendingCoreEdges[hash] = true
+}
+
+// Indicates whether pending core edges pointing to the hash were created by the buffered mutations
+func (m *MutationBatch) HasPendingCoreEdges(hash string) bool {
+	return m.pendingCoreEdges[hash]
+}
+
+// Records that a pending edge between the documents was created by the buffered mutations
+func (m *MutationBatch) AddedPendingEdge(from, to interface{}) {
+	m.pendingEdges[from] = true
+	m.pendingEdges[to] = true
+}
+
+// Indicates whether pending edges involving any of the documents were created by the buffered mutations
+func (m *MutationBatch) HasPendingEdges(docIds ...interface{}) bool {
+	for _, docId := range docIds {
+		if m.pendingEdges[docId] {
+			return true
+		}
+	}
+	return false
+}
+
+// Records that the edge node was created by the buffered mutations
+func (m *MutationBatch) AddedEdgeNode(id string) {
+	m.edgeNodes[id] = true
+}
+
+// Indicates whether the edge node was created by the buffered mutations
+func (m *MutationBatch) HasEdgeNode(id string) bool {
+	return m.edgeNodes[id]
+}
+
+// Records that the document was deleted by the buffered mutations
+func (m *MutationBatch) DeletedDoc(docId interface{}) {
+	m.docs[docId] = ""
+}
+
+// Returns the type of the document if it has been modified by the buffered mutations, an empty
+// type indicates it was deleted
+func (m *MutationBatch) GetDocType(docId interface{}) (string, bool) {
+	typeName, ok := m.docs[docId]
+	return typeName, ok
+}
+
+// Indicates whether any of the documents has been modified by the buffered mutations
+func (m *MutationBatch) HasDocs(docIds ...interface{}) bool {
+	for _, docId := range docIds {
+		if _, ok := m.docs[docId]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Removes the buffered mutations that are part of the request once it has been applied, requests
+// are applied in order so the operations whose mutations have all been applied are removed along
+// with the operations without mutations whose cursor was part of the request
+func (m *MutationBatch) Committed(request []*gql.Mutation) {
+	applied := make(map[*gql.Mutation]bool, len(request))
+	for _, mutation := range request {
+		applied[mutation] = true
+	}
+	for len(m.entries) > 0 {
+		entry := m.entries[0]
+		mutations := make([]*gql.Mutation, 0, len(entry.mutations))
+		for _, mutation := range entry.mutations {
+			if !applied[mutation] {
+				mutations = append(mutations, mutation)
+			}
+		}
+		if len(mutations) > 0 {
+			entry.mutations = mutations
+			return
+		}
+		m.entries = m.entries[1:]
+	}
+}
+
+func (m *MutationBatch) IsEmpty() bool {
+	return len(m.entries) == 0
+}
+
+// Returns the number of buffered mutations
+func (m *MutationBatch) Len() int {
+	length := 0
+	for _, entry := range m.entries {
+		length += len(entry.mutations)
+	}
+	return length
+}
+
+// Groups the buffered mutations into requests of at most maxMutations mutations, each request
+// includes the cursor of the last operation whose mutations it completes, so that the stored
+// cursor always reflects the changes that have been applied. The mutations of an operation are
+// only split if they do not fit in a single request
+func (m *MutationBatch) Requests() [][]*gql.Mutation {
+	requests := make([][]*gql.Mutation, 0)
+	request := make([]*gql.Mutation, 0, m.maxMutations)
+	requestCursor := ""
+	closeRequest := func() {
+		if len(request) == 0 && requestCursor == "" {
+			return
+		}
+		if requestCursor != "" {
+			request = append(request, gql.NewCursorInstance(CursorIdValue, requestCursor).AddMutation(true))
+		}
+		requests = append(requests, request)
+		request = make([]*gql.Mutation, 0, m.maxMutations)
+		requestCursor = ""
+	}
+	for _, entry := range m.entries {
+		//Requests are kept aligned with the operations whenever possible
+		if len(request) > 0 && len(request)+len(entry.mutations) > m.maxMutations {
+			closeRequest()
+		}
+		for _, mutation := range entry.mutations {
+			if len(request) >= m.maxMutations {
+				closeRequest()
+			}
+			request = append(request, mutation)
+		}
+		requestCursor = entry.cursor
+	}
+	closeRequest()
+	return requests
+}
+
+func (m *MutationBatch) String() string {
+	return fmt.Sprintf("MutationBatch{Operations: %v, Mutations: %v, Docs: %v}", len(m.entries), m.Len(), len(m.docs))
+}
@@ -0,0 +1,137 @@
+package doccache_test
+
+import (
+	"testing"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
+	"gotest.tools/assert"
+)
+
+func TestMutationBatchRequests(t *testing.T) {
+	batch := doccache.NewMutationBatch(3)
+	assert.Assert(t, batch.IsEmpty())
+	//Mutations on the same type can be part of the same request
+	addMember1 := getTestMutation("inputMember", "upsertMember")
+	addMember2 := getTestMutation("inputMember", "upsertMember")
+	addPeriod := getTestMutation("inputPeriod", "upsertPeriod")
+	updateMember := getTestMutation("idMember", "setMember", "removeMember")
+	updatePeriod := getTestMutation("idPeriod", "setPeriod", "removePeriod")
+	updateDho := getTestMutation("idDho", "setDho", "removeDho")
+
+	batch.Add("cursor1", addMember1)
+	batch.Add("cursor2", addPeriod)
+	batch.Add("cursor3", addMember2, updateMember)
+	batch.Add("cursor4")
+	batch.Add("cursor5", updatePeriod, updateDho)
+	assert.Equal(t, batch.Len(), 6)
+
+	requests := batch.Requests()
+	assert.Equal(t, len(requests), 3)
+	assertRequest(t, requests[0], "cursor2", addMember1, addPeriod)
+	assertRequest(t, requests[1], "cursor4", addMember2, updateMember)
+	assertRequest(t, requests[2], "cursor5", updatePeriod, updateDho)
+
+	batch.Committed(requests[0])
+	assert.Equal(t, batch.Len(), 4)
+	requests = batch.Requests()
+	assert.Equal(t, len(requests), 2)
+	assertRequest(t, requests[0], "cursor4", addMember2, updateMember)
+	batch.Committed(requests[0])
+	batch.Committed(requests[1])
+	assert.Assert(t, batch.IsEmpty())
+}
+
+func TestMutationBatchCommittedSplitOperation(t *testing.T) {
+	batch := doccache.NewMutationBatch(2)
+	removeMember := getTestMutation("idMember", "setMember", "removeMember")
+	addMember := getTestMutation("idMember", "setMember", "removeMember")
+	updatePeriod := getTestMutation("idPeriod", "setPeriod", "removePeriod")
+
+	batch.Add("cursor1", removeMember, addMember, updatePeriod)
+	batch.Add("cursor2")
+	requests := batch.Requests()
+	batch.Committed(requests[0])
+	assert.Equal(t, batch.Len(), 1)
+	requests = batch.Requests()
+	assert.Equal(t, len(requests), 1)
+	assertRequest(t, requests[0], "cursor2", updatePeriod)
+}
+
+func TestMutationBatchRequestsSplitOperation(t *testing.T) {
+	batch := doccache.NewMutationBatch(2)
+	removeMember := getTestMutation("idMember", "setMember", "removeMember")
+	addMember := getTestMutation("idMember", "setMember", "removeMember")
+	updatePeriod := getTestMutation("idPeriod", "setPeriod", "removePeriod")
+	addPeriod := getTestMutation("inputPeriod", "upsertPeriod")
+
+	batch.Add("cursor1", removeMember, addMember, updatePeriod)
+	batch.Add("cursor2", addPeriod)
+	requests := batch.Requests()
+	assert.Equal(t, len(requests), 2)
+	assertRequest(t, requests[0], "", removeMember, addMember)
+	assertRequest(t, requests[1], "cursor2", updatePeriod, addPeriod)
+}
+
+func TestMutationBatchDocs(t *testing.T) {
+	batch := doccache.NewMutationBatch(10)
+	batch.StoredDoc("1", "Member")
+	batch.DeletedDoc("2")
+	typeName, ok := batch.GetDocType("1")
+	assert.Assert(t, ok)
+	assert.Equal(t, typeName, "Member")
+	typeName, ok = batch.GetDocType("2")
+	assert.Assert(t, ok)
+	assert.Equal(t, typeName, "")
+	_, ok = batch.GetDocType("3")
+	assert.Assert(t, !ok)
+	assert.Assert(t, batch.HasDocs("3", "2"))
+	assert.Assert(t, !batch.HasDocs("3", "4"))
+}
+
+func TestMutationBatchHashes(t *testing.T) {
+	batch := doccache.NewMutationBatch(10)
+	batch.StoredHash("a5ec74", "1")
+	batch.AddedPendingCoreEdge("b7cf9e")
+	docId, ok := batch.GetDocIdByHash("a5ec74")
+	assert.Assert(t, ok)
+	assert.Equal(t, docId, "1")
+	_, ok = batch.GetDocIdByHash("b7cf9e")
+	assert.Assert(t, !ok)
+	assert.Assert(t, batch.HasPendingCoreEdges("b7cf9e"))
+	assert.Assert(t, !batch.HasPendingCoreEdges("a5ec74"))
+}
+
+func TestMutationBatchPendingEdges(t *testing.T) {
+	batch := doccache.NewMutationBatch(10)
+	batch.AddedPendingEdge("1", "2")
+	assert.Assert(t, batch.HasPendingEdges("2"))
+	assert.Assert(t, batch.HasPendingEdges("3", "1"))
+	assert.Assert(t, !batch.HasPendingEdges("3"))
+}
+
+func getTestMutation(params ...string) *gql.Mutation {
+	mutation := &gql.Mutation{
+		Params: make(map[string]interface{}),
+	}
+	for _, param := range params {
+		mutation.Params[param] = param
+	}
+	return mutation
+}
+
+func assertRequest(t *testing.T, actual []*gql.Mutation, cursor string, expected ...*gql.Mutation) {
+	expectedLen := len(expected)
+	if cursor != "" {
+		expectedLen++
+	}
+	assert.Equal(t, len(actual), expectedLen)
+	for i, mutation := range expected {
+		assert.Assert(t, actual[i] == mutation)
+	}
+	if cursor != "" {
+		cursorMutation := actual[expectedLen-1]
+		cursorValues := cursorMutation.Params["inputCursor_"+cursorMutation.Alias].(map[string]interface{})
+		assert.Equal(t, cursorValues["cursor"], cursor)
+	}
+}
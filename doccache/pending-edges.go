@@ -0,0 +1,198 @@
+package doccache
+
+import (
+	"fmt"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
+)
+
+// Generates the mutation that stores the edge as a pending edge, which is created once both its FROM
+// and TO documents exist, or removes it from the pending edges for delete operations, along with the
+// mutation that reverts it and whether the number of pending edges changes, returns nil if there is
+// nothing to change
+func (m *Doccache) pendingEdgeMutation(chainEdge *domain.ChainEdge, deleteOp bool) (*gql.Mutation, *gql.Mutation, bool, error) {
+	pending := newPendingEdge(chainEdge)
+	stored, err := m.getStoredPendingEdge(chainEdge)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	if !deleteOp {
+		log.Infof("Storing pending edge: %v", chainEdge)
+		if stored != nil {
+			return pending.AddMutation(true), newPendingEdge(toChainEdge(stored)).AddMutation(true), false, nil
+		}
+		undoMutation, err := pending.DeleteMutation("id")
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("failed generating pending edge undo mutation, error: %v", err)
+		}
+		return pending.AddMutation(true), undoMutation, true, nil
+	}
+	if stored == nil {
+		return nil, nil, false, nil
+	}
+	log.Infof("Removing pending edge: %v", chainEdge)
+	mutation, err := pending.DeleteMutation("id")
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("failed generating pending edge delete mutation, error: %v", err)
+	}
+	return mutation, newPendingEdge(toChainEdge(stored)).AddMutation(true), true, nil
+}
+
+// Returns the stored pending edge for the edge, nil if it is not a pending edge
+func (m *Doccache) getStoredPendingEdge(chainEdge *domain.ChainEdge) (*gql.SimplifiedBaseInstance, error) {
+	if m.pendingEdges == 0 {
+		return nil, nil
+	}
+	if m.batch != nil && m.batch.HasPendingEdges(chainEdge.From, chainEdge.To) {
+		err := m.commitBatch()
+		if err != nil {
+			return nil, fmt.Errorf("failed committing batch, error: %v", err)
+		}
+	}
+	id := chainEdge.GetId()
+	stored, err := m.client.GetBaseInstances("id", []interface{}{id}, gql.PendingEdgeSimplifiedType.SimplifiedBaseType, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting pending edge: %v, error: %v", id, err)
+	}
+	return stored[id], nil
+}
+
+// A change made to the pending edges that has to be tracked once it has been stored
+type pendingEdgeChange struct {
+	chainEdge    *domain.ChainEdge
+	deleteOp     bool
+	countChanged bool
+}
+
+// Records the changes made to the pending edges, countChanged indicates whether the
+// number of pending edges changed
+func (m *Doccache) trackPendingEdge(chainEdge *domain.ChainEdge, deleteOp, countChanged bool) {
+	if !deleteOp && m.batch != nil {
+		m.batch.AddedPendingEdge(chainEdge.From, chainEdge.To)
+	}
+	if !countChanged {
+		return
+	}
+	if !deleteOp {
+		m.pendingEdges++
+	} else if m.pendingEdges > 0 {
+		m.pendingEdges--
+	}
+}
+
+// Generates the mutations that create the pending edges that can be created now that the document
+// has been stored, and remove them from the pending edges, along with the mutations that revert them
+func (m *Doccache) applyPendingEdgesMutations(docId interface{}, typeName string) ([]*gql.Mutation, []*gql.Mutation, int, error) {
+	mutations := make([]*gql.Mutation, 0)
+	undoMutations := make([]*gql.Mutation, 0)
+	if m.pendingEdges == 0 {
+		return mutations, undoMutations, 0, nil
+	}
+	if m.batch != nil && m.batch.HasPendingEdges(docId) {
+		err := m.commitBatch()
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("failed committing batch, error: %v", err)
+		}
+	}
+	chainEdges := make([]*domain.ChainEdge, 0)
+	for _, field := range []string{"from", "to"} {
+		pendings, err := m.client.SearchBaseInstances(field, []interface{}{docId}, gql.PendingEdgeSimplifiedType.SimplifiedBaseType, nil)
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("failed getting pending edges %v document: %v, error: %v", field, docId, err)
+		}
+		for _, pending := range pendings {
+			chainEdge := toChainEdge(pending)
+			//Self referencing edges are returned by both searches
+			if field == "to" && chainEdge.From == chainEdge.To {
+				continue
+			}
+			chainEdges = append(chainEdges, chainEdge)
+		}
+	}
+	if len(chainEdges) == 0 {
+		return mutations, undoMutations, 0, nil
+	}
+	instances, err := m.getEdgeInstances(chainEdges...)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed getting pending edge instances, error: %v", err)
+	}
+	instances[docId] = gql.NewSimplifiedBaseInstance(
+		gql.DocumentSimplifiedInterface.SimplifiedBaseType,
+		map[string]interface{}{
+			"docId": docId,
+			"type":  typeName,
+		},
+	)
+	applied := 0
+	for _, chainEdge := range chainEdges {
+		_, hasFrom := instances[chainEdge.From]
+		_, hasTo := instances[chainEdge.To]
+		if !hasFrom || !hasTo {
+			continue
+		}
+		log.Infof("Creating pending edge: %v", chainEdge)
+		edgeMutations, edgeUndoMutations, err := m.edgeMutations(chainEdge, instances, false)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		pending := newPendingEdge(chainEdge)
+		deleteMutation, err := pending.DeleteMutation("id")
+		if err != nil {
+			return nil, nil, 0, fmt.Errorf("failed generating pending edge delete mutation, error: %v", err)
+		}
+		mutations = append(append(mutations, edgeMutations...), deleteMutation)
+		undoMutations = append(append(undoMutations, edgeUndoMutations...), pending.AddMutation(true))
+		applied++
+	}
+	return mutations, undoMutations, applied, nil
+}
+
+// Returns the number of pending edges
+func (m *Doccache) PendingEdgeCount() int64 {
+	return m.pendingEdges
+}
+
+// Loads the number of pending edges from the db
+func (m *Doccache) refreshPendingEdgeCount() error {
+	count, err := m.client.Count(gql.PendingEdgeSimplifiedType.SimplifiedBaseType)
+	if err != nil {
+		return fmt.Errorf("failed counting pending edges, error: %v", err)
+	}
+	m.pendingEdges = count
+	return nil
+}
+
+// Returns the stored pending edges
+func (m *Doccache) GetPendingEdges() ([]*domain.ChainEdge, error) {
+	pendings, err := m.client.GetAllBaseInstances(gql.PendingEdgeSimplifiedType.SimplifiedBaseType, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting pending edges, error: %v", err)
+	}
+	chainEdges := make([]*domain.ChainEdge, 0, len(pendings))
+	for _, pending := range pendings {
+		chainEdges = append(chainEdges, toChainEdge(pending))
+	}
+	return chainEdges, nil
+}
+
+// Creates the instance that records an edge whose FROM or TO documents do not exist yet, along
+// with its metadata
+func newPendingEdge(chainEdge *domain.ChainEdge) *gql.SimplifiedInstance {
+	values := chainEdge.GetMetadata()
+	values["id"] = chainEdge.GetId()
+	values["name"] = chainEdge.Name
+	values["from"] = chainEdge.From
+	values["to"] = chainEdge.To
+	return gql.NewSimplifiedInstance(gql.PendingEdgeSimplifiedType, values)
+}
+
+func toChainEdge(pending *gql.SimplifiedBaseInstance) *domain.ChainEdge {
+	chainEdge := domain.NewChainEdge(
+		pending.GetValue("name").(string),
+		pending.GetValue("from").(string),
+		pending.GetValue("to").(string),
+	)
+	chainEdge.SetMetadata(pending.Values)
+	return chainEdge
+}
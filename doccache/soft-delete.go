@@ -0,0 +1,99 @@
+package doccache
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
+)
+
+// Indicates whether the documents of the type are marked as deleted instead of being removed
+func (m *Doccache) softDeletes(typeName string) bool {
+	return m.config.SoftDeleteTypes[typeName]
+}
+
+// Generates the mutations that mark the document as deleted and remove the references other documents
+// have to it and its pending edges, along with the mutations that revert them, returns the number of
+// pending edges removed. The values, edges and owned nodes of the document are kept so that it is
+// still queryable
+func (m *Doccache) softDeleteMutations(chainDoc *domain.ChainDocument, parsedDoc *domain.ParsedDoc, currentType *gql.SimplifiedType, referenceFields []*gql.ReferenceField) ([]*gql.Mutation, []*gql.Mutation, int, error) {
+	docId := parsedDoc.GetValue(DocumentIdName)
+	mutations, undoMutations, err := m.removeReferencesMutations(docId, parsedDoc.GetHash(), referenceFields)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	pendingMutations, pendingUndoMutations, removedPendingEdges, err := m.removePendingEdgesMutations(docId)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	mutations = append(mutations, pendingMutations...)
+	undoMutations = append(undoMutations, pendingUndoMutations...)
+
+	values := map[string]interface{}{
+		"deleted":     true,
+		"deletedDate": getDeletedDate(chainDoc),
+	}
+	undoRemove := map[string]interface{}{
+		"deleted":     true,
+		"deletedDate": values["deletedDate"],
+	}
+	undoSet, err := m.updatedBlockValues(docId, chainDoc, currentType, values, undoRemove)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	mutation, err := currentType.UpdateMutation(DocumentIdName, docId, values, nil)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed creating soft delete mutation, error: %v", err)
+	}
+	undoMutation, err := currentType.UpdateMutation(DocumentIdName, docId, undoSet, undoRemove)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed creating soft delete undo mutation, error: %v", err)
+	}
+	mutations = append(mutations, mutation)
+	undoMutations = append(undoMutations, undoMutation)
+	return mutations, undoMutations, removedPendingEdges, nil
+}
+
+// Adds the values that record the block and transaction of the deletion to the values, returns the
+// values those fields had before the deletion so that they can be restored, the fields that had no
+// value are added to the values to remove instead
+func (m *Doccache) updatedBlockValues(docId interface{}, chainDoc *domain.ChainDocument, currentType *gql.SimplifiedType, values, remove map[string]interface{}) (map[string]interface{}, error) {
+	blockValues := chainDoc.GetBlockValues()
+	//The created block is kept
+	delete(blockValues, "createdBlock")
+	if len(blockValues) == 0 {
+		return nil, nil
+	}
+	fields := make([]string, 0, len(blockValues))
+	for name := range blockValues {
+		fields = append(fields, name)
+	}
+	docs, err := m.GetDocumentBaseInstances([]interface{}{docId}, currentType.SimplifiedBaseType, fields)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting block values of document: %v, error: %v", docId, err)
+	}
+	previous := make(map[string]interface{})
+	for name, value := range blockValues {
+		values[name] = value
+		var previousValue interface{}
+		if doc, ok := docs[docId]; ok {
+			previousValue = doc.GetValue(name)
+		}
+		if previousValue != nil {
+			previous[name] = previousValue
+		} else {
+			remove[name] = value
+		}
+	}
+	return previous, nil
+}
+
+// Returns the date in which the document was deleted, the time of the block that deleted it if known
+func getDeletedDate(chainDoc *domain.ChainDocument) string {
+	deletedDate := chainDoc.BlockTime
+	if deletedDate.IsZero() {
+		deletedDate = time.Now()
+	}
+	return deletedDate.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,225 @@
+package doccache
+
+import (
+	"fmt"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
+)
+
+// Stores the mutations that move a document to its new type. The type of a document can change
+// between versions, i.e. its system_type_n changes or a different type is deduced, in which case the
+// node stored with the old type is replaced by a node of the new type, and its edges and the
+// references other nodes have to it are moved to the new node
+type typeChange struct {
+	// The values of the document as stored with the old type, without its edges
+	previous *gql.SimplifiedInstance
+	// Mutations that have to be applied before the document is added with the new type, along with
+	// the mutations that revert them
+	preMutations     []*gql.Mutation
+	preUndoMutations []*gql.Mutation
+	// Mutations that have to be applied after the document is added with the new type, along with
+	// the mutations that revert them
+	postMutations     []*gql.Mutation
+	postUndoMutations []*gql.Mutation
+}
+
+// Returns the type with which the document is stored, an empty string if it does not exist
+func (m *Doccache) getStoredType(docId interface{}) (string, error) {
+	docs, err := m.GetDocumentBaseInstances([]interface{}{docId}, gql.DocumentSimplifiedInterface.SimplifiedBaseType, []string{DocumentIdName, "type"})
+	if err != nil {
+		return "", fmt.Errorf("failed getting document: %v, error: %v", docId, err)
+	}
+	if doc, ok := docs[docId]; ok {
+		typeName, _ := doc.GetValue("type").(string)
+		return typeName, nil
+	}
+	return "", nil
+}
+
+// Generates the mutations that replace the node of the document stored with the old type with the
+// node of the new type represented by the instance, returns nil if the document does not exist
+func (m *Doccache) typeChangeMutations(instance *gql.SimplifiedInstance, oldTypeName string) (*typeChange, error) {
+	docId := instance.GetValue(DocumentIdName)
+	newTypeName := instance.SimplifiedType.Name
+	oldType, err := m.Schema.GetSimplifiedType(oldTypeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting type: %v, error: %v", oldTypeName, err)
+	}
+	if oldType == nil {
+		return nil, fmt.Errorf("type: %v not found", oldTypeName)
+	}
+	previous, err := m.GetDocumentInstance(docId, oldType, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting document, error: %v", err)
+	}
+	if previous == nil {
+		return nil, nil
+	}
+	coreValues := make(map[string]interface{})
+	for _, name := range oldType.GetCoreFields() {
+		if value, ok := previous.Values[name]; ok {
+			coreValues[name] = value
+		}
+	}
+	change := &typeChange{
+		previous:          gql.NewSimplifiedInstance(oldType, coreValues),
+		preMutations:      make([]*gql.Mutation, 0),
+		preUndoMutations:  make([]*gql.Mutation, 0),
+		postMutations:     make([]*gql.Mutation, 0),
+		postUndoMutations: make([]*gql.Mutation, 0),
+	}
+
+	referenceFields, err := m.Schema.GetReferenceFields(oldType)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting reference fields, error: %v", err)
+	}
+	if m.config.EdgeMetadata {
+		referenceFields = append(referenceFields, edgeNodeReferenceFields()...)
+	}
+	if len(m.config.HistoryTypes) > 0 {
+		referenceFields = append(referenceFields, documentVersionReferenceFields()...)
+	}
+	references, err := m.client.GetReferences(DocumentIdName, docId, referenceFields)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting references, error: %v", err)
+	}
+	for _, referenceField := range referenceFields {
+		referencingIds := make([]interface{}, 0)
+		for _, referencingId := range references[referenceField] {
+			//The edges of the document itself are moved along with it
+			if referencingId != docId {
+				referencingIds = append(referencingIds, referencingId)
+			}
+		}
+		if len(referencingIds) == 0 {
+			continue
+		}
+		err = m.moveReferencesMutations(change, referenceField, referencingIds, docId, newTypeName)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	deleteMutation, err := previous.DeleteMutation(DocumentIdName)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating delete mutation, error: %v", err)
+	}
+	change.preMutations = append(change.preMutations, deleteMutation)
+	change.preUndoMutations = append(change.preUndoMutations, gql.NewSimplifiedInstance(oldType, nonNilValues(previous.Values)).AddMutation(false))
+
+	edges, err := m.moveEdges(previous, oldType, newTypeName)
+	if err != nil {
+		return nil, err
+	}
+	if len(edges) > 0 {
+		//The edges are added once the node exists, they are removed along with it if reverted
+		edgesMutation, err := instance.SimplifiedType.UpdateMutation(DocumentIdName, docId, edges, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed creating edges mutation, error: %v", err)
+		}
+		change.postMutations = append(change.postMutations, edgesMutation)
+	}
+	return change, nil
+}
+
+// Generates the mutations that remove the references to the document before the old node is
+// deleted, and add them back once the new node exists
+func (m *Doccache) moveReferencesMutations(change *typeChange, referenceField *gql.ReferenceField, referencingIds []interface{}, docId interface{}, newTypeName string) error {
+	referenceType, err := m.Schema.GetSimplifiedType(referenceField.TypeName)
+	if err != nil {
+		return fmt.Errorf("failed getting type: %v, error: %v", referenceField.TypeName, err)
+	}
+	idName := referenceField.IdName
+	if idName == "" {
+		idName = DocumentIdName
+	}
+	var ref map[string]interface{}
+	if referenceField.Field.IsArray {
+		ref = map[string]interface{}{referenceField.Field.Name: []map[string]interface{}{GetEdgeValue(docId)}}
+	} else {
+		ref = map[string]interface{}{referenceField.Field.Name: GetEdgeValue(docId)}
+	}
+	relink, err := m.prepareReferenceField(referenceType, referenceField.Field, newTypeName)
+	if err != nil {
+		return fmt.Errorf("failed preparing reference field: %v, error: %v", referenceField, err)
+	}
+	for _, referencingId := range referencingIds {
+		removeMutation, err := referenceType.UpdateMutation(idName, referencingId, nil, ref)
+		if err != nil {
+			return fmt.Errorf("failed creating remove reference mutation, error: %v", err)
+		}
+		restoreMutation, err := referenceType.UpdateMutation(idName, referencingId, ref, nil)
+		if err != nil {
+			return fmt.Errorf("failed creating restore reference mutation, error: %v", err)
+		}
+		change.preMutations = append(change.preMutations, removeMutation)
+		change.preUndoMutations = append(change.preUndoMutations, restoreMutation)
+		if !relink {
+			log.Warnf("Reference: %v of: %v to document: %v can not reference type: %v, removing it", referenceField, referencingId, docId, newTypeName)
+			continue
+		}
+		change.postMutations = append(change.postMutations, restoreMutation)
+		change.postUndoMutations = append(change.postUndoMutations, removeMutation)
+	}
+	return nil
+}
+
+// Makes sure the reference field can reference documents of the type, the field is generalized to
+// reference any document if required, returns false if the field is defined by an interface with a
+// type that is not compatible
+func (m *Doccache) prepareReferenceField(referenceType *gql.SimplifiedType, field *gql.SimplifiedField, typeName string) (bool, error) {
+	edgeType, err := m.coreEdgeType(referenceType, field.Name, typeName)
+	if err != nil {
+		return false, err
+	}
+	if edgeType == "" {
+		return false, nil
+	}
+	if edgeType == field.Type {
+		return true, nil
+	}
+	if field.IsArray {
+		err = m.updateSchemaEdge(referenceType.Name, field.Name, edgeType)
+	} else {
+		err = m.updateSchemaCoreEdge(referenceType.Name, field.Name, edgeType)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Returns the edges of the document stored with the old type, the schema of the new type is updated
+// to be able to store them
+func (m *Doccache) moveEdges(previous *gql.SimplifiedInstance, oldType *gql.SimplifiedType, newTypeName string) (map[string]interface{}, error) {
+	newType, err := m.Schema.GetSimplifiedType(newTypeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting type: %v, error: %v", newTypeName, err)
+	}
+	edges := make(map[string]interface{})
+	for name, field := range oldType.Fields {
+		if !field.IsEdge() || field.IsOwned() {
+			continue
+		}
+		values, _ := previous.GetValue(name).([]interface{})
+		if len(values) == 0 {
+			continue
+		}
+		edgeType := field.Type
+		if currentField := newType.GetField(name); currentField != nil && currentField.Type != edgeType {
+			edgeType = gql.DocumentSimplifiedInterface.Name
+		}
+		err = m.updateSchemaEdge(newTypeName, name, edgeType)
+		if err != nil {
+			return nil, fmt.Errorf("failed updating schema for edge: %v, error: %v", name, err)
+		}
+		refs := make([]map[string]interface{}, 0, len(values))
+		for _, value := range values {
+			if ref, ok := value.(map[string]interface{}); ok {
+				refs = append(refs, GetEdgeValue(ref[DocumentIdName]))
+			}
+		}
+		edges[name] = refs
+	}
+	return edges, nil
+}
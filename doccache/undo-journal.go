@@ -0,0 +1,102 @@
+package doccache
+
+import (
+	"fmt"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
+)
+
+// Stores the mutations required to revert the changes applied while processing a block
+type BlockJournal struct {
+	BlockNum  uint64
+	Mutations []*gql.Mutation
+}
+
+func NewBlockJournal(blockNum uint64) *BlockJournal {
+	return &BlockJournal{
+		BlockNum:  blockNum,
+		Mutations: make([]*gql.Mutation, 0),
+	}
+}
+
+// Adds a mutation that reverts a change applied while processing the block
+func (m *BlockJournal) Record(mutation *gql.Mutation) {
+	m.Mutations = append(m.Mutations, mutation)
+}
+
+// Returns the mutations in the order in which they should be applied to revert
+// the block changes
+func (m *BlockJournal) UndoMutations() []*gql.Mutation {
+	undo := make([]*gql.Mutation, 0, len(m.Mutations))
+	for i := len(m.Mutations) - 1; i >= 0; i-- {
+		undo = append(undo, m.Mutations[i])
+	}
+	return undo
+}
+
+func (m *BlockJournal) String() string {
+	return fmt.Sprintf("BlockJournal{BlockNum: %v, Mutations: %v}", m.BlockNum, len(m.Mutations))
+}
+
+// Keeps the undo information for the most recent blocks, enabling the exact restoration
+// of the previous state when a block is undone because of a fork
+type UndoJournal struct {
+	blocks    []*BlockJournal
+	maxBlocks int
+	current   *BlockJournal
+}
+
+func NewUndoJournal(maxBlocks int) *UndoJournal {
+	return &UndoJournal{
+		blocks:    make([]*BlockJournal, 0, maxBlocks),
+		maxBlocks: maxBlocks,
+	}
+}
+
+// Sets the block to which subsequently recorded mutations belong, a blockNum of 0
+// stops the recording of mutations
+func (m *UndoJournal) SetBlock(blockNum uint64) {
+	if blockNum == 0 {
+		m.current = nil
+		return
+	}
+	if m.current != nil && m.current.BlockNum == blockNum {
+		return
+	}
+	m.current = NewBlockJournal(blockNum)
+	m.blocks = append(m.blocks, m.current)
+	if len(m.blocks) > m.maxBlocks {
+		m.blocks = m.blocks[len(m.blocks)-m.maxBlocks:]
+	}
+}
+
+// Indicates whether mutations are being recorded
+func (m *UndoJournal) IsRecording() bool {
+	return m.current != nil
+}
+
+// Records a mutation that reverts a change applied while processing the current block
+func (m *UndoJournal) Record(mutation *gql.Mutation) {
+	if m.current != nil {
+		m.current.Record(mutation)
+	}
+}
+
+// Removes and returns the journal for the specified block, the journals of any blocks
+// recorded after it are discarded as they should have already been undone
+func (m *UndoJournal) Pop(blockNum uint64) *BlockJournal {
+	for i := len(m.blocks) - 1; i >= 0; i-- {
+		if m.blocks[i].BlockNum == blockNum {
+			journal := m.blocks[i]
+			m.blocks = m.blocks[:i]
+			m.current = nil
+			return journal
+		}
+	}
+	return nil
+}
+
+// Returns the number of blocks for which undo information is kept
+func (m *UndoJournal) Len() int {
+	return len(m.blocks)
+}
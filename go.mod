@@ -4,6 +4,9 @@ go 1.15
 
 require (
 	github.com/dfuse-io/dfuse-eosio v0.9.0-beta9.0.20210812014530-dcb01c5c4b35
+	github.com/eoscanada/eos-go v0.9.1-0.20210802215146-d4a45e07e9b5
+	github.com/golang/protobuf v1.5.2
+	github.com/gorilla/websocket v1.4.2
 	github.com/iancoleman/strcase v0.1.3
 	github.com/machinebox/graphql v0.2.2
 	github.com/matryer/is v1.4.0 // indirect
@@ -46,6 +46,47 @@ var DocumentFieldArgs = map[string]*SimplifiedField{
 		NonNull: true,
 		Indexes: NewIndexes("exact"),
 	},
+	"hash": {
+		Name:    "hash",
+		Type:    "String",
+		Indexes: NewIndexes("exact"),
+	},
+	"certificates": {
+		Name:      "certificates",
+		Type:      "Certificate",
+		IsArray:   true,
+		OwnedType: CertificateSimplifiedType.SimplifiedBaseType,
+	},
+	"deleted": {
+		Name:    "deleted",
+		Type:    GQLType_Boolean,
+		Indexes: NewIndexes("bool"),
+	},
+	"deletedDate": {
+		Name:    "deletedDate",
+		Type:    GQLType_Time,
+		Indexes: NewIndexes("hour"),
+	},
+	"createdBlock": {
+		Name:    "createdBlock",
+		Type:    GQLType_Int64,
+		Indexes: NewIndexes("int64"),
+	},
+	"updatedBlock": {
+		Name:    "updatedBlock",
+		Type:    GQLType_Int64,
+		Indexes: NewIndexes("int64"),
+	},
+	"updatedTrxId": {
+		Name:    "updatedTrxId",
+		Type:    "String",
+		Indexes: NewIndexes("exact"),
+	},
+	"updatedBlockTime": {
+		Name:    "updatedBlockTime",
+		Type:    GQLType_Time,
+		Indexes: NewIndexes("hour"),
+	},
 }
 
 const DocumentFields = `
@@ -55,6 +96,14 @@ const DocumentFields = `
 		createdDate: DateTime! @search(by: [hour])
 		updatedDate: DateTime! @search(by: [hour])
 		contract: String! @search(by: [exact])
+		hash: String @search(by: [exact])
+		certificates: [Certificate!]
+		deleted: Boolean @search(by: [bool])
+		deletedDate: DateTime @search(by: [hour])
+		createdBlock: Int64 @search(by: [int64])
+		updatedBlock: Int64 @search(by: [int64])
+		updatedTrxId: String @search(by: [exact])
+		updatedBlockTime: DateTime @search(by: [hour])
 `
 
 // The base graphql schema
@@ -79,10 +128,58 @@ const BaseSchema = `
 		elasticApiKey: String!
 	}
 
+	type Certificate {
+		id: String! @id @search(by: [exact])
+		certifier: String! @search(by: [exact, regexp])
+		notes: String! @search(by: [regexp])
+		certificationDate: DateTime! @search(by: [hour])
+	}
+
+	type PendingCoreEdge {
+		id: String! @id @search(by: [exact])
+		hash: String! @search(by: [exact])
+		docId: String!
+		field: String!
+	}
+
+	type PendingEdge {
+		id: String! @id @search(by: [exact])
+		name: String!
+		from: String! @search(by: [exact])
+		to: String! @search(by: [exact])
+		creator: String
+		contract: String
+		createdDate: DateTime
+		createdBlock: Int64
+		createdTrxId: String
+	}
+
+	type Edge {
+		id: String! @id @search(by: [exact])
+		name: String! @search(by: [exact])
+		from: Document
+		to: Document
+		creator: String @search(by: [exact])
+		contract: String @search(by: [exact])
+		createdDate: DateTime @search(by: [hour])
+		createdBlock: Int64 @search(by: [int64])
+		createdTrxId: String @search(by: [exact])
+	}
+
 	type TypeVersion {
 		type: String! @search(by: [exact])
 		version: String @search(by: [exact])
 	}
+
+	type DocumentVersion {
+		id: String! @id @search(by: [exact])
+		docId: String! @search(by: [exact])
+		version: Int64! @search(by: [int64])
+		document: Document
+		block: Int64 @search(by: [int64])
+		date: DateTime! @search(by: [hour])
+		values: String!
+	}
 	
 `
 
@@ -162,6 +259,229 @@ var DoccacheConfigSimplifiedType = &SimplifiedType{
 	},
 }
 
+// The certificate type, certificates are owned by the document that has been certified
+var CertificateSimplifiedType = &SimplifiedType{
+	SimplifiedBaseType: &SimplifiedBaseType{
+		Name: "Certificate",
+		Fields: map[string]*SimplifiedField{
+			"id": {
+				Name:    "id",
+				IsID:    true,
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+				NonNull: true,
+			},
+			"certifier": {
+				Name:    "certifier",
+				Type:    "String",
+				Indexes: NewIndexes("exact", "regexp"),
+				NonNull: true,
+			},
+			"notes": {
+				Name:    "notes",
+				Type:    "String",
+				Indexes: NewIndexes("regexp"),
+				NonNull: true,
+			},
+			"certificationDate": {
+				Name:    "certificationDate",
+				Type:    "DateTime",
+				Indexes: NewIndexes("hour"),
+				NonNull: true,
+			},
+		},
+	},
+}
+
+// The type used to keep track of the core edges whose target document has not been stored yet
+var PendingCoreEdgeSimplifiedType = &SimplifiedType{
+	SimplifiedBaseType: &SimplifiedBaseType{
+		Name: "PendingCoreEdge",
+		Fields: map[string]*SimplifiedField{
+			"id": {
+				Name:    "id",
+				IsID:    true,
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+				NonNull: true,
+			},
+			"hash": {
+				Name:    "hash",
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+				NonNull: true,
+			},
+			"docId": {
+				Name:    "docId",
+				Type:    "String",
+				NonNull: true,
+			},
+			"field": {
+				Name:    "field",
+				Type:    "String",
+				NonNull: true,
+			},
+		},
+	},
+}
+
+// The type used to keep track of the edges whose from or to documents have not been stored yet
+var PendingEdgeSimplifiedType = &SimplifiedType{
+	SimplifiedBaseType: &SimplifiedBaseType{
+		Name: "PendingEdge",
+		Fields: map[string]*SimplifiedField{
+			"id": {
+				Name:    "id",
+				IsID:    true,
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+				NonNull: true,
+			},
+			"name": {
+				Name:    "name",
+				Type:    "String",
+				NonNull: true,
+			},
+			"from": {
+				Name:    "from",
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+				NonNull: true,
+			},
+			"to": {
+				Name:    "to",
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+				NonNull: true,
+			},
+			"creator": {
+				Name: "creator",
+				Type: "String",
+			},
+			"contract": {
+				Name: "contract",
+				Type: "String",
+			},
+			"createdDate": {
+				Name: "createdDate",
+				Type: GQLType_Time,
+			},
+			"createdBlock": {
+				Name: "createdBlock",
+				Type: GQLType_Int64,
+			},
+			"createdTrxId": {
+				Name: "createdTrxId",
+				Type: "String",
+			},
+		},
+	},
+}
+
+// The type used to store the edges along with their metadata, when the edge metadata mode is enabled
+var EdgeSimplifiedType = &SimplifiedType{
+	SimplifiedBaseType: &SimplifiedBaseType{
+		Name: "Edge",
+		Fields: map[string]*SimplifiedField{
+			"id": {
+				Name:    "id",
+				IsID:    true,
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+				NonNull: true,
+			},
+			"name": {
+				Name:    "name",
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+				NonNull: true,
+			},
+			"from": {
+				Name: "from",
+				Type: "Document",
+			},
+			"to": {
+				Name: "to",
+				Type: "Document",
+			},
+			"creator": {
+				Name:    "creator",
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+			},
+			"contract": {
+				Name:    "contract",
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+			},
+			"createdDate": {
+				Name:    "createdDate",
+				Type:    GQLType_Time,
+				Indexes: NewIndexes("hour"),
+			},
+			"createdBlock": {
+				Name:    "createdBlock",
+				Type:    GQLType_Int64,
+				Indexes: NewIndexes("int64"),
+			},
+			"createdTrxId": {
+				Name:    "createdTrxId",
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+			},
+		},
+	},
+}
+
+// The type used to store the previous values of a document each time it is updated, for the types
+// for which history is enabled
+var DocumentVersionSimplifiedType = &SimplifiedType{
+	SimplifiedBaseType: &SimplifiedBaseType{
+		Name: "DocumentVersion",
+		Fields: map[string]*SimplifiedField{
+			"id": {
+				Name:    "id",
+				IsID:    true,
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+				NonNull: true,
+			},
+			"docId": {
+				Name:    "docId",
+				Type:    "String",
+				Indexes: NewIndexes("exact"),
+				NonNull: true,
+			},
+			"version": {
+				Name:    "version",
+				Type:    GQLType_Int64,
+				Indexes: NewIndexes("int64"),
+				NonNull: true,
+			},
+			"document": {
+				Name: "document",
+				Type: "Document",
+			},
+			"block": {
+				Name:    "block",
+				Type:    GQLType_Int64,
+				Indexes: NewIndexes("int64"),
+			},
+			"date": {
+				Name:    "date",
+				Type:    GQLType_Time,
+				Indexes: NewIndexes("hour"),
+				NonNull: true,
+			},
+			"values": {
+				Name:    "values",
+				Type:    "String",
+				NonNull: true,
+			},
+		},
+	},
+}
+
 var BaseSchemaSource = &ast.Source{
 	Input:   BaseSchema,
 	BuiltIn: false,
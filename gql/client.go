@@ -4,16 +4,27 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"sync/atomic"
 
 	"github.com/machinebox/graphql"
 )
 
+var mutationCount uint64
+
 type Mutation struct {
+	// Unique alias of the mutation operation, its parameter names are suffixed with it, enabling any
+	// mix of mutations, including several on the same type, to be sent in the same request
+	Alias        string
 	ParamStmt    string
 	MutationStmt string
 	Params       map[string]interface{}
 }
 
+// Returns a process wide unique alias for a mutation
+func newMutationAlias() string {
+	return fmt.Sprintf("m%v", atomic.AddUint64(&mutationCount, 1))
+}
+
 func (m *Mutation) HasParams() bool {
 	return len(m.Params) > 0
 }
@@ -91,17 +102,10 @@ func (m *Client) GetBaseInstances(idName string, ids []interface{}, simplifiedTy
 		return nil, fmt.Errorf("failed getting: %v with ids: %v error: %v", simplifiedType.Name, ids, err)
 	}
 	// fmt.Printf("getting: %v with ids: %v, query:%v\n", simplifiedType.Name, ids, query)
-	req := graphql.NewRequest(query)
-	req.Var("ids", ids)
-	var response interface{}
-	err = m.client.Run(context.Background(), req, &response)
+	data, err := m.query(queryName, query, ids)
 	if err != nil {
-		// fmt.Println("Response: ", response)
-		if isFatalError(response, err) {
-			return nil, fmt.Errorf("failed getting: %v with ids: %v, query:%v, error: %v", simplifiedType.Name, ids, query, err)
-		}
+		return nil, fmt.Errorf("failed getting: %v with ids: %v, query:%v, error: %v", simplifiedType.Name, ids, query, err)
 	}
-	data := response.(map[string]interface{})[queryName].([]interface{})
 	// fmt.Println("Response: ", response)
 	instances := make(map[interface{}]*SimplifiedBaseInstance, len(ids))
 	for _, values := range data {
@@ -112,12 +116,91 @@ func (m *Client) GetBaseInstances(idName string, ids []interface{}, simplifiedTy
 	return instances, nil
 }
 
+// Returns the instances whose value for the specified field is one of the provided values, several
+// instances can have the same value
+func (m *Client) SearchBaseInstances(fieldName string, values []interface{}, simplifiedType *SimplifiedBaseType, projection []string) ([]*SimplifiedBaseInstance, error) {
+
+	//Make sure the search field is in the projection
+	if projection != nil {
+		projection = append(projection, fieldName)
+	}
+	queryName, query, err := simplifiedType.GetSearchStmt(fieldName, projection)
+	if err != nil {
+		return nil, fmt.Errorf("failed searching: %v by: %v with values: %v error: %v", simplifiedType.Name, fieldName, values, err)
+	}
+	data, err := m.query(queryName, query, values)
+	if err != nil {
+		return nil, fmt.Errorf("failed searching: %v by: %v with values: %v, query:%v, error: %v", simplifiedType.Name, fieldName, values, query, err)
+	}
+	instances := make([]*SimplifiedBaseInstance, 0, len(data))
+	for _, v := range data {
+		instances = append(instances, NewSimplifiedBaseInstance(simplifiedType, v.(map[string]interface{})))
+	}
+	return instances, nil
+}
+
+// Returns all the instances of the type
+func (m *Client) GetAllBaseInstances(simplifiedType *SimplifiedBaseType, projection []string) ([]*SimplifiedBaseInstance, error) {
+	queryName, query := simplifiedType.GetAllStmt(projection)
+	data, err := m.query(queryName, query, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed getting all: %v, query:%v, error: %v", simplifiedType.Name, query, err)
+	}
+	instances := make([]*SimplifiedBaseInstance, 0, len(data))
+	for _, v := range data {
+		instances = append(instances, NewSimplifiedBaseInstance(simplifiedType, v.(map[string]interface{})))
+	}
+	return instances, nil
+}
+
+// Returns the number of instances of the type
+func (m *Client) Count(simplifiedType *SimplifiedBaseType) (int64, error) {
+	queryName, query := simplifiedType.GetCountStmt()
+	req := graphql.NewRequest(query)
+	var response interface{}
+	err := m.client.Run(context.Background(), req, &response)
+	if err != nil {
+		return 0, fmt.Errorf("failed counting: %v, query:%v, error: %v", simplifiedType.Name, query, err)
+	}
+	aggregate, ok := response.(map[string]interface{})[queryName].(map[string]interface{})
+	if !ok {
+		return 0, nil
+	}
+	return int64(aggregate["count"].(float64)), nil
+}
+
+// Runs the query with the provided values as the ids parameter and returns the query results
+func (m *Client) query(queryName, query string, ids []interface{}) ([]interface{}, error) {
+	req := graphql.NewRequest(query)
+	if ids != nil {
+		req.Var("ids", ids)
+	}
+	var response interface{}
+	err := m.client.Run(context.Background(), req, &response)
+	if err != nil {
+		// fmt.Println("Response: ", response)
+		if isFatalError(response, err) {
+			return nil, err
+		}
+	}
+	return response.(map[string]interface{})[queryName].([]interface{}), nil
+}
+
 func isFatalError(response interface{}, err error) bool {
 	errMsg := strings.ToLower(err.Error())
 	return response == nil || !(strings.Contains(errMsg, "non-nullable field") && strings.Contains(errMsg, "was not present in result"))
 }
 
+// Executes the mutations in a single request, mutations are aliased when built so that any mix of
+// mutations, including several on the same type, can be sent together
 func (m *Client) Mutate(mutations ...*Mutation) error {
+	aliases := make(map[string]bool, len(mutations))
+	for _, mutation := range mutations {
+		if mutation.Alias != "" && aliases[mutation.Alias] {
+			return fmt.Errorf("mutation: %v is included more than once in the request", mutation)
+		}
+		aliases[mutation.Alias] = true
+	}
 	paramStmt := &strings.Builder{}
 	mutationsStmt := &strings.Builder{}
 	for _, mutation := range mutations {
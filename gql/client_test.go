@@ -950,3 +950,109 @@ func TestMultipleMutations(t *testing.T) {
 	assertInstance(t, actualCursorInstance, cursorInstance)
 
 }
+
+func TestMultipleMutationsSameType(t *testing.T) {
+	beforeEach()
+	schema, err := gql.NewSchema("", true)
+	assert.NilError(t, err)
+	assignmentType := gql.NewSimplifiedType(
+		"Assignment",
+		map[string]*gql.SimplifiedField{
+			"assignee": {
+				Name:    "assignee",
+				Type:    "String",
+				NonNull: true,
+				Indexes: gql.NewIndexes("term"),
+			},
+			"votes": {
+				Name: "votes",
+				Type: "Int64",
+			},
+		},
+		gql.DocumentSimplifiedInterface,
+	)
+	_, err = schema.UpdateType(assignmentType)
+	assert.NilError(t, err)
+	err = admin.UpdateSchema(schema)
+	assert.NilError(t, err)
+
+	getAssignmentInstance := func(docId, assignee string, votes int64) *gql.SimplifiedInstance {
+		return gql.NewSimplifiedInstance(
+			assignmentType,
+			map[string]interface{}{
+				"docId":       docId,
+				"createdDate": "2020-11-12T18:27:47.000Z",
+				"updatedDate": "2020-11-12T19:27:47.000Z",
+				"creator":     "dao.hypha",
+				"contract":    "contract1",
+				"type":        "Assignment",
+				"assignee":    assignee,
+				"votes":       votes,
+			},
+		)
+	}
+	assignment1 := getAssignmentInstance("1", "alice", 20)
+	assignment2 := getAssignmentInstance("2", "bob", 30)
+	assignment3 := getAssignmentInstance("3", "carol", 40)
+
+	err = client.Mutate(
+		assignment1.AddMutation(false),
+		assignment2.AddMutation(false),
+		assignment3.AddMutation(false),
+	)
+	assert.NilError(t, err)
+
+	updateMutation1, err := assignmentType.UpdateMutation("docId", "1", map[string]interface{}{"votes": 21}, nil)
+	assert.NilError(t, err)
+	updateMutation2, err := assignmentType.UpdateMutation("docId", "2", map[string]interface{}{"votes": 31}, nil)
+	assert.NilError(t, err)
+	deleteMutation, err := assignment3.DeleteMutation("docId")
+	assert.NilError(t, err)
+	err = client.Mutate(
+		updateMutation1,
+		updateMutation2,
+		deleteMutation,
+		gql.NewCursorInstance("c1", "cursor1").AddMutation(true),
+	)
+	assert.NilError(t, err)
+
+	assignment1.SetValue("votes", int64(21))
+	assignment2.SetValue("votes", int64(31))
+	actual, err := client.GetOne("docId", "1", assignmentType, nil)
+	assert.NilError(t, err)
+	assertInstance(t, actual, assignment1)
+	actual, err = client.GetOne("docId", "2", assignmentType, nil)
+	assert.NilError(t, err)
+	assertInstance(t, actual, assignment2)
+	actual, err = client.GetOne("docId", "3", assignmentType, nil)
+	assert.NilError(t, err)
+	assert.Assert(t, actual == nil)
+}
+
+func TestMutationAliased(t *testing.T) {
+	assignmentType := gql.NewSimplifiedType(
+		"Assignment",
+		map[string]*gql.SimplifiedField{
+			"votes": {
+				Name: "votes",
+				Type: "Int64",
+			},
+		},
+		gql.DocumentSimplifiedInterface,
+	)
+	update1, err := assignmentType.UpdateMutation("docId", "1", map[string]interface{}{"votes": 21}, nil)
+	assert.NilError(t, err)
+	update2, err := assignmentType.UpdateMutation("docId", "2", map[string]interface{}{"votes": 31}, nil)
+	assert.NilError(t, err)
+	assert.Assert(t, update1.Alias != "")
+	assert.Assert(t, update1.Alias != update2.Alias)
+	alias := update1.Alias
+	assert.Equal(t, update1.ParamStmt, fmt.Sprintf("$idAssignment_%v: String!, $setAssignment_%v: AssignmentPatch, $removeAssignment_%v: AssignmentPatch", alias, alias, alias))
+	assert.Equal(t, update1.MutationStmt, fmt.Sprintf("%v: updateAssignment(input: { filter: { docId: { eq: $idAssignment_%v } }, set: $setAssignment_%v, remove: $removeAssignment_%v }){numUids}", alias, alias, alias, alias))
+	assert.Equal(t, len(update1.Params), 3)
+	assert.Equal(t, update1.Params["idAssignment_"+alias], "1")
+
+	add := assignmentType.AddMutation(map[string]interface{}{"docId": "3"}, true)
+	assert.Assert(t, add.Alias != update1.Alias && add.Alias != update2.Alias)
+	assert.Equal(t, add.MutationStmt, fmt.Sprintf("%v: addAssignment(input: $inputAssignment_%v, upsert: $upsertAssignment_%v){numUids}", add.Alias, add.Alias, add.Alias))
+}
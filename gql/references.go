@@ -0,0 +1,137 @@
+package gql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/machinebox/graphql"
+	"github.com/vektah/gqlparser/ast"
+)
+
+// Identifies an object field through which the instances of a type can reference other instances
+type ReferenceField struct {
+	TypeName string
+	// Name of the id field of the referencing type, defaults to the id name of the referenced type
+	IdName string
+	Field  *SimplifiedField
+}
+
+func (m *ReferenceField) String() string {
+	return fmt.Sprintf("%v.%v", m.TypeName, m.Field.Name)
+}
+
+func (m *ReferenceField) getIdName(defaultIdName string) string {
+	if m.IdName != "" {
+		return m.IdName
+	}
+	return defaultIdName
+}
+
+// Generates the gql query statement to find the instances that reference the instance with the
+// idName value specified by the id parameter through any of the fields, the results of each
+// field are returned using the r<index> alias
+func GetReferencesStmt(idName string, fields []*ReferenceField) string {
+	q := &strings.Builder{}
+	for i, field := range fields {
+		q.WriteString(fmt.Sprintf(
+			"r%v: query%v @cascade(fields: [\"%v\"]){ %v %v(filter: { %v }){ %v } }\n",
+			i,
+			field.TypeName,
+			field.Field.Name,
+			field.getIdName(idName),
+			field.Field.Name,
+			eqFilterStmt(idName, "id"),
+			idName,
+		))
+	}
+	return fmt.Sprintf(
+		`
+			query($id: String!){
+				%v
+			}
+		`,
+		q.String(),
+	)
+}
+
+// Returns the ids of the instances that reference the instance with the idName value through
+// any of the fields, by field
+func (m *Client) GetReferences(idName string, idValue interface{}, fields []*ReferenceField) (map[*ReferenceField][]interface{}, error) {
+	references := make(map[*ReferenceField][]interface{})
+	if len(fields) == 0 {
+		return references, nil
+	}
+	query := GetReferencesStmt(idName, fields)
+	req := graphql.NewRequest(query)
+	req.Var("id", idValue)
+	var response interface{}
+	err := m.client.Run(context.Background(), req, &response)
+	if err != nil && isFatalError(response, err) {
+		return nil, fmt.Errorf("failed getting references to: %v, query: %v, error: %v", idValue, query, err)
+	}
+	data := response.(map[string]interface{})
+	for i, field := range fields {
+		instances, ok := data[fmt.Sprintf("r%v", i)].([]interface{})
+		if !ok {
+			continue
+		}
+		for _, instance := range instances {
+			if id, ok := instance.(map[string]interface{})[field.getIdName(idName)]; ok && id != nil {
+				references[field] = append(references[field], id)
+			}
+		}
+	}
+	return references, nil
+}
+
+// Returns the fields through which documents can reference documents of the target type, the
+// fields are sorted by type and field name
+func (m *Schema) GetReferenceFields(targetType *SimplifiedType) ([]*ReferenceField, error) {
+	typeNames := make([]string, 0)
+	for _, typeDef := range m.Schema.Types {
+		if typeDef.Kind == ast.Object && ExtendsDocument(typeDef) {
+			typeNames = append(typeNames, typeDef.Name)
+		}
+	}
+	sort.Strings(typeNames)
+	fields := make([]*ReferenceField, 0)
+	for _, typeName := range typeNames {
+		simplifiedType, err := m.GetSimplifiedType(typeName)
+		if err != nil {
+			return nil, fmt.Errorf("failed getting type: %v, error: %v", typeName, err)
+		}
+		fieldNames := make([]string, 0, len(simplifiedType.Fields))
+		for name := range simplifiedType.Fields {
+			fieldNames = append(fieldNames, name)
+		}
+		sort.Strings(fieldNames)
+		for _, fieldName := range fieldNames {
+			field := simplifiedType.Fields[fieldName]
+			if !field.IsObject() || field.IsOwned() {
+				continue
+			}
+			if field.Type == targetType.Name || field.Type == DocumentSimplifiedInterface.Name || targetType.HasInterface(field.Type) {
+				fields = append(fields, &ReferenceField{
+					TypeName: typeName,
+					Field:    field,
+				})
+			}
+		}
+	}
+	return fields, nil
+}
+
+// Returns the name of the dgraph predicate that stores the field, fields defined by an interface
+// are stored using the interface name as prefix
+func (m *Schema) GetPredicateName(typeName, fieldName string) string {
+	if typeDef := m.GetType(typeName); typeDef != nil {
+		for _, interfaceName := range typeDef.Interfaces {
+			if interfDef := m.GetType(interfaceName); interfDef != nil && interfDef.Fields.ForName(fieldName) != nil {
+				return fmt.Sprintf("%v.%v", interfaceName, fieldName)
+			}
+		}
+	}
+	return fmt.Sprintf("%v.%v", typeName, fieldName)
+}
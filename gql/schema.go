@@ -10,10 +10,12 @@ import (
 )
 
 const (
-	GQLType_ID     = "ID"
-	GQLType_Int64  = "Int64"
-	GQLType_Time   = "DateTime"
-	GQLType_String = "String"
+	GQLType_ID      = "ID"
+	GQLType_Int64   = "Int64"
+	GQLType_Float   = "Float"
+	GQLType_Time    = "DateTime"
+	GQLType_String  = "String"
+	GQLType_Boolean = "Boolean"
 )
 
 type SchemaUpdateOp string
@@ -28,6 +30,8 @@ const (
 type Schema struct {
 	Schema          *ast.Schema
 	SimplifiedTypes map[string]*SimplifiedType
+	// Types whose instances are owned by the instances that reference them
+	OwnedTypes map[string]*SimplifiedBaseType
 }
 
 func InitialSchema() (*Schema, error) {
@@ -61,9 +65,27 @@ func NewSchema(schemaDef string, includeBaseSchema bool) (*Schema, error) {
 	return &Schema{
 		Schema:          schema,
 		SimplifiedTypes: make(map[string]*SimplifiedType),
+		OwnedTypes:      make(map[string]*SimplifiedBaseType),
 	}, nil
 }
 
+// Registers a type whose instances are owned by the instances that reference them,
+// the fields that reference the owned type are marked as owned
+func (m *Schema) AddOwnedType(ownedType *SimplifiedBaseType) {
+	m.OwnedTypes[ownedType.Name] = ownedType
+	for _, simplifiedType := range m.SimplifiedTypes {
+		m.setOwnedTypes(simplifiedType)
+	}
+}
+
+func (m *Schema) setOwnedTypes(simplifiedType *SimplifiedType) {
+	for _, field := range simplifiedType.Fields {
+		if ownedType, ok := m.OwnedTypes[field.Type]; ok {
+			field.OwnedType = ownedType
+		}
+	}
+}
+
 // Returns the simplified type for the type with the specified name
 func (m *Schema) GetSimplifiedType(name string) (*SimplifiedType, error) {
 	simplifiedType, ok := m.SimplifiedTypes[name]
@@ -77,6 +99,7 @@ func (m *Schema) GetSimplifiedType(name string) (*SimplifiedType, error) {
 		if err != nil {
 			return nil, err
 		}
+		m.setOwnedTypes(simplifiedType)
 		m.SimplifiedTypes[name] = simplifiedType
 	}
 	return simplifiedType, nil
@@ -108,7 +131,9 @@ func (m *Schema) UpdateType(newType *SimplifiedType) (SchemaUpdateOp, error) {
 	// fmt.Println("OldType: ", oldType)
 	if oldType == nil {
 		m.Schema.Types[newType.Name] = CreateType(newType)
-		m.SimplifiedTypes[newType.Name] = newType.Clone()
+		created := newType.Clone()
+		m.setOwnedTypes(created)
+		m.SimplifiedTypes[newType.Name] = created
 		return SchemaUpdateOp_Created, nil
 	}
 	toAdd, toUpdate, err := oldType.PrepareFieldUpdate(newType)
@@ -127,6 +152,7 @@ func (m *Schema) UpdateType(newType *SimplifiedType) (SchemaUpdateOp, error) {
 			*fieldDefs = append(*fieldDefs, CreateField(field))
 			oldType.Fields[field.Name] = field
 		}
+		m.setOwnedTypes(oldType)
 		updateOp = SchemaUpdateOp_Updated
 	}
 	// fmt.Println("toAdd: ", toAdd)
@@ -174,6 +200,41 @@ func (m *Schema) UpdateField(typeName string, field *SimplifiedField) (bool, err
 	return false, nil
 }
 
+// Adds the fields of the provided interface that are missing from its current definition, to
+// the interface and to the types that implement it, returns whether the schema changed
+func (m *Schema) AddInterfaceFields(simplifiedInterface *SimplifiedInterface) (bool, error) {
+	interfDef := m.GetType(simplifiedInterface.Name)
+	if interfDef == nil {
+		return false, fmt.Errorf("failed to add interface fields, definition for interface: %v not found", simplifiedInterface.Name)
+	}
+	toAdd := make([]*SimplifiedField, 0)
+	for _, field := range simplifiedInterface.Fields {
+		if interfDef.Fields.ForName(field.Name) == nil {
+			if field.NonNull {
+				return false, fmt.Errorf("can't add non null field: %v to interface: %v", field.Name, simplifiedInterface.Name)
+			}
+			toAdd = append(toAdd, field)
+		}
+	}
+	if len(toAdd) == 0 {
+		return false, nil
+	}
+	for _, field := range toAdd {
+		interfDef.Fields = append(interfDef.Fields, CreateField(field))
+	}
+	for _, typeDef := range m.Schema.Types {
+		if typeDef.Kind == ast.Object && HasInterface(typeDef, simplifiedInterface.Name) {
+			for _, field := range toAdd {
+				_, err := m.UpdateField(typeDef.Name, field)
+				if err != nil {
+					return false, fmt.Errorf("failed to add interface field: %v to type: %v, error: %v", field.Name, typeDef.Name, err)
+				}
+			}
+		}
+	}
+	return true, nil
+}
+
 // Returns whether the type extends from document
 func ExtendsDocument(typeDef *ast.Definition) bool {
 	return HasInterface(typeDef, "Document")
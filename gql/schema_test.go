@@ -0,0 +1,169 @@
+package gql_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
+	tutil "github.com/sebastianmontero/hypha-document-cache-gql-go/test/util"
+	"gotest.tools/assert"
+)
+
+func TestAddInterfaceFields(t *testing.T) {
+	schema, err := gql.LoadSchema(`
+		interface Document @withSubscription {
+			docId: String! @id @search(by: [exact])
+			type: String! @search(by: [exact])
+			creator: String! @search(by: [exact, regexp])
+			createdDate: DateTime! @search(by: [hour])
+			updatedDate: DateTime! @search(by: [hour])
+			contract: String! @search(by: [exact])
+		}
+
+		type Period implements Document @withSubscription {
+			docId: String! @id @search(by: [exact])
+			type: String! @search(by: [exact])
+			creator: String! @search(by: [exact, regexp])
+			createdDate: DateTime! @search(by: [hour])
+			updatedDate: DateTime! @search(by: [hour])
+			contract: String! @search(by: [exact])
+			details_number_i: Int64 @search(by: [int64])
+		}
+
+		type Cursor {
+			id: String! @id @search(by: [exact])
+			cursor: String!
+		}
+	`)
+	assert.NilError(t, err)
+
+	updated, err := schema.AddInterfaceFields(gql.DocumentSimplifiedInterface)
+	assert.NilError(t, err)
+	assert.Assert(t, updated)
+	tutil.AssertInterface(t, gql.DocumentSimplifiedInterface, schema)
+	periodType := gql.NewSimplifiedType(
+		"Period",
+		map[string]*gql.SimplifiedField{
+			"details_number_i": {
+				Name:    "details_number_i",
+				Type:    gql.GQLType_Int64,
+				Indexes: gql.NewIndexes("int64"),
+			},
+		},
+		gql.DocumentSimplifiedInterface,
+	)
+	tutil.AssertType(t, periodType, schema)
+	cursorType, err := schema.GetSimplifiedType("Cursor")
+	assert.NilError(t, err)
+	assert.Assert(t, !cursorType.HasField("hash"))
+
+	updated, err = schema.AddInterfaceFields(gql.DocumentSimplifiedInterface)
+	assert.NilError(t, err)
+	assert.Assert(t, !updated)
+}
+
+func TestGetSearchStmt(t *testing.T) {
+	_, _, err := gql.DocumentSimplifiedInterface.GetSearchStmt("hash", nil)
+	assert.NilError(t, err)
+	_, _, err = gql.DocumentSimplifiedInterface.GetSearchStmt("createdDate", nil)
+	assert.ErrorContains(t, err, "can not be used to search")
+	_, _, err = gql.DocumentSimplifiedInterface.GetSearchStmt("title", nil)
+	assert.ErrorContains(t, err, "does not have field")
+}
+
+func TestGetReferenceFields(t *testing.T) {
+	schema, err := gql.LoadSchema(`
+		interface Document @withSubscription {
+			docId: String! @id @search(by: [exact])
+			type: String! @search(by: [exact])
+		}
+
+		interface Votable {
+			vote: [Document]
+		}
+
+		type Member implements Document @withSubscription {
+			docId: String! @id @search(by: [exact])
+			type: String! @search(by: [exact])
+			period: [Period]
+		}
+
+		type Period implements Document & Votable @withSubscription {
+			docId: String! @id @search(by: [exact])
+			type: String! @search(by: [exact])
+			vote: [Document]
+			member: [Member]
+			details_startPeriod_c_edge: Period
+			details_number_i: Int64 @search(by: [int64])
+		}
+	`)
+	assert.NilError(t, err)
+	memberType, err := schema.GetSimplifiedType("Member")
+	assert.NilError(t, err)
+	fields, err := schema.GetReferenceFields(memberType)
+	assert.NilError(t, err)
+	assert.Equal(t, len(fields), 2)
+	assert.Equal(t, fields[0].String(), "Period.member")
+	assert.Equal(t, fields[1].String(), "Period.vote")
+	assert.Equal(t, schema.GetPredicateName("Period", "member"), "Period.member")
+	assert.Equal(t, schema.GetPredicateName("Period", "vote"), "Votable.vote")
+	assert.Equal(t, schema.GetPredicateName("Period", "docId"), "Document.docId")
+
+	periodType, err := schema.GetSimplifiedType("Period")
+	assert.NilError(t, err)
+	fields, err = schema.GetReferenceFields(periodType)
+	assert.NilError(t, err)
+	assert.Equal(t, len(fields), 3)
+	assert.Equal(t, fields[0].String(), "Member.period")
+	assert.Equal(t, fields[1].String(), "Period.details_startPeriod_c_edge")
+	assert.Equal(t, fields[2].String(), "Period.vote")
+	stmt := gql.GetReferencesStmt("docId", fields)
+	assert.Assert(t, strings.Contains(stmt, `r1: queryPeriod @cascade(fields: ["details_startPeriod_c_edge"]){ docId details_startPeriod_c_edge(filter: { docId: { eq: $id } }){ docId } }`))
+}
+
+func TestAddOwnedType(t *testing.T) {
+	schema, err := gql.LoadSchema(`
+		interface Document @withSubscription {
+			docId: String! @id @search(by: [exact])
+			type: String! @search(by: [exact])
+		}
+
+		type Note {
+			id: String! @id
+			text: String
+		}
+
+		type Member implements Document @withSubscription {
+			docId: String! @id @search(by: [exact])
+			type: String! @search(by: [exact])
+			notes: [Note]
+		}
+
+		type Period implements Document @withSubscription {
+			docId: String! @id @search(by: [exact])
+			type: String! @search(by: [exact])
+			member: [Member]
+			notes: [Note]
+		}
+	`)
+	assert.NilError(t, err)
+	memberType, err := schema.GetSimplifiedType("Member")
+	assert.NilError(t, err)
+	assert.Assert(t, !memberType.GetField("notes").IsOwned())
+	noteType, err := schema.GetSimplifiedType("Note")
+	assert.NilError(t, err)
+	schema.AddOwnedType(noteType.SimplifiedBaseType)
+	assert.Assert(t, memberType.GetField("notes").IsOwned())
+
+	periodType, err := schema.GetSimplifiedType("Period")
+	assert.NilError(t, err)
+	assert.Assert(t, periodType.GetField("notes").IsOwned())
+	assert.Assert(t, !periodType.GetField("member").IsOwned())
+	_, stmt := periodType.GetAllStmt(nil)
+	assert.Assert(t, strings.Contains(stmt, "notes{id text }") || strings.Contains(stmt, "notes{text id }"))
+	assert.Assert(t, strings.Contains(stmt, "member{docId}"))
+
+	fields, err := schema.GetReferenceFields(noteType)
+	assert.NilError(t, err)
+	assert.Equal(t, len(fields), 0)
+}
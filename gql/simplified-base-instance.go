@@ -31,6 +31,9 @@ func (m *SimplifiedBaseInstance) GetValue(name string) interface{} {
 		case GQLType_Int64:
 			intValue, _ := strconv.ParseInt(fmt.Sprintf("%v", value), 10, 64)
 			return intValue
+		case GQLType_Float:
+			floatValue, _ := strconv.ParseFloat(fmt.Sprintf("%v", value), 64)
+			return floatValue
 		case GQLType_Time:
 			return util.ToTime(fmt.Sprintf("%v", value))
 		default:
@@ -41,6 +41,19 @@ func (m *SimplifiedBaseType) GetIdField(name string) (*SimplifiedField, error) {
 	}
 }
 
+// Returns the field with the specified name if it can be used to search for instances, the
+// field has to be an id or have an index that supports equality filters
+func (m *SimplifiedBaseType) GetSearchField(name string) (*SimplifiedField, error) {
+	field := m.GetField(name)
+	if field == nil {
+		return nil, fmt.Errorf("type: %v does not have field: %v", m.Name, name)
+	}
+	if field.IsID || field.Indexes.Has("exact") || field.Indexes.Has("hash") {
+		return field, nil
+	}
+	return nil, fmt.Errorf("field: %v in type: %v can not be used to search, it is not an ID and does not have an exact or hash index", name, m.Name)
+}
+
 // Returns non edge fields
 func (m *SimplifiedBaseType) GetCoreFields() []string {
 	coreFields := make([]string, 0)
@@ -77,6 +90,56 @@ func (m *SimplifiedBaseType) GetStmt(idName string, projection []string) (string
 	if err != nil {
 		return "", "", err
 	}
+	queryName, stmt := m.getInStmt(id, projection)
+	return queryName, stmt, nil
+}
+
+// Generates the gql query statement to return the instances of this type whose field
+// value is one of the provided values
+func (m *SimplifiedBaseType) GetSearchStmt(fieldName string, projection []string) (string, string, error) {
+
+	field, err := m.GetSearchField(fieldName)
+	if err != nil {
+		return "", "", err
+	}
+	queryName, stmt := m.getInStmt(field, projection)
+	return queryName, stmt, nil
+}
+
+// Generates the gql query statement to return all the instances of this type
+func (m *SimplifiedBaseType) GetAllStmt(projection []string) (string, string) {
+	queryName := fmt.Sprintf("query%v", m.Name)
+	stmt := fmt.Sprintf(
+		`
+			query{
+				%v{
+					%v
+				}
+			}
+		`,
+		queryName,
+		queryFieldsStmt(m.Fields, projection),
+	)
+	return queryName, stmt
+}
+
+// Generates the gql query statement to count the instances of this type
+func (m *SimplifiedBaseType) GetCountStmt() (string, string) {
+	queryName := fmt.Sprintf("aggregate%v", m.Name)
+	stmt := fmt.Sprintf(
+		`
+			query{
+				%v{
+					count
+				}
+			}
+		`,
+		queryName,
+	)
+	return queryName, stmt
+}
+
+func (m *SimplifiedBaseType) getInStmt(field *SimplifiedField, projection []string) (string, string) {
 	queryName := fmt.Sprintf("query%v", m.Name)
 	stmt := fmt.Sprintf(
 		`
@@ -86,13 +149,13 @@ func (m *SimplifiedBaseType) GetStmt(idName string, projection []string) (string
 				}
 			}
 		`,
-		id.Type,
+		field.Type,
 		queryName,
-		inFilterStmt(id.Name, "ids"),
+		inFilterStmt(field.Name, "ids"),
 		queryFieldsStmt(m.Fields, projection),
 	)
 
-	return queryName, stmt, nil
+	return queryName, stmt
 }
 
 // Compares the current type with the provided type compares them and
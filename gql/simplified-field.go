@@ -71,6 +71,10 @@ type SimplifiedField struct {
 	NonNull bool
 	Indexes Indexes
 	IsArray bool
+	// The type of the objects referenced by the field when they are owned by the instance
+	// that references them, owned objects are returned along with the instance and are
+	// not considered references to other instances
+	OwnedType *SimplifiedBaseType
 }
 
 func NewSimplifiedField(fieldDef *ast.FieldDefinition) (*SimplifiedField, error) {
@@ -103,19 +107,28 @@ func NewEdgeField(edgeName, edgeType string) *SimplifiedField {
 	}
 }
 
+// Creates a field that references a single object of the specified type
+func NewCoreEdgeField(edgeName, edgeType string) *SimplifiedField {
+	return &SimplifiedField{
+		Name: edgeName,
+		Type: edgeType,
+	}
+}
+
 func (m *SimplifiedField) Clone() *SimplifiedField {
 	return &SimplifiedField{
-		IsID:    m.IsID,
-		Name:    m.Name,
-		Type:    m.Type,
-		NonNull: m.NonNull,
-		Indexes: m.Indexes.Clone(),
-		IsArray: m.IsArray,
+		IsID:      m.IsID,
+		Name:      m.Name,
+		Type:      m.Type,
+		NonNull:   m.NonNull,
+		Indexes:   m.Indexes.Clone(),
+		IsArray:   m.IsArray,
+		OwnedType: m.OwnedType,
 	}
 }
 
 func (m *SimplifiedField) IsObject() bool {
-	return m.Type != GQLType_Int64 && m.Type != GQLType_String && m.Type != GQLType_Time && m.Type != GQLType_ID
+	return m.Type != GQLType_Int64 && m.Type != GQLType_Float && m.Type != GQLType_String && m.Type != GQLType_Time && m.Type != GQLType_ID && m.Type != GQLType_Boolean
 }
 
 func (m *SimplifiedField) IsCoreEdge() bool {
@@ -126,6 +139,11 @@ func (m *SimplifiedField) IsEdge() bool {
 	return m.IsArray && m.IsObject()
 }
 
+// Indicates whether the objects referenced by the field are owned by the instance
+func (m *SimplifiedField) IsOwned() bool {
+	return m.OwnedType != nil
+}
+
 func (m *SimplifiedField) equal(field *SimplifiedField) bool {
 	return m.IsID == field.IsID && m.Name == field.Name && m.Type == field.Type &&
 		m.NonNull == field.NonNull && m.IsArray == field.IsArray && m.Indexes.Equal(field.Indexes)
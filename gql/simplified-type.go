@@ -161,9 +161,11 @@ func (m *SimplifiedType) String() string {
 
 // Generates the statment required to add an object of this type to the db
 func (m *SimplifiedType) AddMutation(values map[string]interface{}, upsert bool) *Mutation {
-	inputParamName := m.addInputParamNameStmt()
-	upsertParamName := m.upsertParamNameStmt()
+	alias := newMutationAlias()
+	inputParamName := m.addInputParamNameStmt(alias)
+	upsertParamName := m.upsertParamNameStmt(alias)
 	return &Mutation{
+		Alias: alias,
 		ParamStmt: fmt.Sprintf(
 			"$%v: %v, $%v: Boolean",
 			inputParamName,
@@ -171,7 +173,8 @@ func (m *SimplifiedType) AddMutation(values map[string]interface{}, upsert bool)
 			upsertParamName,
 		),
 		MutationStmt: fmt.Sprintf(
-			"add%v(input: $%v, upsert: $%v){numUids}",
+			"%v: add%v(input: $%v, upsert: $%v){numUids}",
+			alias,
 			m.Name,
 			inputParamName,
 			upsertParamName,
@@ -190,19 +193,22 @@ func (m *SimplifiedType) addInputParamTypeStmt() string {
 	)
 }
 
-func (m *SimplifiedType) addInputParamNameStmt() string {
-	return m.nameStmt("input")
+func (m *SimplifiedType) addInputParamNameStmt(alias string) string {
+	return m.nameStmt("input", alias)
 }
 
-func (m *SimplifiedType) upsertParamNameStmt() string {
-	return m.nameStmt("upsert")
+func (m *SimplifiedType) upsertParamNameStmt(alias string) string {
+	return m.nameStmt("upsert", alias)
 }
 
-func (m *SimplifiedType) nameStmt(param string) string {
+// Parameter names are suffixed with the alias of the mutation, so that they are unique
+// within a request
+func (m *SimplifiedType) nameStmt(param, alias string) string {
 	return fmt.Sprintf(
-		"%v%v",
+		"%v%v_%v",
 		param,
 		m.Name,
+		alias,
 	)
 }
 
@@ -212,11 +218,13 @@ func (m *SimplifiedType) UpdateMutation(idName string, idValue interface{}, set,
 	if err != nil {
 		return nil, err
 	}
-	idParamName := m.idParamNameStmt()
-	setParamName := m.setParamNameStmt()
-	removeParamName := m.removeParamNameStmt()
+	alias := newMutationAlias()
+	idParamName := m.idParamNameStmt(alias)
+	setParamName := m.setParamNameStmt(alias)
+	removeParamName := m.removeParamNameStmt(alias)
 	patchParamType := m.patchParamTypeStmt()
 	return &Mutation{
+		Alias: alias,
 		ParamStmt: fmt.Sprintf(
 			"$%v: %v!, $%v: %v, $%v: %v",
 			idParamName,
@@ -227,7 +235,8 @@ func (m *SimplifiedType) UpdateMutation(idName string, idValue interface{}, set,
 			patchParamType,
 		),
 		MutationStmt: fmt.Sprintf(
-			"update%v(input: { filter: { %v }, set: $%v, remove: $%v }){numUids}",
+			"%v: update%v(input: { filter: { %v }, set: $%v, remove: $%v }){numUids}",
+			alias,
 			m.Name,
 			eqFilterStmt(idField.Name, idParamName),
 			setParamName,
@@ -247,15 +256,18 @@ func (m *SimplifiedType) DeleteMutation(idName string, idValue interface{}) (*Mu
 	if err != nil {
 		return nil, err
 	}
-	idParamName := m.idParamNameStmt()
+	alias := newMutationAlias()
+	idParamName := m.idParamNameStmt(alias)
 	return &Mutation{
+		Alias: alias,
 		ParamStmt: fmt.Sprintf(
 			"$%v: %v!",
 			idParamName,
 			idField.Type,
 		),
 		MutationStmt: fmt.Sprintf(
-			"delete%v(filter: { %v }){numUids}",
+			"%v: delete%v(filter: { %v }){numUids}",
+			alias,
 			m.Name,
 			eqFilterStmt(idField.Name, idParamName),
 		),
@@ -265,16 +277,16 @@ func (m *SimplifiedType) DeleteMutation(idName string, idValue interface{}) (*Mu
 	}, nil
 }
 
-func (m *SimplifiedType) idParamNameStmt() string {
-	return m.nameStmt("id")
+func (m *SimplifiedType) idParamNameStmt(alias string) string {
+	return m.nameStmt("id", alias)
 }
 
-func (m *SimplifiedType) setParamNameStmt() string {
-	return m.nameStmt("set")
+func (m *SimplifiedType) setParamNameStmt(alias string) string {
+	return m.nameStmt("set", alias)
 }
 
-func (m *SimplifiedType) removeParamNameStmt() string {
-	return m.nameStmt("remove")
+func (m *SimplifiedType) removeParamNameStmt(alias string) string {
+	return m.nameStmt("remove", alias)
 }
 
 func (m *SimplifiedType) patchParamTypeStmt() string {
@@ -312,6 +324,10 @@ func queryFieldsStmt(fields map[string]*SimplifiedField, projection []string) st
 }
 
 func queryFieldStmt(field *SimplifiedField) string {
+	//Owned objects are part of the instance, so all their values are returned
+	if field.IsOwned() {
+		return fmt.Sprintf("%v{%v}", field.Name, strings.ReplaceAll(queryFieldsStmt(field.OwnedType.Fields, nil), "\n", " "))
+	}
 	if field.IsObject() {
 		return fmt.Sprintf("%v{docId}", field.Name)
 	} else {
@@ -22,10 +22,50 @@ var (
 		Name: "hypha_graph_document_cache_deleted_edges",
 		Help: "# of deleted edges",
 	})
+	UpdatedEdges = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hypha_graph_document_cache_updated_edges",
+		Help: "# of updated edges",
+	})
 	BlockNumber = promauto.NewGauge(prometheus.GaugeOpts{
 		Name: "hypha_graph_document_cache_block_number",
 		Help: "Block Number",
 	})
+	HeadLag = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hypha_graph_document_cache_head_lag_seconds",
+		Help: "Seconds elapsed since the time of the last processed block, shows how far behind head the cache runs",
+	})
+	StreamStalls = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hypha_graph_document_cache_stream_stalls",
+		Help: "# of times no deltas or heart beats were received within the stall timeout",
+	})
+	StreamReconnects = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hypha_graph_document_cache_stream_reconnects",
+		Help: "# of times the stream was restarted from the last cursor after stalling or failing",
+	})
+	DeadLetters = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hypha_graph_document_cache_dead_letters",
+		Help: "# of deltas that could not be processed and were recorded in the dead letter store",
+	})
+	FailedDeltaRetries = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hypha_graph_document_cache_failed_delta_retries",
+		Help: "# of times the processing of a delta was retried after a failure",
+	})
+	CapturedRecords = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hypha_graph_document_cache_captured_records",
+		Help: "# of deltas and heart beats recorded in the capture archive",
+	})
+	UndoneBlocks = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hypha_graph_document_cache_undone_blocks",
+		Help: "# of blocks undone using the undo journal",
+	})
+	PendingEdges = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "hypha_graph_document_cache_pending_edges",
+		Help: "# of edges waiting for their FROM or TO documents to be stored",
+	})
+	ReversedUndoDeltas = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "hypha_graph_document_cache_reversed_undo_deltas",
+		Help: "# of undo deltas processed as reversed operations because there was no undo journal for the block",
+	})
 )
 
 // func init() {
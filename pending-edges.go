@@ -0,0 +1,20 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache"
+)
+
+// Prints the edges whose FROM or TO documents do not exist yet
+func printPendingEdges(cache *doccache.Doccache) error {
+	pendingEdges, err := cache.GetPendingEdges()
+	if err != nil {
+		return err
+	}
+	for _, pendingEdge := range pendingEdges {
+		fmt.Println(pendingEdge)
+	}
+	log.Infof("Pending edges: %v", len(pendingEdges))
+	return nil
+}
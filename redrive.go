@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/deadletter"
+)
+
+// Reprocesses the deltas stored in the dead letter store, the deltas that are successfully
+// processed are removed from the store, the ones that fail again are kept with the updated
+// error. The cursor is not modified, so the stream process should not be running while
+// redriving. Returns the number of dead letters that remain in the store
+func (m *deltaStreamHandler) redriveDeadLetters() (int, error) {
+	deadLetters, err := m.deadLetters.Load()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load dead letters, error: %v", err)
+	}
+	log.Infof("Redriving %v dead letters from: %v", len(deadLetters), m.deadLetters.FilePath)
+	// Redriven deltas are not part of the current block, so they must not be undoable
+	m.doccache.SetBlock(0)
+	cursor := m.doccache.Cursor.GetValue("cursor").(string)
+	remaining := make([]*deadletter.DeadLetter, 0)
+	for _, deadLetter := range deadLetters {
+		delta, err := deadLetter.Delta.ToTableDelta()
+		if err != nil {
+			return 0, fmt.Errorf("failed to redrive dead letter: %v, error: %v", deadLetter, err)
+		}
+		err = m.processDelta(delta, cursor)
+		if err != nil {
+			log.Errorf(err, "Failed to redrive dead letter: %v", deadLetter)
+			deadLetter.Error = err.Error()
+			deadLetter.Attempts++
+			remaining = append(remaining, deadLetter)
+		} else {
+			log.Infof("Redrove dead letter: %v", deadLetter)
+		}
+	}
+	err = m.deadLetters.Replace(remaining)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update dead letter store, error: %v", err)
+	}
+	log.Infof("Redrove %v dead letters, %v remaining", len(deadLetters)-len(remaining), len(remaining))
+	return len(remaining), nil
+}
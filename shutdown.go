@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Exit codes of the stream process
+const (
+	// The stream completed or the process was stopped by a signal, and the cursor was stored
+	ExitCode_Success = 0
+	// The stream ended because of an error
+	ExitCode_StreamFailed = 1
+	// The process was stopped by a signal but the pending changes or the cursor could not be stored
+	ExitCode_ShutdownFailed = 3
+)
+
+// Stops the processing of the stream, waits for the in flight delta to be processed, stores the
+// pending changes and the last cursor, and closes the capture archive. Any deltas received afterwards
+// are ignored
+func (m *deltaStreamHandler) shutdown() error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.stopped = true
+	err := m.storeProgress()
+	if err != nil {
+		return err
+	}
+	m.closeCapture()
+	return nil
+}
+
+// Commits the pending changes and stores the last cursor
+func (m *deltaStreamHandler) storeProgress() error {
+	err := m.doccache.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to commit changes for block: %v, error: %v", m.batchBlock, err)
+	}
+	m.batchBlock = 0
+	m.lastBlockOp = nil
+	if m.cursor != "" {
+		err = m.doccache.UpdateCursor(m.cursor)
+		if err != nil {
+			return fmt.Errorf("failed to update cursor: %v, error: %v", m.cursor, err)
+		}
+	}
+	return nil
+}
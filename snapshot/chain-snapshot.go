@@ -0,0 +1,141 @@
+package snapshot
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	eos "github.com/eoscanada/eos-go"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/stream"
+)
+
+// Number of rows requested per page when not specified
+const DefaultPageSize uint32 = 100
+
+type getInfoResponse struct {
+	HeadBlockNum             uint64 `json:"head_block_num"`
+	LastIrreversibleBlockNum uint64 `json:"last_irreversible_block_num"`
+}
+
+type getTableRowsRequest struct {
+	Code       string `json:"code"`
+	Scope      string `json:"scope"`
+	Table      string `json:"table"`
+	LowerBound string `json:"lower_bound,omitempty"`
+	Limit      uint32 `json:"limit"`
+	JSON       bool   `json:"json"`
+}
+
+type getTableRowsResponse struct {
+	Rows    []string `json:"rows"`
+	More    bool     `json:"more"`
+	NextKey string   `json:"next_key"`
+}
+
+// Reads the current state of contract tables through the chain API, the rows are requested in
+// their binary form and decoded using the contract ABI, so that they have the same format as the
+// rows provided by the delta sources
+type ChainSnapshot struct {
+	Endpoint string
+	Decoder  stream.RowDecoder
+	PageSize uint32
+	client   *http.Client
+}
+
+func NewChainSnapshot(endpoint string, decoder stream.RowDecoder, pageSize uint32) *ChainSnapshot {
+	if pageSize == 0 {
+		pageSize = DefaultPageSize
+	}
+	return &ChainSnapshot{
+		Endpoint: strings.TrimRight(endpoint, "/"),
+		Decoder:  decoder,
+		PageSize: pageSize,
+		client:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Returns the current last irreversible block number of the chain
+func (m *ChainSnapshot) GetLastIrreversibleBlockNum() (uint64, error) {
+	info := &getInfoResponse{}
+	err := m.post("get_info", nil, info)
+	if err != nil {
+		return 0, err
+	}
+	return info.LastIrreversibleBlockNum, nil
+}
+
+// Pages through the table rows in primary key order, calling the row function with the JSON
+// representation of each one, returns the number of rows read
+func (m *ChainSnapshot) ReadTable(code, table string, rowFn func(data []byte) error) (int, error) {
+	request := &getTableRowsRequest{
+		Code:  code,
+		Scope: code,
+		Table: table,
+		Limit: m.PageSize,
+		JSON:  false,
+	}
+	count := 0
+	for {
+		response := &getTableRowsResponse{}
+		err := m.post("get_table_rows", request, response)
+		if err != nil {
+			return count, err
+		}
+		for _, row := range response.Rows {
+			binary, err := hex.DecodeString(row)
+			if err != nil {
+				return count, fmt.Errorf("failed to decode hex row of table: %v, row: %v, error: %v", table, row, err)
+			}
+			data, err := m.Decoder.Decode(eos.AccountName(code), eos.TableName(table), binary)
+			if err != nil {
+				return count, fmt.Errorf("failed to decode row of table: %v, row: %v, error: %v", table, row, err)
+			}
+			err = rowFn(data)
+			if err != nil {
+				return count, err
+			}
+			count++
+		}
+		if !response.More {
+			return count, nil
+		}
+		if response.NextKey == "" {
+			return count, fmt.Errorf("chain API did not provide the next key for table: %v, lower bound: %v", table, request.LowerBound)
+		}
+		request.LowerBound = response.NextKey
+	}
+}
+
+func (m *ChainSnapshot) post(method string, request, response interface{}) error {
+	body := []byte("{}")
+	if request != nil {
+		var err error
+		body, err = json.Marshal(request)
+		if err != nil {
+			return fmt.Errorf("failed to encode %v request: %v, error: %v", method, request, err)
+		}
+	}
+	url := fmt.Sprintf("%v/v1/chain/%v", m.Endpoint, method)
+	resp, err := m.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to call %v, error: %v", url, err)
+	}
+	defer resp.Body.Close()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %v response, error: %v", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("call to %v failed, status: %v, body: %v", url, resp.Status, string(respBody))
+	}
+	err = json.Unmarshal(respBody, response)
+	if err != nil {
+		return fmt.Errorf("failed to decode %v response: %v, error: %v", url, string(respBody), err)
+	}
+	return nil
+}
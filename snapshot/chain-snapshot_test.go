@@ -0,0 +1,152 @@
+package snapshot_test
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	eos "github.com/eoscanada/eos-go"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/snapshot"
+	"gotest.tools/assert"
+)
+
+const testABI = `{
+	"version": "eosio::abi/1.1",
+	"structs": [
+		{"name": "document", "base": "", "fields": [{"name": "id", "type": "uint64"}, {"name": "creator", "type": "name"}]}
+	],
+	"tables": [
+		{"name": "documents", "index_type": "i64", "type": "document"}
+	]
+}`
+
+type abiDecoder struct {
+	abi *eos.ABI
+}
+
+func (m *abiDecoder) Decode(account eos.AccountName, table eos.TableName, data []byte) ([]byte, error) {
+	return m.abi.DecodeTableRowTyped(m.abi.TableForName(table).Type, data)
+}
+
+// Stand in for the chain API, serves the documents table rows with ids from 1 to numRows
+type chainAPI struct {
+	t           *testing.T
+	abi         *eos.ABI
+	numRows     int
+	lowerBounds []string
+}
+
+func (m *chainAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v1/chain/get_info":
+		fmt.Fprint(w, `{"head_block_num":1500,"last_irreversible_block_num":1170}`)
+	case "/v1/chain/get_table_rows":
+		request := make(map[string]interface{})
+		err := json.NewDecoder(r.Body).Decode(&request)
+		assert.NilError(m.t, err)
+		assert.Equal(m.t, request["json"], false)
+		if request["table"] != "documents" {
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"code":500,"message":"Table not found"}`)
+			return
+		}
+		lowerBound, _ := request["lower_bound"].(string)
+		m.lowerBounds = append(m.lowerBounds, lowerBound)
+		start := 1
+		if lowerBound != "" {
+			start, err = strconv.Atoi(lowerBound)
+			assert.NilError(m.t, err)
+		}
+		end := start + int(request["limit"].(float64))
+		if end > m.numRows+1 {
+			end = m.numRows + 1
+		}
+		rows := make([]string, 0)
+		for id := start; id < end; id++ {
+			row, err := m.abi.EncodeTable("documents", []byte(fmt.Sprintf(`{"id":%v,"creator":"dao.hypha"}`, id)))
+			assert.NilError(m.t, err)
+			rows = append(rows, hex.EncodeToString(row))
+		}
+		response := map[string]interface{}{
+			"rows": rows,
+			"more": end <= m.numRows,
+		}
+		if end <= m.numRows {
+			response["next_key"] = strconv.Itoa(end)
+		}
+		err = json.NewEncoder(w).Encode(response)
+		assert.NilError(m.t, err)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newChainSnapshot(t *testing.T, numRows int, pageSize uint32) (*snapshot.ChainSnapshot, *chainAPI) {
+	abi, err := eos.NewABI(strings.NewReader(testABI))
+	assert.NilError(t, err)
+	api := &chainAPI{
+		t:       t,
+		abi:     abi,
+		numRows: numRows,
+	}
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	return snapshot.NewChainSnapshot(server.URL+"/", &abiDecoder{abi: abi}, pageSize), api
+}
+
+func TestGetLastIrreversibleBlockNum(t *testing.T) {
+	chainSnapshot, _ := newChainSnapshot(t, 0, 0)
+	lastIrreversibleBlockNum, err := chainSnapshot.GetLastIrreversibleBlockNum()
+	assert.NilError(t, err)
+	assert.Equal(t, lastIrreversibleBlockNum, uint64(1170))
+}
+
+func TestReadTable(t *testing.T) {
+	chainSnapshot, api := newChainSnapshot(t, 5, 2)
+	rows := make([]string, 0)
+	count, err := chainSnapshot.ReadTable("dao.hypha", "documents", func(data []byte) error {
+		rows = append(rows, string(data))
+		return nil
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, count, 5)
+	assert.DeepEqual(t, api.lowerBounds, []string{"", "3", "5"})
+	assert.Equal(t, len(rows), 5)
+	for i, row := range rows {
+		assert.Equal(t, row, fmt.Sprintf(`{"creator":"dao.hypha","id":%v}`, i+1))
+	}
+}
+
+func TestReadTableEmpty(t *testing.T) {
+	chainSnapshot, _ := newChainSnapshot(t, 0, 0)
+	count, err := chainSnapshot.ReadTable("dao.hypha", "documents", func(data []byte) error {
+		return fmt.Errorf("no rows expected")
+	})
+	assert.NilError(t, err)
+	assert.Equal(t, count, 0)
+}
+
+func TestReadTableShouldStopOnRowError(t *testing.T) {
+	chainSnapshot, _ := newChainSnapshot(t, 5, 2)
+	count, err := chainSnapshot.ReadTable("dao.hypha", "documents", func(data []byte) error {
+		if strings.Contains(string(data), `"id":4`) {
+			return fmt.Errorf("failed to store row")
+		}
+		return nil
+	})
+	assert.ErrorContains(t, err, "failed to store row")
+	assert.Equal(t, count, 3)
+}
+
+func TestReadTableShouldFailForChainAPIError(t *testing.T) {
+	chainSnapshot, _ := newChainSnapshot(t, 5, 2)
+	_, err := chainSnapshot.ReadTable("dao.hypha", "edges", func(data []byte) error {
+		return nil
+	})
+	assert.ErrorContains(t, err, "Table not found")
+}
@@ -2,18 +2,25 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
+	"fmt"
 	"os"
+	"sync"
+	"time"
 
 	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
 	"github.com/rs/zerolog"
 	"github.com/sebastianmontero/dfuse-firehose-client/dfclient"
 	"github.com/sebastianmontero/dgraph-go-client/dgraph"
 	"github.com/sebastianmontero/hypha-document-cache-gql-go/config"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/deadletter"
 	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache"
 	"github.com/sebastianmontero/hypha-document-cache-gql-go/doccache/domain"
 	"github.com/sebastianmontero/hypha-document-cache-gql-go/gql"
 	"github.com/sebastianmontero/hypha-document-cache-gql-go/monitoring"
 	"github.com/sebastianmontero/hypha-document-cache-gql-go/monitoring/metrics"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/snapshot"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/stream"
 	"github.com/sebastianmontero/slog-go/slog"
 	"github.com/streamingfast/bstream"
 	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
@@ -25,6 +32,14 @@ var (
 	log *slog.Log
 )
 
+// Commits the changes batched for a block, implemented by the doccache
+type blockCommitter interface {
+	Flush() error
+	DiscardBatch(blockNum uint64)
+	UpdateCursor(cursor string) error
+	PendingEdgeCount() int64
+}
+
 // Dfuse stream handler, processes the table deltas and calls the correct docache methods
 // based on the delta type
 type deltaStreamHandler struct {
@@ -32,8 +47,33 @@ type deltaStreamHandler struct {
 	cursor string
 	// Processes the operations indicated by the table deltas and updates dgraph to reflect these changes
 	doccache *doccache.Doccache
+	// Commits the batched changes, the doccache unless a test replaces it
+	committer blockCommitter
 	// Stores the initial configuration information
 	config *config.Config
+	// Last block reverted using the undo journal, the rest of its undo deltas are skipped
+	undoneBlock uint64
+	// Stores the deltas that could not be processed
+	deadLetters *deadletter.Store
+	// Block whose changes are being batched
+	batchBlock uint64
+	// Last operation of interest of the batched block, once it is processed the batch is committed
+	lastBlockOp *pbcodec.DBOp
+	// Time of the batched block, used to report how far behind head the cache runs
+	batchBlockTime time.Time
+	// Deltas of the batched block, recorded in the dead letter store if the batch can not be committed
+	batchDeltas []*stream.DeltaRecord
+	// Serializes the processing of the stream, the shutdown and the reconnections, so that the
+	// in flight delta is completed before the stream is stopped
+	lock sync.Mutex
+	// Indicates the process is shutting down, deltas received afterwards are ignored
+	stopped bool
+	// Indicates the requested stream completed
+	completed bool
+	// Last time a stream event was processed, used to detect stalled streams
+	lastActivity time.Time
+	// Records the received stream for debugging purposes, nil if capture is not enabled
+	capture *stream.Archive
 }
 
 // Called every time there is a table delta of interest, determines what the operation is and calls the
@@ -41,28 +81,157 @@ type deltaStreamHandler struct {
 func (m *deltaStreamHandler) OnDelta(delta *dfclient.TableDelta, cursor string, forkStep pbbstream.ForkStep) {
 	log.Debugf("On Delta: \nCursor: %v \nFork Step: %v \nDelta %v ", cursor, forkStep, delta)
 	log.Debugf("Doc table name: %v ", m.config.DocTableName)
+	record := stream.NewDeltaRecord(delta, cursor, forkStep)
+	m.captureRecord(record)
+	blockNum := uint64(delta.Block.Number)
+	if forkStep == pbbstream.ForkStep_STEP_UNDO {
+		m.flush()
+		if m.undoBlock(blockNum, cursor) {
+			return
+		}
+	} else {
+		m.undoneBlock = 0
+		if blockNum != m.batchBlock {
+			m.flush()
+			m.startBatch(delta.Block)
+		}
+		if forkStep == pbbstream.ForkStep_STEP_IRREVERSIBLE {
+			// Irreversible blocks can not be undone, there is no need to keep undo information
+			m.doccache.SetBlock(0)
+		} else {
+			m.doccache.SetBlock(blockNum)
+		}
+	}
+	err := m.processDelta(delta, cursor)
+	if err != nil {
+		err = m.handleFailure(delta, cursor, forkStep, err)
+	}
+	if err == nil && m.doccache.IsBatching() {
+		m.batchDeltas = append(m.batchDeltas, record)
+	}
+	metrics.PendingEdges.Set(float64(m.doccache.PendingEdgeCount()))
+	m.cursor = cursor
+	if !m.doccache.IsBatching() {
+		metrics.BlockNumber.Set(float64(blockNum))
+	} else if m.lastBlockOp != nil && delta.DBOp == m.lastBlockOp {
+		m.flush()
+	}
+}
+
+// Starts batching the changes of the block, all the changes are committed once the last
+// delta of interest of the block has been processed
+func (m *deltaStreamHandler) startBatch(block *pbcodec.Block) {
+	m.batchBlock = uint64(block.Number)
+	m.lastBlockOp = m.findLastBlockOp(block)
+	m.batchBlockTime = getBlockTime(block)
+	m.doccache.StartBatch()
+}
+
+// Commits the batched changes, the block number metric is updated once they are stored. If the
+// changes can not be committed the failure policy is applied to the deltas of the block
+func (m *deltaStreamHandler) flush() {
+	if m.batchBlock == 0 {
+		return
+	}
+	err := m.committer.Flush()
+	if err != nil {
+		m.handleFlushFailure(err)
+	}
+	metrics.BlockNumber.Set(float64(m.batchBlock))
+	metrics.PendingEdges.Set(float64(m.committer.PendingEdgeCount()))
+	setHeadLag(m.batchBlockTime)
+	m.batchBlock = 0
+	m.lastBlockOp = nil
+	m.batchDeltas = nil
+}
+
+// Returns the time of the block, the zero time if the block does not provide it
+func getBlockTime(block *pbcodec.Block) time.Time {
+	if block.Header == nil || block.Header.Timestamp == nil {
+		return time.Time{}
+	}
+	blockTime, err := block.Time()
+	if err != nil {
+		return time.Time{}
+	}
+	return blockTime
+}
+
+// Returns the id of the transaction that generated the delta, empty if the block does not provide
+// its transactions
+func getTrxId(delta *dfclient.TableDelta) string {
+	if delta.DBOp == nil {
+		return ""
+	}
+	for _, trace := range delta.Block.TransactionTraces() {
+		for _, dbOp := range trace.DbOps {
+			if dbOp == delta.DBOp {
+				return trace.Id
+			}
+		}
+	}
+	return ""
+}
+
+// Updates the head lag metric based on the time of the last processed block
+func setHeadLag(blockTime time.Time) {
+	if !blockTime.IsZero() {
+		metrics.HeadLag.Set(time.Since(blockTime).Seconds())
+	}
+}
+
+// Finds the last operation on the documents or edges tables within the block, returns nil
+// if the block does not provide its operations, in which case the batch is committed when
+// the next block or heart beat arrives
+func (m *deltaStreamHandler) findLastBlockOp(block *pbcodec.Block) *pbcodec.DBOp {
+	traces := block.TransactionTraces()
+	for i := len(traces) - 1; i >= 0; i-- {
+		dbOps := traces[i].DbOps
+		for j := len(dbOps) - 1; j >= 0; j-- {
+			dbOp := dbOps[j]
+			if dbOp.Code == m.config.ContractName &&
+				(dbOp.TableName == m.config.DocTableName || dbOp.TableName == m.config.EdgeTableName) {
+				return dbOp
+			}
+		}
+	}
+	return nil
+}
+
+// Calls the doccache method that corresponds to the delta operation, upserts replace the stored
+// document or edge with the new data as the previous value of the row is not known
+func (m *deltaStreamHandler) processDelta(delta *dfclient.TableDelta, cursor string) error {
+	if delta.Operation == stream.OperationUpsert && len(delta.NewData) == 0 {
+		return fmt.Errorf("the previous value of the row is unknown, unable to revert upsert of table: %v, primary key: %v", delta.TableName, delta.PrimaryKey)
+	}
 	if delta.TableName == m.config.DocTableName {
 		chainDoc := &domain.ChainDocument{}
 		switch delta.Operation {
-		case pbcodec.DBOp_OPERATION_INSERT, pbcodec.DBOp_OPERATION_UPDATE:
+		case pbcodec.DBOp_OPERATION_INSERT, pbcodec.DBOp_OPERATION_UPDATE, stream.OperationUpsert:
 			err := json.Unmarshal(delta.NewData, chainDoc)
 			if err != nil {
-				log.Panicf(err, "Error unmarshalling doc new data: %v", string(delta.NewData))
+				return fmt.Errorf("error unmarshalling doc new data: %v, error: %v", string(delta.NewData), err)
 			}
+			chainDoc.Block = uint64(delta.Block.Number)
+			chainDoc.BlockTime = getBlockTime(delta.Block)
+			chainDoc.TrxId = getTrxId(delta)
 			log.Tracef("Storing doc: %v ", chainDoc)
 			err = m.doccache.StoreDocument(chainDoc, cursor)
 			if err != nil {
-				log.Panicf(err, "Failed to store doc: %v", chainDoc)
+				return fmt.Errorf("failed to store doc: %v, error: %v", chainDoc, err)
 			}
 			metrics.CreatedDocs.Inc()
 		case pbcodec.DBOp_OPERATION_REMOVE:
 			err := json.Unmarshal(delta.OldData, chainDoc)
 			if err != nil {
-				log.Panicf(err, "Error unmarshalling doc old data: %v", string(delta.OldData))
+				return fmt.Errorf("error unmarshalling doc old data: %v, error: %v", string(delta.OldData), err)
 			}
+			chainDoc.Block = uint64(delta.Block.Number)
+			chainDoc.BlockTime = getBlockTime(delta.Block)
+			chainDoc.TrxId = getTrxId(delta)
 			err = m.doccache.DeleteDocument(chainDoc, cursor)
 			if err != nil {
-				log.Panicf(err, "Failed to delete doc: %v", chainDoc)
+				return fmt.Errorf("failed to delete doc: %v, error: %v", chainDoc, err)
 			}
 			metrics.DeletedDocs.Inc()
 		}
@@ -82,11 +251,15 @@ func (m *deltaStreamHandler) OnDelta(delta *dfclient.TableDelta, cursor string,
 			}
 			err := json.Unmarshal(deltaData, chainEdge)
 			if err != nil {
-				log.Panicf(err, "Error unmarshalling edge data: %v", chainEdge)
+				return fmt.Errorf("error unmarshalling edge data: %v, error: %v", string(deltaData), err)
+			}
+			if !deleteOp {
+				chainEdge.CreatedBlock = uint64(delta.Block.Number)
+				chainEdge.CreatedTrxId = getTrxId(delta)
 			}
 			err = m.doccache.MutateEdge(chainEdge, deleteOp, cursor)
 			if err != nil {
-				log.Panicf(err, "Failed to mutate doc, deleteOp: %v, edge: %v", deleteOp, chainEdge)
+				return fmt.Errorf("failed to mutate edge, deleteOp: %v, edge: %v, error: %v", deleteOp, chainEdge, err)
 			}
 			if deleteOp {
 				metrics.DeletedEdges.Inc()
@@ -95,21 +268,155 @@ func (m *deltaStreamHandler) OnDelta(delta *dfclient.TableDelta, cursor string,
 			}
 
 		case pbcodec.DBOp_OPERATION_UPDATE:
-			log.Panicf(nil, "Edge updating is not handled: %v", delta)
+			oldEdge := &domain.ChainEdge{}
+			err := json.Unmarshal(delta.OldData, oldEdge)
+			if err != nil {
+				return fmt.Errorf("error unmarshalling edge old data: %v, error: %v", string(delta.OldData), err)
+			}
+			newEdge := &domain.ChainEdge{}
+			err = json.Unmarshal(delta.NewData, newEdge)
+			if err != nil {
+				return fmt.Errorf("error unmarshalling edge new data: %v, error: %v", string(delta.NewData), err)
+			}
+			newEdge.CreatedBlock = uint64(delta.Block.Number)
+			newEdge.CreatedTrxId = getTrxId(delta)
+			err = m.doccache.UpdateEdge(oldEdge, newEdge, cursor)
+			if err != nil {
+				return fmt.Errorf("failed to update edge, old edge: %v, new edge: %v, error: %v", oldEdge, newEdge, err)
+			}
+			metrics.UpdatedEdges.Inc()
+
+		case stream.OperationUpsert:
+			// The primary key of an edge is derived from its from, to and name, so the relationship
+			// of the row can not change, storing the edge replaces the metadata of the stored one
+			chainEdge := &domain.ChainEdge{}
+			err := json.Unmarshal(delta.NewData, chainEdge)
+			if err != nil {
+				return fmt.Errorf("error unmarshalling edge new data: %v, error: %v", string(delta.NewData), err)
+			}
+			chainEdge.CreatedBlock = uint64(delta.Block.Number)
+			chainEdge.CreatedTrxId = getTrxId(delta)
+			err = m.doccache.MutateEdge(chainEdge, false, cursor)
+			if err != nil {
+				return fmt.Errorf("failed to upsert edge: %v, error: %v", chainEdge, err)
+			}
+			metrics.UpdatedEdges.Inc()
 		}
 	}
-	metrics.BlockNumber.Set(float64(delta.Block.Number))
+	return nil
+}
+
+// Applies the configured failure policy to a delta that could not be processed, the delta is
+// either retried, recorded in the dead letter store or the process is stopped. Returns nil if
+// a retry succeeded, otherwise the error of the last attempt
+func (m *deltaStreamHandler) handleFailure(delta *dfclient.TableDelta, cursor string, forkStep pbbstream.ForkStep, err error) error {
+	if m.config.FailurePolicy == config.FailurePolicy_Panic {
+		log.Panicf(err, "Failed to process delta: %v", delta)
+	}
+	attempts, err := m.retry("process delta", func() error { return m.processDelta(delta, cursor) }, err)
+	if err == nil {
+		return nil
+	}
+	log.Errorf(err, "Failed to process delta, recording it in the dead letter store: %v", delta)
+	m.recordDeadLetter(deadletter.NewDeadLetter(stream.NewDeltaRecord(delta, cursor, forkStep), err, attempts))
+	m.updateCursor(cursor)
+	return err
+}
+
+// Applies the configured failure policy to a batch that could not be committed, the commit is
+// either retried, the deltas of the block are recorded in the dead letter store or the process
+// is stopped. The changes of the block that were not committed are discarded along with its
+// undo information, and the cursor is moved past the block
+func (m *deltaStreamHandler) handleFlushFailure(err error) {
+	if m.config.FailurePolicy == config.FailurePolicy_Panic {
+		log.Panicf(err, "Failed to commit changes for block: %v", m.batchBlock)
+	}
+	attempts, err := m.retry("commit changes", m.committer.Flush, err)
+	if err == nil {
+		return
+	}
+	log.Errorf(err, "Failed to commit changes for block: %v, recording its %v deltas in the dead letter store", m.batchBlock, len(m.batchDeltas))
+	for _, record := range m.batchDeltas {
+		m.recordDeadLetter(deadletter.NewDeadLetter(record, err, attempts))
+	}
+	m.committer.DiscardBatch(m.batchBlock)
+	m.updateCursor(m.cursor)
+}
+
+// Retries the failed operation when using the retry then record policy, returns the number of
+// attempts made and the error of the last one
+func (m *deltaStreamHandler) retry(description string, operation func() error, err error) (uint, error) {
+	attempts := uint(1)
+	if m.config.FailurePolicy == config.FailurePolicy_RetryThenRecord {
+		for ; err != nil && attempts <= m.config.FailureRetries; attempts++ {
+			log.Warnf("Failed to %v, attempt: %v, retrying in: %v, error: %v", description, attempts, m.config.FailureRetryDelay, err)
+			metrics.FailedDeltaRetries.Inc()
+			time.Sleep(m.config.FailureRetryDelay)
+			err = operation()
+		}
+	}
+	return attempts, err
+}
+
+func (m *deltaStreamHandler) recordDeadLetter(deadLetter *deadletter.DeadLetter) {
+	err := m.deadLetters.Record(deadLetter)
+	if err != nil {
+		log.Panicf(err, "Failed to record dead letter: %v", deadLetter)
+	}
+	metrics.DeadLetters.Inc()
+}
+
+func (m *deltaStreamHandler) updateCursor(cursor string) {
+	err := m.committer.UpdateCursor(cursor)
+	if err != nil {
+		log.Panicf(err, "Failed to update cursor: %v", cursor)
+	}
+}
+
+// Reverts the changes of the block using the undo journal, the first undo delta of the block
+// restores the state previous to the block, the rest of its undo deltas are skipped. Returns
+// false if there is no undo information for the block, in which case the undo delta has to be
+// processed as a regular delta, relying on the stream to provide the reversed operation
+func (m *deltaStreamHandler) undoBlock(blockNum uint64, cursor string) bool {
+	if m.undoneBlock == blockNum {
+		err := m.doccache.UpdateCursor(cursor)
+		if err != nil {
+			log.Panicf(err, "Failed to update cursor: %v", cursor)
+		}
+		m.cursor = cursor
+		return true
+	}
+	undone, err := m.doccache.UndoBlock(blockNum, cursor)
+	if err != nil {
+		log.Panicf(err, "Failed to undo block: %v", blockNum)
+	}
+	if !undone {
+		log.Warnf("No undo journal for block: %v, processing reversed operation", blockNum)
+		m.doccache.SetBlock(0)
+		metrics.ReversedUndoDeltas.Inc()
+		return false
+	}
+	m.undoneBlock = blockNum
+	metrics.UndoneBlocks.Inc()
+	metrics.BlockNumber.Set(float64(blockNum - 1))
+	metrics.PendingEdges.Set(float64(m.doccache.PendingEdgeCount()))
 	m.cursor = cursor
+	return true
 }
 
 // Called every certain amount of blocks and its useful to update the cursor when there are
 // no deltas of interest for a long time
 func (m *deltaStreamHandler) OnHeartBeat(block *pbcodec.Block, cursor string) {
+	m.captureRecord(stream.NewHeartBeatRecord(block, cursor))
+	m.flushCapture()
+	m.flush()
 	err := m.doccache.UpdateCursor(cursor)
 	if err != nil {
 		log.Panicf(err, "Failed to update cursor: %v", cursor)
 	}
+	m.cursor = cursor
 	metrics.BlockNumber.Set(float64(block.Number))
+	setHeadLag(getBlockTime(block))
 }
 
 // Called when there is an error with the stream connection
@@ -117,36 +424,61 @@ func (m *deltaStreamHandler) OnError(err error) {
 	log.Error(err, "On Error")
 }
 
-// Called when the requested stream completes, should never be called because there is no
-// final block
+// Called when the requested stream completes, which happens when a stop block is specified or
+// when the delta file has been replayed, the pending changes and the last cursor are stored so that the process can exit cleanly
 func (m *deltaStreamHandler) OnComplete(lastBlockRef bstream.BlockRef) {
-	log.Infof("On Complete Last Block Ref: %v", lastBlockRef)
+	m.flush()
+	if m.cursor != "" {
+		err := m.doccache.UpdateCursor(m.cursor)
+		if err != nil {
+			log.Panicf(err, "Failed to update cursor: %v", m.cursor)
+		}
+	}
+	m.closeCapture()
+	m.completed = true
+	log.Infof("On Complete Last Block Ref: %v, Cursor: %v", lastBlockRef, m.cursor)
 }
 
-// Loads the configuration file, creates a new dfuse client and configures it with the stream handler
-// defined above
+// Loads the configuration file, creates the configured delta source and configures it with the stream handler
+// defined above, if the redrive-dead-letters, extract-capture or pending-edges flags are specified, the corresponding
+// command is run instead of starting the stream, if the bootstrap flag is specified the cache is built from
+// the table snapshot before starting the stream. The process exits once the stream completes or a SIGTERM or
+// SIGINT signal is received
 func main() {
 	log = slog.New(&slog.Config{Pretty: true, Level: zerolog.DebugLevel}, "start-doccache")
-	if len(os.Args) != 2 {
+	redrive := flag.Bool("redrive-dead-letters", false, "Reprocesses the deltas stored in the dead letter store and exits, the stream process should not be running")
+	extractBlocks := flag.String("extract-capture", "", "Extracts the records for the block range, specified as <start>-<end>, from the capture archive and exits")
+	extractOutput := flag.String("extract-output", "", "File to write the extracted records to, defaults to stdout")
+	stopBlock := flag.Uint64("stop-block", 0, "Last block to process, once it has been processed the process exits, overrides the stop-block configuration parameter")
+	bootstrap := flag.Bool("bootstrap", false, "Builds the cache from the current state of the contract tables before starting the stream, the cache must not have a cursor")
+	pendingEdges := flag.Bool("pending-edges", false, "Prints the edges whose FROM or TO documents do not exist yet and exits")
+	flag.Parse()
+	if flag.NArg() != 1 {
 		log.Panic(nil, "Config file has to be specified as the only cmd argument")
 	}
+	configFile := flag.Arg(0)
 
-	config, err := config.LoadConfig(os.Args[1])
+	config, err := config.LoadConfig(configFile)
 	if err != nil {
-		log.Panicf(err, "Unable to load config file: %v", os.Args[1])
+		log.Panicf(err, "Unable to load config file: %v", configFile)
 	}
 
+	if *stopBlock > 0 {
+		if config.StartBlock > 0 && uint64(config.StartBlock) > *stopBlock {
+			log.Panicf(nil, "Stop block: %v must not be lower than start block: %v", *stopBlock, config.StartBlock)
+		}
+		config.StopBlock = *stopBlock
+	}
 	log.Info(config.String())
 
-	go monitoring.SetupEndpoint(config.PrometheusPort)
-	if err != nil {
-		log.Panic(err, "Error seting up prometheus endpoint")
+	if *extractBlocks != "" {
+		err = extractCapture(config.CaptureDir, *extractBlocks, *extractOutput)
+		if err != nil {
+			log.Panic(err, "Error extracting capture records")
+		}
+		return
 	}
 
-	client, err := dfclient.NewDfClient(config.FirehoseEndpoint, config.DfuseApiKey, config.DfuseAuthURL, config.EosEndpoint, nil)
-	if err != nil {
-		log.Panic(err, "Error creating dfclient")
-	}
 	dg, err := dgraph.New(config.DgraphGRPCEndpoint)
 	if err != nil {
 		log.Panic(err, "Error creating dgraph client")
@@ -158,18 +490,89 @@ func main() {
 		log.Panic(err, "Error creating doccache client")
 	}
 	log.Infof("Cursor: %v", cache.Cursor)
+	handler := &deltaStreamHandler{
+		doccache:    cache,
+		committer:   cache,
+		config:      config,
+		deadLetters: deadletter.NewStore(config.DeadLetterFile),
+	}
+	if *redrive {
+		remaining, err := handler.redriveDeadLetters()
+		if err != nil {
+			log.Panic(err, "Error redriving dead letters")
+		}
+		if remaining > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if *pendingEdges {
+		err = printPendingEdges(cache)
+		if err != nil {
+			log.Panic(err, "Error printing pending edges")
+		}
+		return
+	}
+
+	go monitoring.SetupEndpoint(config.PrometheusPort)
+	if err != nil {
+		log.Panic(err, "Error seting up prometheus endpoint")
+	}
+
+	if config.CaptureDir != "" {
+		handler.capture, err = stream.NewArchive(config.CaptureDir, config.CaptureSegmentSize, config.CaptureMaxSegments)
+		if err != nil {
+			log.Panic(err, "Error creating capture archive")
+		}
+		log.Infof("Capturing stream to: %v", config.CaptureDir)
+	}
+	if *bootstrap {
+		cursor := cache.Cursor.GetValue("cursor").(string)
+		if cursor != "" {
+			log.Panicf(nil, "Unable to bootstrap, the cache already has a cursor: %v", cursor)
+		}
+		chainSnapshot := snapshot.NewChainSnapshot(config.EosEndpoint, dfclient.NewDecoder(config.EosEndpoint), uint32(config.BootstrapPageSize))
+		err = handler.bootstrap(chainSnapshot)
+		if err != nil {
+			log.Panic(err, "Error bootstrapping from table snapshot")
+		}
+	}
+	source, err := newDeltaSource(config)
+	if err != nil {
+		log.Panic(err, "Error creating delta source")
+	}
+	// Undo is handled by the undo journal, the reversed operations are only used for
+	// blocks that are no longer in the journal, i.e. processed before a restart
 	deltaRequest := &dfclient.DeltaStreamRequest{
 		StartBlockNum:      config.StartBlock,
 		StartCursor:        cache.Cursor.GetValue("cursor").(string),
-		StopBlockNum:       0,
+		StopBlockNum:       config.StopBlock,
 		ForkSteps:          []pbbstream.ForkStep{pbbstream.ForkStep_STEP_NEW, pbbstream.ForkStep_STEP_UNDO},
 		ReverseUndoOps:     true,
 		HeartBeatFrequency: config.HeartBeatFrequency,
 	}
+	if config.IrreversibleOnly {
+		log.Infof("Processing only irreversible blocks")
+		deltaRequest.ForkSteps = []pbbstream.ForkStep{pbbstream.ForkStep_STEP_IRREVERSIBLE}
+	}
 	// deltaRequest.AddTables("eosio.token", []string{"balance"})
 	deltaRequest.AddTables(config.ContractName, []string{config.DocTableName, config.EdgeTableName})
-	client.DeltaStream(deltaRequest, &deltaStreamHandler{
-		doccache: cache,
-		config:   config,
-	})
+	os.Exit(runStream(source, deltaRequest, handler, dg, config))
+}
+
+// Creates the configured delta source
+func newDeltaSource(cfg *config.Config) (stream.DeltaSource, error) {
+	if cfg.DeltaSource == config.DeltaSource_File {
+		log.Infof("Replaying deltas from file: %v", cfg.DeltaFile)
+		return stream.NewFileSource(cfg.DeltaFile), nil
+	}
+	if cfg.DeltaSource == config.DeltaSource_Ship {
+		log.Infof("Streaming deltas from state history endpoint: %v", cfg.ShipEndpoint)
+		return stream.NewShipSource(cfg.ShipEndpoint, dfclient.NewDecoder(cfg.EosEndpoint)), nil
+	}
+	client, err := dfclient.NewDfClient(cfg.FirehoseEndpoint, cfg.DfuseApiKey, cfg.DfuseAuthURL, cfg.EosEndpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed creating dfclient, error: %v", err)
+	}
+	return stream.NewFirehoseSource(client), nil
 }
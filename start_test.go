@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/config"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/deadletter"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/stream"
+	"github.com/sebastianmontero/slog-go/slog"
+	"gotest.tools/assert"
+)
+
+func TestFlushFailureRecordsBlockDeltas(t *testing.T) {
+	committer := &failingCommitter{failures: 1}
+	handler := newFlushTestHandler(t, config.FailurePolicy_SkipAndRecord, committer)
+
+	handler.flush()
+
+	deadLetters, err := handler.deadLetters.Load()
+	assert.NilError(t, err)
+	assert.Equal(t, len(deadLetters), 2)
+	assert.Equal(t, deadLetters[0].Delta.Cursor, "cursor10_1")
+	assert.Equal(t, deadLetters[1].Delta.Cursor, "cursor10_2")
+	assert.Equal(t, deadLetters[0].Error, "commit failed")
+	assert.Equal(t, deadLetters[0].Attempts, uint(1))
+	assert.Equal(t, committer.discardedBlock, uint64(10))
+	assert.Equal(t, committer.cursor, "cursor10_2")
+	assert.Equal(t, handler.batchBlock, uint64(0))
+	assert.Equal(t, len(handler.batchDeltas), 0)
+}
+
+func TestFlushFailureRetriesThenRecords(t *testing.T) {
+	committer := &failingCommitter{failures: 3}
+	handler := newFlushTestHandler(t, config.FailurePolicy_RetryThenRecord, committer)
+
+	handler.flush()
+
+	deadLetters, err := handler.deadLetters.Load()
+	assert.NilError(t, err)
+	assert.Equal(t, len(deadLetters), 2)
+	assert.Equal(t, deadLetters[0].Attempts, uint(3))
+	assert.Equal(t, committer.flushes, 3)
+	assert.Equal(t, committer.discardedBlock, uint64(10))
+	assert.Equal(t, committer.cursor, "cursor10_2")
+}
+
+func TestFlushFailureRetrySucceeds(t *testing.T) {
+	committer := &failingCommitter{failures: 2}
+	handler := newFlushTestHandler(t, config.FailurePolicy_RetryThenRecord, committer)
+
+	handler.flush()
+
+	deadLetters, err := handler.deadLetters.Load()
+	assert.NilError(t, err)
+	assert.Equal(t, len(deadLetters), 0)
+	assert.Equal(t, committer.flushes, 3)
+	assert.Equal(t, committer.discardedBlock, uint64(0))
+	assert.Equal(t, committer.cursor, "")
+}
+
+func newFlushTestHandler(t *testing.T, failurePolicy string, committer blockCommitter) *deltaStreamHandler {
+	log = slog.New(nil, "start-doccache-test")
+	return &deltaStreamHandler{
+		committer: committer,
+		config: &config.Config{
+			FailurePolicy:  failurePolicy,
+			FailureRetries: 2,
+		},
+		deadLetters: deadletter.NewStore(filepath.Join(t.TempDir(), "dead-letters.jsonl")),
+		batchBlock:  10,
+		batchDeltas: []*stream.DeltaRecord{
+			getDeltaRecord(10, "cursor10_1"),
+			getDeltaRecord(10, "cursor10_2"),
+		},
+		cursor: "cursor10_2",
+	}
+}
+
+func getDeltaRecord(blockNum uint64, cursor string) *stream.DeltaRecord {
+	return &stream.DeltaRecord{
+		Kind:      stream.RecordKind_Delta,
+		Operation: "OPERATION_INSERT",
+		Code:      "dao.hypha",
+		Scope:     "dao.hypha",
+		TableName: "documents",
+		NewData:   []byte(fmt.Sprintf(`{"id":%v}`, blockNum)),
+		BlockNum:  blockNum,
+		Cursor:    cursor,
+		ForkStep:  "STEP_NEW",
+	}
+}
+
+// Block committer whose first commits fail
+type failingCommitter struct {
+	failures       int
+	flushes        int
+	discardedBlock uint64
+	cursor         string
+}
+
+func (m *failingCommitter) Flush() error {
+	m.flushes++
+	if m.flushes <= m.failures {
+		return fmt.Errorf("commit failed")
+	}
+	return nil
+}
+
+func (m *failingCommitter) DiscardBatch(blockNum uint64) {
+	m.discardedBlock = blockNum
+}
+
+func (m *failingCommitter) UpdateCursor(cursor string) error {
+	m.cursor = cursor
+	return nil
+}
+
+func (m *failingCommitter) PendingEdgeCount() int64 {
+	return 0
+}
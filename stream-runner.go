@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/sebastianmontero/dfuse-firehose-client/dfclient"
+	"github.com/sebastianmontero/dgraph-go-client/dgraph"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/config"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/monitoring/metrics"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/stream"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/util"
+	"github.com/streamingfast/bstream"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+)
+
+// Stream handler for a single stream connection, forwards the stream events to the delta stream
+// handler while the session is active, so that a stalled stream that is being torn down can not
+// interfere with the one that replaces it
+type streamSession struct {
+	handler *deltaStreamHandler
+	// Guarded by the handler lock
+	active bool
+	// Indicates whether any stream event was received, guarded by the handler lock
+	received bool
+}
+
+// Acquires the handler lock if the session is active, returns false otherwise
+func (m *streamSession) begin() bool {
+	m.handler.lock.Lock()
+	if !m.active || m.handler.stopped {
+		m.handler.lock.Unlock()
+		return false
+	}
+	m.received = true
+	m.handler.lastActivity = time.Now()
+	return true
+}
+
+func (m *streamSession) end() {
+	m.handler.lastActivity = time.Now()
+	m.handler.lock.Unlock()
+}
+
+func (m *streamSession) OnDelta(delta *dfclient.TableDelta, cursor string, forkStep pbbstream.ForkStep) {
+	if m.begin() {
+		defer m.end()
+		m.handler.OnDelta(delta, cursor, forkStep)
+	}
+}
+
+func (m *streamSession) OnHeartBeat(block *pbcodec.Block, cursor string) {
+	if m.begin() {
+		defer m.end()
+		m.handler.OnHeartBeat(block, cursor)
+	}
+}
+
+func (m *streamSession) OnError(err error) {
+	if m.begin() {
+		defer m.end()
+		m.handler.OnError(err)
+	}
+}
+
+func (m *streamSession) OnComplete(lastBlockRef bstream.BlockRef) {
+	if m.begin() {
+		defer m.end()
+		m.handler.OnComplete(lastBlockRef)
+	}
+}
+
+// Creates a new active session, deactivating any previous one
+func (m *deltaStreamHandler) startSession() *streamSession {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.lastActivity = time.Now()
+	return &streamSession{
+		handler: m,
+		active:  true,
+	}
+}
+
+// Deactivates the session, so that its events are ignored, and stores the progress so that the
+// stream can be restarted from the last cursor. Returns whether the session received any event
+func (m *deltaStreamHandler) endSession(session *streamSession) (bool, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	session.active = false
+	if m.completed || m.stopped {
+		return session.received, nil
+	}
+	return session.received, m.storeProgress()
+}
+
+// Indicates whether no stream event has been processed within the timeout
+func (m *deltaStreamHandler) isStalled(timeout time.Duration) bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return time.Since(m.lastActivity) > timeout
+}
+
+// Streams the deltas to the handler until the stream completes or a SIGTERM or SIGINT signal is received,
+// in which case the process is shutdown gracefully. When streaming from the network, a watchdog tears the
+// stream down and restarts it from the last cursor if no delta or heart beat is received within the stall timeout, streams
+// that end because of an error are also restarted, the reconnection attempts are delayed using exponential
+// backoff. Returns the exit code of the process
+func runStream(source stream.DeltaSource, request *dfclient.DeltaStreamRequest, handler *deltaStreamHandler, dg *dgraph.Dgraph, cfg *config.Config) int {
+	exitCode := streamUntilDone(source, request, handler, cfg)
+	err := dg.Close()
+	if err != nil {
+		log.Error(err, "Failed to close dgraph connection")
+	}
+	log.Infof("Stream finished, cursor: %v, exit code: %v", handler.cursor, exitCode)
+	return exitCode
+}
+
+func streamUntilDone(source stream.DeltaSource, request *dfclient.DeltaStreamRequest, handler *deltaStreamHandler, cfg *config.Config) int {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+	reconnect := cfg.DeltaSource != config.DeltaSource_File
+	var watchdog <-chan time.Time
+	if reconnect {
+		ticker := time.NewTicker(cfg.StallTimeout / 4)
+		defer ticker.Stop()
+		watchdog = ticker.C
+	}
+	backoff := util.NewBackoff(cfg.ReconnectMinDelay, cfg.ReconnectMaxDelay)
+	for {
+		session := handler.startSession()
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func(request dfclient.DeltaStreamRequest) {
+			source.DeltaStream(ctx, &request, session)
+			close(done)
+		}(*request)
+	wait:
+		for {
+			select {
+			case sig := <-signals:
+				cancel()
+				return stop(handler, sig)
+			case <-done:
+				break wait
+			case <-watchdog:
+				if handler.isStalled(cfg.StallTimeout) {
+					log.Warnf("No deltas or heart beats received in: %v, the stream is stalled", cfg.StallTimeout)
+					metrics.StreamStalls.Inc()
+					break wait
+				}
+			}
+		}
+		// Tears the stream down before a new one is started
+		cancel()
+		select {
+		case sig := <-signals:
+			return stop(handler, sig)
+		case <-done:
+		}
+		received, err := handler.endSession(session)
+		if handler.completed {
+			return ExitCode_Success
+		}
+		if err != nil {
+			log.Error(err, "Failed to store progress before reconnecting")
+			return ExitCode_StreamFailed
+		}
+		if !reconnect {
+			return ExitCode_StreamFailed
+		}
+		if received {
+			backoff.Reset()
+		}
+		delay := backoff.Next()
+		if handler.cursor != "" {
+			request.StartCursor = handler.cursor
+		}
+		log.Warnf("Restarting stream from cursor: %v in: %v, attempt: %v", request.StartCursor, delay, backoff.Failures())
+		select {
+		case sig := <-signals:
+			return stop(handler, sig)
+		case <-time.After(delay):
+		}
+		metrics.StreamReconnects.Inc()
+	}
+}
+
+// Shuts the handler down after receiving a signal
+func stop(handler *deltaStreamHandler, sig os.Signal) int {
+	log.Infof("Received signal: %v, shutting down", sig)
+	err := handler.shutdown()
+	if err != nil {
+		log.Error(err, "Failed to shutdown gracefully")
+		return ExitCode_ShutdownFailed
+	}
+	return ExitCode_Success
+}
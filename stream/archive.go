@@ -0,0 +1,335 @@
+package stream
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+const segmentFileFormat = "deltas-%012d-%012d.jsonl.gz"
+const openSegmentFileFormat = "deltas-%012d.jsonl.gz.open"
+
+var segmentFileRegex = regexp.MustCompile(`^deltas-(\d+)-(\d+)\.jsonl\.gz$`)
+var openSegmentFileRegex = regexp.MustCompile(`^deltas-(\d+)\.jsonl\.gz\.open$`)
+
+// Represents a compressed file of the archive that contains the records for a range of blocks
+type Segment struct {
+	FirstBlock uint64
+	LastBlock  uint64
+	Path       string
+}
+
+// Indicates whether the segment may contain records for blocks in the range
+func (m *Segment) Overlaps(startBlock, endBlock uint64) bool {
+	return m.FirstBlock <= endBlock && m.LastBlock >= startBlock
+}
+
+func (m *Segment) String() string {
+	return fmt.Sprintf("Segment{FirstBlock: %v, LastBlock: %v, Path: %v}", m.FirstBlock, m.LastBlock, m.Path)
+}
+
+// Segment that is currently being written
+type segmentWriter struct {
+	file       *os.File
+	gzip       *gzip.Writer
+	path       string
+	firstBlock uint64
+	lastBlock  uint64
+	records    uint
+}
+
+// Local archive of the stream records, the records are appended to gzip compressed JSONL segments
+// that are rotated once they reach the configured number of records, each segment is named after
+// the range of blocks it contains, which enables the extraction of the records for a range of blocks
+type Archive struct {
+	Dir         string
+	SegmentSize uint
+	MaxSegments uint
+	current     *segmentWriter
+}
+
+// Creates an archive that stores its segments in dir, segments that were left open by a previous
+// run are closed, a maxSegments of 0 indicates that segments are never removed
+func NewArchive(dir string, segmentSize, maxSegments uint) (*Archive, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %v, error: %v", dir, err)
+	}
+	m := &Archive{
+		Dir:         dir,
+		SegmentSize: segmentSize,
+		MaxSegments: maxSegments,
+	}
+	openSegments, err := m.openSegments()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range openSegments {
+		err = m.recoverSegment(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Appends a record to the archive, rotating the current segment if it is full
+func (m *Archive) Record(record *DeltaRecord) error {
+	if m.current == nil {
+		err := m.openSegment(record.BlockNum)
+		if err != nil {
+			return err
+		}
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %v, error: %v", record, err)
+	}
+	_, err = m.current.gzip.Write(append(line, '\n'))
+	if err != nil {
+		return fmt.Errorf("failed to write record: %v to segment: %v, error: %v", record, m.current.path, err)
+	}
+	m.current.records++
+	if record.BlockNum < m.current.firstBlock {
+		m.current.firstBlock = record.BlockNum
+	}
+	if record.BlockNum > m.current.lastBlock {
+		m.current.lastBlock = record.BlockNum
+	}
+	if m.current.records >= m.SegmentSize {
+		return m.closeSegment()
+	}
+	return nil
+}
+
+// Writes the buffered records to the current segment file
+func (m *Archive) Flush() error {
+	if m.current == nil {
+		return nil
+	}
+	err := m.current.gzip.Flush()
+	if err != nil {
+		return fmt.Errorf("failed to flush segment: %v, error: %v", m.current.path, err)
+	}
+	return nil
+}
+
+// Closes the current segment
+func (m *Archive) Close() error {
+	if m.current == nil {
+		return nil
+	}
+	return m.closeSegment()
+}
+
+func (m *Archive) openSegment(blockNum uint64) error {
+	path := filepath.Join(m.Dir, fmt.Sprintf(openSegmentFileFormat, blockNum))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to create segment: %v, error: %v", path, err)
+	}
+	m.current = &segmentWriter{
+		file:       file,
+		gzip:       gzip.NewWriter(file),
+		path:       path,
+		firstBlock: blockNum,
+		lastBlock:  blockNum,
+	}
+	return nil
+}
+
+func (m *Archive) closeSegment() error {
+	current := m.current
+	m.current = nil
+	err := current.gzip.Close()
+	if err != nil {
+		current.file.Close()
+		return fmt.Errorf("failed to close segment: %v, error: %v", current.path, err)
+	}
+	err = current.file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to close segment: %v, error: %v", current.path, err)
+	}
+	return m.finalizeSegment(current.path, current.firstBlock, current.lastBlock)
+}
+
+// Renames the segment so that its name reflects the range of blocks it contains and removes
+// the oldest segments if there are more than the maximum
+func (m *Archive) finalizeSegment(path string, firstBlock, lastBlock uint64) error {
+	segmentPath := filepath.Join(m.Dir, fmt.Sprintf(segmentFileFormat, firstBlock, lastBlock))
+	err := os.Rename(path, segmentPath)
+	if err != nil {
+		return fmt.Errorf("failed to rename segment: %v to %v, error: %v", path, segmentPath, err)
+	}
+	if m.MaxSegments == 0 {
+		return nil
+	}
+	segments, err := m.Segments()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(segments)-int(m.MaxSegments); i++ {
+		err = os.Remove(segments[i].Path)
+		if err != nil {
+			return fmt.Errorf("failed to remove segment: %v, error: %v", segments[i].Path, err)
+		}
+	}
+	return nil
+}
+
+// Finalizes a segment left open by a previous run, the records that could be read are kept
+func (m *Archive) recoverSegment(path string) error {
+	firstBlock, lastBlock := uint64(0), uint64(0)
+	records := 0
+	err := readSegment(path, func(record *DeltaRecord) error {
+		if records == 0 || record.BlockNum < firstBlock {
+			firstBlock = record.BlockNum
+		}
+		if record.BlockNum > lastBlock {
+			lastBlock = record.BlockNum
+		}
+		records++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if records == 0 {
+		err = os.Remove(path)
+		if err != nil {
+			return fmt.Errorf("failed to remove empty segment: %v, error: %v", path, err)
+		}
+		return nil
+	}
+	return m.finalizeSegment(path, firstBlock, lastBlock)
+}
+
+// Returns the finalized segments sorted by first block
+func (m *Archive) Segments() ([]*Segment, error) {
+	entries, err := ioutil.ReadDir(m.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %v, error: %v", m.Dir, err)
+	}
+	segments := make([]*Segment, 0)
+	for _, entry := range entries {
+		matches := segmentFileRegex.FindStringSubmatch(entry.Name())
+		if matches == nil {
+			continue
+		}
+		firstBlock, _ := strconv.ParseUint(matches[1], 10, 64)
+		lastBlock, _ := strconv.ParseUint(matches[2], 10, 64)
+		segments = append(segments, &Segment{
+			FirstBlock: firstBlock,
+			LastBlock:  lastBlock,
+			Path:       filepath.Join(m.Dir, entry.Name()),
+		})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].FirstBlock < segments[j].FirstBlock
+	})
+	return segments, nil
+}
+
+// Returns the paths of the segments that have not been finalized
+func (m *Archive) openSegments() ([]string, error) {
+	entries, err := ioutil.ReadDir(m.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive directory: %v, error: %v", m.Dir, err)
+	}
+	paths := make([]string, 0)
+	for _, entry := range entries {
+		if openSegmentFileRegex.MatchString(entry.Name()) {
+			paths = append(paths, filepath.Join(m.Dir, entry.Name()))
+		}
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// Writes the records for the blocks in the range [startBlock, endBlock] to the writer as JSONL,
+// in the format used by the file delta source, segments that are still being written are also
+// taken into account. Returns the number of records extracted
+func (m *Archive) Extract(startBlock, endBlock uint64, writer io.Writer) (int, error) {
+	segments, err := m.Segments()
+	if err != nil {
+		return 0, err
+	}
+	paths := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if segment.Overlaps(startBlock, endBlock) {
+			paths = append(paths, segment.Path)
+		}
+	}
+	openSegments, err := m.openSegments()
+	if err != nil {
+		return 0, err
+	}
+	paths = append(paths, openSegments...)
+	extracted := 0
+	for _, path := range paths {
+		err = readSegment(path, func(record *DeltaRecord) error {
+			if record.BlockNum < startBlock || record.BlockNum > endBlock {
+				return nil
+			}
+			line, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("failed to encode record: %v, error: %v", record, err)
+			}
+			_, err = writer.Write(append(line, '\n'))
+			if err != nil {
+				return fmt.Errorf("failed to write record: %v, error: %v", record, err)
+			}
+			extracted++
+			return nil
+		})
+		if err != nil {
+			return extracted, err
+		}
+	}
+	return extracted, nil
+}
+
+// Reads the records of a segment, a truncated segment, i.e. one that was being written when
+// the process stopped, is read up to the last complete record
+func readSegment(path string, fn func(record *DeltaRecord) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %v, error: %v", path, err)
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return fmt.Errorf("failed to open segment: %v, error: %v", path, err)
+	}
+	defer reader.Close()
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		record := &DeltaRecord{}
+		err = json.Unmarshal(scanner.Bytes(), record)
+		if err != nil {
+			//Last line of a truncated segment
+			break
+		}
+		err = fn(record)
+		if err != nil {
+			return err
+		}
+	}
+	err = scanner.Err()
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return fmt.Errorf("failed to read segment: %v, error: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package stream_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/stream"
+	"gotest.tools/assert"
+)
+
+func TestArchive(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := stream.NewArchive(dir, 3, 0)
+	assert.NilError(t, err)
+	for blockNum := uint64(10); blockNum < 17; blockNum++ {
+		err = archive.Record(getArchiveRecord(blockNum))
+		assert.NilError(t, err)
+	}
+	err = archive.Close()
+	assert.NilError(t, err)
+
+	segments, err := archive.Segments()
+	assert.NilError(t, err)
+	assert.Equal(t, len(segments), 3)
+	assertSegment(t, segments[0], 10, 12)
+	assertSegment(t, segments[1], 13, 15)
+	assertSegment(t, segments[2], 16, 16)
+
+	output := &bytes.Buffer{}
+	extracted, err := archive.Extract(12, 14, output)
+	assert.NilError(t, err)
+	assert.Equal(t, extracted, 3)
+	assertBlockNums(t, output.String(), 12, 13, 14)
+}
+
+func TestArchiveMaxSegments(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := stream.NewArchive(dir, 2, 2)
+	assert.NilError(t, err)
+	for blockNum := uint64(10); blockNum < 17; blockNum++ {
+		err = archive.Record(getArchiveRecord(blockNum))
+		assert.NilError(t, err)
+	}
+	err = archive.Close()
+	assert.NilError(t, err)
+
+	segments, err := archive.Segments()
+	assert.NilError(t, err)
+	assert.Equal(t, len(segments), 2)
+	assertSegment(t, segments[0], 14, 15)
+	assertSegment(t, segments[1], 16, 16)
+}
+
+func TestArchiveRecoversOpenSegment(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := stream.NewArchive(dir, 10, 0)
+	assert.NilError(t, err)
+	for blockNum := uint64(10); blockNum < 13; blockNum++ {
+		err = archive.Record(getArchiveRecord(blockNum))
+		assert.NilError(t, err)
+	}
+	err = archive.Flush()
+	assert.NilError(t, err)
+
+	t.Log("Extracting from the segment that is still being written")
+	output := &bytes.Buffer{}
+	reader := &stream.Archive{Dir: dir}
+	extracted, err := reader.Extract(0, 100, output)
+	assert.NilError(t, err)
+	assert.Equal(t, extracted, 3)
+	assertBlockNums(t, output.String(), 10, 11, 12)
+
+	t.Log("Creating a new archive without closing the previous one")
+	archive, err = stream.NewArchive(dir, 10, 0)
+	assert.NilError(t, err)
+	segments, err := archive.Segments()
+	assert.NilError(t, err)
+	assert.Equal(t, len(segments), 1)
+	assertSegment(t, segments[0], 10, 12)
+	files, err := ioutil.ReadDir(dir)
+	assert.NilError(t, err)
+	assert.Equal(t, len(files), 1)
+}
+
+func getArchiveRecord(blockNum uint64) *stream.DeltaRecord {
+	return &stream.DeltaRecord{
+		Kind:       stream.RecordKind_Delta,
+		Operation:  "OPERATION_INSERT",
+		Code:       "dao.hypha",
+		Scope:      "dao.hypha",
+		TableName:  "documents",
+		PrimaryKey: fmt.Sprintf("%v", blockNum),
+		NewData:    []byte(fmt.Sprintf(`{"id":%v}`, blockNum)),
+		BlockNum:   blockNum,
+		Cursor:     fmt.Sprintf("cursor%v", blockNum),
+		ForkStep:   "STEP_NEW",
+	}
+}
+
+func assertSegment(t *testing.T, segment *stream.Segment, firstBlock, lastBlock uint64) {
+	assert.Equal(t, segment.FirstBlock, firstBlock)
+	assert.Equal(t, segment.LastBlock, lastBlock)
+	assert.Equal(t, filepath.Base(segment.Path), fmt.Sprintf("deltas-%012d-%012d.jsonl.gz", firstBlock, lastBlock))
+}
+
+func assertBlockNums(t *testing.T, jsonl string, blockNums ...uint64) {
+	lines := strings.Split(strings.TrimSpace(jsonl), "\n")
+	assert.Equal(t, len(lines), len(blockNums))
+	for i, line := range lines {
+		record := &stream.DeltaRecord{}
+		err := json.Unmarshal([]byte(line), record)
+		assert.NilError(t, err)
+		assert.Equal(t, record.BlockNum, blockNums[i])
+	}
+}
@@ -0,0 +1,162 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/sebastianmontero/dfuse-firehose-client/dfclient"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+)
+
+// Kinds of records
+const (
+	RecordKind_Delta     = "delta"
+	RecordKind_HeartBeat = "heartbeat"
+)
+
+// Serializable representation of a table delta as received from the stream, along with
+// its position in the stream, enables the storage and replay of deltas. Heart beats are also
+// represented by a record, in which case only the block and cursor information is set
+type DeltaRecord struct {
+	Kind       string          `json:"kind,omitempty"`
+	Operation  string          `json:"operation,omitempty"`
+	Code       string          `json:"code,omitempty"`
+	Scope      string          `json:"scope,omitempty"`
+	TableName  string          `json:"tableName,omitempty"`
+	PrimaryKey string          `json:"primaryKey,omitempty"`
+	OldData    json.RawMessage `json:"oldData,omitempty"`
+	NewData    json.RawMessage `json:"newData,omitempty"`
+	BlockNum   uint64          `json:"blockNum"`
+	BlockId    string          `json:"blockId,omitempty"`
+	BlockTime  *time.Time      `json:"blockTime,omitempty"`
+	Cursor     string          `json:"cursor"`
+	ForkStep   string          `json:"forkStep,omitempty"`
+}
+
+// Creates a delta record from a table delta received from the stream
+func NewDeltaRecord(delta *dfclient.TableDelta, cursor string, forkStep pbbstream.ForkStep) *DeltaRecord {
+	record := &DeltaRecord{
+		Kind:       RecordKind_Delta,
+		Operation:  delta.Operation.String(),
+		Code:       delta.Code,
+		Scope:      delta.Scope,
+		TableName:  delta.TableName,
+		PrimaryKey: delta.PrimaryKey,
+		OldData:    toRawMessage(delta.OldData),
+		NewData:    toRawMessage(delta.NewData),
+		Cursor:     cursor,
+		ForkStep:   forkStep.String(),
+	}
+	record.setBlock(delta.Block)
+	return record
+}
+
+// Creates a record for a heart beat received from the stream
+func NewHeartBeatRecord(block *pbcodec.Block, cursor string) *DeltaRecord {
+	record := &DeltaRecord{
+		Kind:   RecordKind_HeartBeat,
+		Cursor: cursor,
+	}
+	record.setBlock(block)
+	return record
+}
+
+func (m *DeltaRecord) setBlock(block *pbcodec.Block) {
+	if block == nil {
+		return
+	}
+	m.BlockNum = uint64(block.Number)
+	m.BlockId = block.Id
+	if block.Header != nil && block.Header.Timestamp != nil {
+		blockTime, err := block.Time()
+		if err == nil {
+			m.BlockTime = &blockTime
+		}
+	}
+}
+
+// Indicates whether the record represents a heart beat
+func (m *DeltaRecord) IsHeartBeat() bool {
+	return m.Kind == RecordKind_HeartBeat
+}
+
+// Returns the block of the record, only the id, number and timestamp are set
+func (m *DeltaRecord) GetBlock() (*pbcodec.Block, error) {
+	block := &pbcodec.Block{
+		Id:     m.BlockId,
+		Number: uint32(m.BlockNum),
+		Header: &pbcodec.BlockHeader{},
+	}
+	if m.BlockTime != nil {
+		timestamp, err := ptypes.TimestampProto(*m.BlockTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block time: %v for delta record: %v, error: %v", m.BlockTime, m, err)
+		}
+		block.Header.Timestamp = timestamp
+	}
+	return block, nil
+}
+
+// Returns the table delta represented by this record, the block only has the id, number
+// and timestamp set
+func (m *DeltaRecord) ToTableDelta() (*dfclient.TableDelta, error) {
+	operation, ok := pbcodec.DBOp_Operation_value[m.Operation]
+	if !ok {
+		return nil, fmt.Errorf("invalid operation: %v for delta record: %v", m.Operation, m)
+	}
+	block, err := m.GetBlock()
+	if err != nil {
+		return nil, err
+	}
+	return &dfclient.TableDelta{
+		Operation:  pbcodec.DBOp_Operation(operation),
+		Code:       m.Code,
+		Scope:      m.Scope,
+		TableName:  m.TableName,
+		PrimaryKey: m.PrimaryKey,
+		OldData:    fromRawMessage(m.OldData),
+		NewData:    fromRawMessage(m.NewData),
+		Block:      block,
+	}, nil
+}
+
+// Returns the fork step of the delta
+func (m *DeltaRecord) GetForkStep() (pbbstream.ForkStep, error) {
+	forkStep, ok := pbbstream.ForkStep_value[m.ForkStep]
+	if !ok {
+		return pbbstream.ForkStep_STEP_UNKNOWN, fmt.Errorf("invalid fork step: %v for delta record: %v", m.ForkStep, m)
+	}
+	return pbbstream.ForkStep(forkStep), nil
+}
+
+func (m *DeltaRecord) String() string {
+	return fmt.Sprintf("DeltaRecord{Kind: %v, Operation: %v, Code: %v, Scope: %v, TableName: %v, PrimaryKey: %v, BlockNum: %v, Cursor: %v, ForkStep: %v, OldData: %v, NewData: %v}",
+		m.Kind, m.Operation, m.Code, m.Scope, m.TableName, m.PrimaryKey, m.BlockNum, m.Cursor, m.ForkStep, string(m.OldData), string(m.NewData))
+}
+
+// Table rows are JSON objects, data that is not valid JSON is stored as a JSON string so that
+// it can still be recorded
+func toRawMessage(data []byte) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+	if !json.Valid(data) {
+		encoded, _ := json.Marshal(string(data))
+		return json.RawMessage(encoded)
+	}
+	return json.RawMessage(data)
+}
+
+func fromRawMessage(data json.RawMessage) []byte {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	var raw string
+	if json.Unmarshal(data, &raw) == nil {
+		return []byte(raw)
+	}
+	return []byte(data)
+}
@@ -0,0 +1,83 @@
+package stream_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/sebastianmontero/dfuse-firehose-client/dfclient"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/stream"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+	"gotest.tools/assert"
+)
+
+func TestDeltaRecordRoundTrip(t *testing.T) {
+	blockTime := time.Date(2021, 1, 11, 21, 52, 32, 500000000, time.UTC)
+	timestamp, err := ptypes.TimestampProto(blockTime)
+	assert.NilError(t, err)
+	delta := &dfclient.TableDelta{
+		Operation:  pbcodec.DBOp_OPERATION_UPDATE,
+		Code:       "dao.hypha",
+		Scope:      "dao.hypha",
+		TableName:  "edges",
+		PrimaryKey: "2475211255",
+		OldData:    []byte(`{"edge_name":"settings","from_node":1,"to_node":2}`),
+		NewData:    []byte(`{"edge_name":"settings","from_node":1,"to_node":3}`),
+		Block: &pbcodec.Block{
+			Id:     "0000000a",
+			Number: 10,
+			Header: &pbcodec.BlockHeader{
+				Timestamp: timestamp,
+			},
+		},
+	}
+	record := stream.NewDeltaRecord(delta, "cursor1", pbbstream.ForkStep_STEP_NEW)
+	encoded, err := json.Marshal(record)
+	assert.NilError(t, err)
+
+	decoded := &stream.DeltaRecord{}
+	err = json.Unmarshal(encoded, decoded)
+	assert.NilError(t, err)
+	assert.Equal(t, decoded.Cursor, "cursor1")
+	assert.Equal(t, decoded.BlockNum, uint64(10))
+	forkStep, err := decoded.GetForkStep()
+	assert.NilError(t, err)
+	assert.Equal(t, forkStep, pbbstream.ForkStep_STEP_NEW)
+
+	actual, err := decoded.ToTableDelta()
+	assert.NilError(t, err)
+	assert.Equal(t, actual.Operation, delta.Operation)
+	assert.Equal(t, actual.Code, delta.Code)
+	assert.Equal(t, actual.Scope, delta.Scope)
+	assert.Equal(t, actual.TableName, delta.TableName)
+	assert.Equal(t, actual.PrimaryKey, delta.PrimaryKey)
+	assert.Equal(t, string(actual.OldData), string(delta.OldData))
+	assert.Equal(t, string(actual.NewData), string(delta.NewData))
+	assert.Equal(t, actual.Block.Id, delta.Block.Id)
+	assert.Equal(t, actual.Block.Number, delta.Block.Number)
+	assert.Equal(t, actual.Block.MustTime(), blockTime)
+}
+
+func TestDeltaRecordInvalidData(t *testing.T) {
+	delta := &dfclient.TableDelta{
+		Operation: pbcodec.DBOp_OPERATION_INSERT,
+		TableName: "documents",
+		NewData:   []byte(`{"id":1,"content_groups":`),
+		Block: &pbcodec.Block{
+			Number: 10,
+		},
+	}
+	record := stream.NewDeltaRecord(delta, "cursor1", pbbstream.ForkStep_STEP_NEW)
+	encoded, err := json.Marshal(record)
+	assert.NilError(t, err)
+
+	decoded := &stream.DeltaRecord{}
+	err = json.Unmarshal(encoded, decoded)
+	assert.NilError(t, err)
+	actual, err := decoded.ToTableDelta()
+	assert.NilError(t, err)
+	assert.Equal(t, string(actual.NewData), string(delta.NewData))
+	assert.Assert(t, actual.OldData == nil)
+}
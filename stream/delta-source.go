@@ -0,0 +1,21 @@
+package stream
+
+import (
+	"context"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/sebastianmontero/dfuse-firehose-client/dfclient"
+)
+
+// Operation of the deltas of rows whose previous value is unknown, the row was either inserted
+// or updated, the delta only has new data and the stored value has to be replaced by it
+const OperationUpsert = pbcodec.DBOp_OPERATION_UNKNOWN
+
+// Provides the table deltas that are processed by the document cache, enables the cache to be fed
+// from the dfuse firehose, a state history endpoint or a delta file
+type DeltaSource interface {
+	// Streams the deltas that match the request to the handler, blocks until the stream completes
+	// or the context is cancelled, in which case the stream is torn down and neither an error nor
+	// the completion are reported to the handler
+	DeltaStream(ctx context.Context, request *dfclient.DeltaStreamRequest, handler dfclient.DeltaStreamHandler)
+}
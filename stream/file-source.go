@@ -0,0 +1,231 @@
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sebastianmontero/dfuse-firehose-client/dfclient"
+	"github.com/streamingfast/bstream"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+)
+
+// Delta source that replays the delta records stored in a JSONL file, one record per line,
+// enables the reproduction of production incidents and offline end to end tests. The deltas
+// are provided as they were recorded, so undo deltas are not reversed. Files with the format of
+// the logged deltas, as in delta-samples.txt, are also supported
+type FileSource struct {
+	FilePath string
+}
+
+func NewFileSource(filePath string) *FileSource {
+	return &FileSource{
+		FilePath: filePath,
+	}
+}
+
+// Streams the deltas in the file that match the request to the handler, if the request has a
+// start cursor, the records up to and including the one with that cursor are skipped, or for a
+// bootstrap cursor, the records up to and including its block, otherwise the records for blocks
+// previous to the start block are skipped
+func (m *FileSource) DeltaStream(ctx context.Context, request *dfclient.DeltaStreamRequest, handler dfclient.DeltaStreamHandler) {
+	file, err := os.Open(m.FilePath)
+	if err != nil {
+		handler.OnError(fmt.Errorf("failed to open delta file: %v, error: %v", m.FilePath, err))
+		return
+	}
+	defer file.Close()
+	lastBlockRef, err := ReplayRecords(&contextReader{ctx: ctx, reader: file}, request, handler)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		handler.OnError(fmt.Errorf("failed to replay delta file: %v, error: %v", m.FilePath, err))
+		return
+	}
+	handler.OnComplete(lastBlockRef)
+}
+
+// Reader that stops reading once the context is cancelled
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (m *contextReader) Read(p []byte) (int, error) {
+	err := m.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	return m.reader.Read(p)
+}
+
+// Streams the delta records read from the reader that match the request to the handler, returns
+// the reference to the last block processed
+func ReplayRecords(reader io.Reader, request *dfclient.DeltaStreamRequest, handler dfclient.DeltaStreamHandler) (bstream.BlockRef, error) {
+	lastBlockRef := bstream.BlockRefEmpty
+	forkSteps := make(map[pbbstream.ForkStep]bool, len(request.ForkSteps))
+	for _, forkStep := range request.ForkSteps {
+		forkSteps[forkStep] = true
+	}
+	bootstrapBlockNum := getBootstrapBlockNum(request.StartCursor)
+	skipping := request.StartCursor != "" && bootstrapBlockNum == 0
+	sampleParser := &deltaSampleParser{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record *DeltaRecord
+		if strings.HasPrefix(line, "{") {
+			record = &DeltaRecord{}
+			err := json.Unmarshal([]byte(line), record)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode delta record at line: %v, error: %v", lineNum, err)
+			}
+		} else {
+			var err error
+			record, err = sampleParser.parse(line)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode delta sample at line: %v, error: %v", lineNum, err)
+			}
+			if record == nil {
+				continue
+			}
+		}
+		if bootstrapBlockNum > 0 && record.BlockNum <= bootstrapBlockNum {
+			continue
+		}
+		if skipping {
+			skipping = record.Cursor != request.StartCursor
+			continue
+		}
+		if request.StartCursor == "" && request.StartBlockNum > 0 && record.BlockNum < uint64(request.StartBlockNum) {
+			continue
+		}
+		if request.StopBlockNum > 0 && record.BlockNum > request.StopBlockNum {
+			break
+		}
+		block, err := record.GetBlock()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get block of delta record at line: %v, error: %v", lineNum, err)
+		}
+		if record.IsHeartBeat() {
+			handler.OnHeartBeat(block, record.Cursor)
+		} else {
+			forkStep, err := record.GetForkStep()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get fork step of delta record at line: %v, error: %v", lineNum, err)
+			}
+			if (len(forkSteps) > 0 && !forkSteps[forkStep]) || !request.HasTable(record.Code, record.TableName) {
+				continue
+			}
+			delta, err := record.ToTableDelta()
+			if err != nil {
+				return nil, fmt.Errorf("failed to get table delta of delta record at line: %v, error: %v", lineNum, err)
+			}
+			handler.OnDelta(delta, record.Cursor, forkStep)
+		}
+		lastBlockRef = bstream.NewBlockRef(record.BlockId, record.BlockNum)
+	}
+	err := scanner.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read delta records, error: %v", err)
+	}
+	if skipping {
+		return nil, fmt.Errorf("start cursor: %v not found", request.StartCursor)
+	}
+	return lastBlockRef, nil
+}
+
+// Returns the block number of a bootstrap cursor, which has the format __<blockNum>__0__0 and
+// indicates that the cache has the state up to and including that block, 0 if the cursor is not
+// a bootstrap cursor
+func getBootstrapBlockNum(cursor string) uint64 {
+	deltaCursor, err := dfclient.NewDeltaCursor(cursor)
+	if err != nil || deltaCursor.BlockCursor != "" || deltaCursor.TraceIndex != 0 || deltaCursor.DeltaIndex != 0 {
+		return 0
+	}
+	return deltaCursor.BlockNum
+}
+
+// Assembles delta records from the lines of logged deltas, as in delta-samples.txt, each delta
+// spans the lines:
+//
+//	Cursor:  <cursor> Fork Step: <fork step>
+//	On Delta:  Op: <operation>, Code: <code>, Scope: <scope>, TableName: <table>, PrimaryKey: <key>
+//	OldData: <old data>
+//	NewData: <new data>
+//
+// other lines, i.e. Decoded data, are ignored. The block number is taken from the cursor, the
+// block id and time are not logged
+type deltaSampleParser struct {
+	record *DeltaRecord
+}
+
+// Processes a line, returns the record once all of its lines have been processed, nil otherwise
+func (m *deltaSampleParser) parse(line string) (*DeltaRecord, error) {
+	switch {
+	case strings.HasPrefix(line, "Cursor:"):
+		parts := strings.SplitN(strings.TrimPrefix(line, "Cursor:"), "Fork Step:", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid cursor line: %v", line)
+		}
+		cursor := strings.TrimSpace(parts[0])
+		deltaCursor, err := dfclient.NewDeltaCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		m.record = &DeltaRecord{
+			Kind:     RecordKind_Delta,
+			BlockNum: deltaCursor.BlockNum,
+			Cursor:   cursor,
+			ForkStep: strings.TrimSpace(parts[1]),
+		}
+	case strings.HasPrefix(line, "On Delta:"):
+		if m.record == nil {
+			return nil, fmt.Errorf("delta line without a preceding cursor line: %v", line)
+		}
+		for _, field := range strings.Split(strings.TrimPrefix(line, "On Delta:"), ",") {
+			parts := strings.SplitN(field, ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid delta field: %v in line: %v", field, line)
+			}
+			value := strings.TrimSpace(parts[1])
+			switch strings.TrimSpace(parts[0]) {
+			case "Op":
+				m.record.Operation = value
+			case "Code":
+				m.record.Code = value
+			case "Scope":
+				m.record.Scope = value
+			case "TableName":
+				m.record.TableName = value
+			case "PrimaryKey":
+				m.record.PrimaryKey = value
+			}
+		}
+	case strings.HasPrefix(line, "OldData:"):
+		if m.record != nil {
+			m.record.OldData = toRawMessage([]byte(strings.TrimSpace(strings.TrimPrefix(line, "OldData:"))))
+		}
+	case strings.HasPrefix(line, "NewData:"):
+		// NewData lines of deltas whose cursor line is not in the sample are ignored
+		if m.record == nil || m.record.Operation == "" {
+			return nil, nil
+		}
+		record := m.record
+		record.NewData = toRawMessage([]byte(strings.TrimSpace(strings.TrimPrefix(line, "NewData:"))))
+		m.record = nil
+		return record, nil
+	}
+	return nil, nil
+}
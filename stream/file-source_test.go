@@ -0,0 +1,174 @@
+package stream_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/sebastianmontero/dfuse-firehose-client/dfclient"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/stream"
+	"github.com/streamingfast/bstream"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+	"gotest.tools/assert"
+)
+
+const testRecords = `{"kind":"delta","operation":"OPERATION_INSERT","code":"dao.hypha","scope":"dao.hypha","tableName":"documents","primaryKey":"1","newData":{"id":1},"blockNum":10,"blockId":"b10","cursor":"c10_1","forkStep":"STEP_NEW"}
+{"kind":"delta","operation":"OPERATION_INSERT","code":"dao.hypha","scope":"dao.hypha","tableName":"votes","primaryKey":"2","newData":{"id":2},"blockNum":10,"blockId":"b10","cursor":"c10_2","forkStep":"STEP_NEW"}
+
+{"kind":"delta","operation":"OPERATION_INSERT","code":"dao.hypha","scope":"dao.hypha","tableName":"edges","primaryKey":"3","newData":{"edge_name":"member","from_node":1,"to_node":2},"blockNum":11,"blockId":"b11","cursor":"c11_1","forkStep":"STEP_NEW"}
+{"kind":"heartbeat","blockNum":12,"blockId":"b12","cursor":"c12"}
+{"kind":"delta","operation":"OPERATION_REMOVE","code":"dao.hypha","scope":"dao.hypha","tableName":"edges","primaryKey":"3","oldData":{"edge_name":"member","from_node":1,"to_node":2},"blockNum":11,"blockId":"b11","cursor":"c11_undo","forkStep":"STEP_UNDO"}
+{"kind":"delta","operation":"OPERATION_UPDATE","code":"dao.hypha","scope":"dao.hypha","tableName":"documents","primaryKey":"1","oldData":{"id":1},"newData":{"id":1,"creator":"dao.hypha"},"blockNum":13,"blockId":"b13","cursor":"c13_1","forkStep":"STEP_NEW"}
+`
+
+type event struct {
+	kind     string
+	cursor   string
+	blockNum uint32
+	forkStep pbbstream.ForkStep
+	delta    *dfclient.TableDelta
+}
+
+type recordingHandler struct {
+	events       []*event
+	errors       []error
+	lastBlockRef bstream.BlockRef
+	completed    bool
+}
+
+func (m *recordingHandler) OnDelta(delta *dfclient.TableDelta, cursor string, forkStep pbbstream.ForkStep) {
+	m.events = append(m.events, &event{kind: "delta", cursor: cursor, blockNum: delta.Block.Number, forkStep: forkStep, delta: delta})
+}
+
+func (m *recordingHandler) OnHeartBeat(block *pbcodec.Block, cursor string) {
+	m.events = append(m.events, &event{kind: "heartbeat", cursor: cursor, blockNum: block.Number})
+}
+
+func (m *recordingHandler) OnError(err error) {
+	m.errors = append(m.errors, err)
+}
+
+func (m *recordingHandler) OnComplete(lastBlockRef bstream.BlockRef) {
+	m.lastBlockRef = lastBlockRef
+	m.completed = true
+}
+
+func (m *recordingHandler) cursors() []string {
+	cursors := make([]string, 0, len(m.events))
+	for _, event := range m.events {
+		cursors = append(cursors, event.cursor)
+	}
+	return cursors
+}
+
+func getTestRequest() *dfclient.DeltaStreamRequest {
+	request := &dfclient.DeltaStreamRequest{
+		ForkSteps: []pbbstream.ForkStep{pbbstream.ForkStep_STEP_NEW, pbbstream.ForkStep_STEP_UNDO},
+	}
+	request.AddTables("dao.hypha", []string{"documents", "edges"})
+	return request
+}
+
+func TestFileSource(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "deltas.jsonl")
+	err := ioutil.WriteFile(filePath, []byte(testRecords), 0644)
+	assert.NilError(t, err)
+
+	handler := &recordingHandler{}
+	stream.NewFileSource(filePath).DeltaStream(context.Background(), getTestRequest(), handler)
+	assert.Equal(t, len(handler.errors), 0)
+	assert.Assert(t, handler.completed)
+	assert.Equal(t, handler.lastBlockRef.Num(), uint64(13))
+	assert.Equal(t, handler.lastBlockRef.ID(), "b13")
+	assert.DeepEqual(t, handler.cursors(), []string{"c10_1", "c11_1", "c12", "c11_undo", "c13_1"})
+
+	assert.Equal(t, handler.events[0].delta.Operation, pbcodec.DBOp_OPERATION_INSERT)
+	assert.Equal(t, handler.events[0].delta.TableName, "documents")
+	assert.Equal(t, string(handler.events[0].delta.NewData), `{"id":1}`)
+	assert.Equal(t, handler.events[0].blockNum, uint32(10))
+	assert.Equal(t, handler.events[2].kind, "heartbeat")
+	assert.Equal(t, handler.events[2].blockNum, uint32(12))
+	assert.Equal(t, handler.events[3].forkStep, pbbstream.ForkStep_STEP_UNDO)
+	assert.Equal(t, handler.events[3].delta.Operation, pbcodec.DBOp_OPERATION_REMOVE)
+	assert.Equal(t, string(handler.events[3].delta.OldData), `{"edge_name":"member","from_node":1,"to_node":2}`)
+	assert.Equal(t, handler.events[4].delta.Operation, pbcodec.DBOp_OPERATION_UPDATE)
+}
+
+func TestReplayRecordsFromCursor(t *testing.T) {
+	request := getTestRequest()
+	request.StartCursor = "c11_1"
+	handler := &recordingHandler{}
+	_, err := stream.ReplayRecords(strings.NewReader(testRecords), request, handler)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, handler.cursors(), []string{"c12", "c11_undo", "c13_1"})
+}
+
+func TestReplayRecordsFromBootstrapCursor(t *testing.T) {
+	request := getTestRequest()
+	request.StartCursor = "__10__0__0"
+	handler := &recordingHandler{}
+	_, err := stream.ReplayRecords(strings.NewReader(testRecords), request, handler)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, handler.cursors(), []string{"c11_1", "c12", "c11_undo", "c13_1"})
+}
+
+func TestReplayRecordsFromDeltaSamples(t *testing.T) {
+	file, err := os.Open("../delta-samples.txt")
+	assert.NilError(t, err)
+	defer file.Close()
+	handler := &recordingHandler{}
+	lastBlockRef, err := stream.ReplayRecords(file, getTestRequest(), handler)
+	assert.NilError(t, err)
+	assert.Equal(t, len(handler.events), 4)
+	assert.Equal(t, lastBlockRef.Num(), uint64(88665122))
+	for i, event := range handler.events {
+		assert.Assert(t, strings.HasSuffix(event.cursor, fmt.Sprintf("__88665122__0__%v", i+5)))
+		assert.Equal(t, event.blockNum, uint32(88665122))
+		assert.Equal(t, event.forkStep, pbbstream.ForkStep_STEP_NEW)
+		assert.Equal(t, event.delta.Operation, pbcodec.DBOp_OPERATION_INSERT)
+		assert.Equal(t, event.delta.Code, "dao.hypha")
+		assert.Assert(t, len(event.delta.OldData) == 0)
+		assert.Assert(t, len(event.delta.NewData) > 0)
+	}
+	assert.Equal(t, handler.events[0].delta.TableName, "documents")
+	assert.Equal(t, handler.events[0].delta.PrimaryKey, "..........dwj")
+	assert.Equal(t, handler.events[1].delta.TableName, "edges")
+	assert.Assert(t, strings.Contains(string(handler.events[1].delta.NewData), `"edge_name":"owns"`))
+}
+
+func TestReplayRecordsBlockRange(t *testing.T) {
+	request := getTestRequest()
+	request.StartBlockNum = 11
+	request.StopBlockNum = 12
+	request.ForkSteps = []pbbstream.ForkStep{pbbstream.ForkStep_STEP_NEW}
+	handler := &recordingHandler{}
+	lastBlockRef, err := stream.ReplayRecords(strings.NewReader(testRecords), request, handler)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, handler.cursors(), []string{"c11_1", "c12"})
+	assert.Equal(t, lastBlockRef.Num(), uint64(12))
+}
+
+func TestReplayRecordsShouldFailForMissingCursor(t *testing.T) {
+	request := getTestRequest()
+	request.StartCursor = "c99"
+	handler := &recordingHandler{}
+	_, err := stream.ReplayRecords(strings.NewReader(testRecords), request, handler)
+	assert.ErrorContains(t, err, "start cursor: c99 not found")
+	assert.Equal(t, len(handler.events), 0)
+}
+
+func TestFileSourceShouldReportErrorForInvalidRecord(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "deltas.jsonl")
+	err := ioutil.WriteFile(filePath, []byte(fmt.Sprintf("%v{invalid\n", testRecords)), 0644)
+	assert.NilError(t, err)
+	handler := &recordingHandler{}
+	stream.NewFileSource(filePath).DeltaStream(context.Background(), getTestRequest(), handler)
+	assert.Equal(t, len(handler.errors), 1)
+	assert.ErrorContains(t, handler.errors[0], "failed to decode delta record at line: 8")
+	assert.Assert(t, !handler.completed)
+}
@@ -0,0 +1,78 @@
+package stream
+
+import (
+	"context"
+	"runtime"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/sebastianmontero/dfuse-firehose-client/dfclient"
+	"github.com/streamingfast/bstream"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+)
+
+// Streams the deltas from a dfuse firehose endpoint without support for cancellation, the dfuse
+// client implements this interface
+type FirehoseClient interface {
+	DeltaStream(request *dfclient.DeltaStreamRequest, handler dfclient.DeltaStreamHandler)
+}
+
+// Delta source that streams the deltas from a dfuse firehose endpoint using the dfuse client, which
+// decodes the deltas and provides the cursors. The client does not accept a context, so its stream
+// runs in its own goroutine and DeltaStream returns as soon as the context is cancelled, the events
+// the abandoned stream provides afterwards are dropped and its goroutine ends on the first of them
+type FirehoseSource struct {
+	Client FirehoseClient
+}
+
+func NewFirehoseSource(client FirehoseClient) *FirehoseSource {
+	return &FirehoseSource{
+		Client: client,
+	}
+}
+
+// Streams the deltas of the requested tables to the handler, starting from the cursor if the
+// request has one, otherwise from the start block
+func (m *FirehoseSource) DeltaStream(ctx context.Context, request *dfclient.DeltaStreamRequest, handler dfclient.DeltaStreamHandler) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.Client.DeltaStream(request, &cancellableHandler{ctx: ctx, handler: handler})
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
+
+// Forwards the stream events to the handler while the context is active, once it is cancelled
+// the goroutine that provides the events is ended so that the abandoned stream stops being read
+type cancellableHandler struct {
+	ctx     context.Context
+	handler dfclient.DeltaStreamHandler
+}
+
+func (m *cancellableHandler) OnDelta(delta *dfclient.TableDelta, cursor string, forkStep pbbstream.ForkStep) {
+	m.exitIfCancelled()
+	m.handler.OnDelta(delta, cursor, forkStep)
+}
+
+func (m *cancellableHandler) OnHeartBeat(block *pbcodec.Block, cursor string) {
+	m.exitIfCancelled()
+	m.handler.OnHeartBeat(block, cursor)
+}
+
+func (m *cancellableHandler) OnError(err error) {
+	m.exitIfCancelled()
+	m.handler.OnError(err)
+}
+
+func (m *cancellableHandler) OnComplete(lastBlockRef bstream.BlockRef) {
+	m.exitIfCancelled()
+	m.handler.OnComplete(lastBlockRef)
+}
+
+func (m *cancellableHandler) exitIfCancelled() {
+	if m.ctx.Err() != nil {
+		runtime.Goexit()
+	}
+}
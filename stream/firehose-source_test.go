@@ -0,0 +1,81 @@
+package stream_test
+
+import (
+	"context"
+	"testing"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	"github.com/sebastianmontero/dfuse-firehose-client/dfclient"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/stream"
+	"github.com/streamingfast/bstream"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+	"gotest.tools/assert"
+)
+
+// Firehose client that provides a delta, waits to be resumed and provides another delta
+type stubFirehoseClient struct {
+	started  chan struct{}
+	resume   chan struct{}
+	exited   chan struct{}
+	returned bool
+}
+
+func newStubFirehoseClient() *stubFirehoseClient {
+	return &stubFirehoseClient{
+		started: make(chan struct{}),
+		resume:  make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+}
+
+func (m *stubFirehoseClient) DeltaStream(request *dfclient.DeltaStreamRequest, handler dfclient.DeltaStreamHandler) {
+	defer close(m.exited)
+	handler.OnDelta(getFirehoseDelta(10), "cursor10", pbbstream.ForkStep_STEP_NEW)
+	close(m.started)
+	<-m.resume
+	handler.OnDelta(getFirehoseDelta(11), "cursor11", pbbstream.ForkStep_STEP_NEW)
+	handler.OnComplete(bstream.NewBlockRef("11", 11))
+	m.returned = true
+}
+
+func getFirehoseDelta(blockNum uint32) *dfclient.TableDelta {
+	return &dfclient.TableDelta{
+		Operation: pbcodec.DBOp_OPERATION_INSERT,
+		Code:      "dao.hypha",
+		Scope:     "dao.hypha",
+		TableName: "documents",
+		Block:     &pbcodec.Block{Number: blockNum},
+	}
+}
+
+func TestFirehoseSource(t *testing.T) {
+	client := newStubFirehoseClient()
+	close(client.resume)
+	handler := &recordingHandler{}
+	stream.NewFirehoseSource(client).DeltaStream(context.Background(), getTestRequest(), handler)
+
+	assert.DeepEqual(t, handler.cursors(), []string{"cursor10", "cursor11"})
+	assert.Equal(t, handler.completed, true)
+	assert.Equal(t, client.returned, true)
+}
+
+func TestFirehoseSourceCancel(t *testing.T) {
+	client := newStubFirehoseClient()
+	handler := &recordingHandler{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		stream.NewFirehoseSource(client).DeltaStream(ctx, getTestRequest(), handler)
+		close(done)
+	}()
+	<-client.started
+	cancel()
+	//Returns without waiting for the client
+	<-done
+	close(client.resume)
+	<-client.exited
+
+	assert.DeepEqual(t, handler.cursors(), []string{"cursor10"})
+	assert.Equal(t, handler.completed, false)
+	assert.Equal(t, client.returned, false)
+}
@@ -0,0 +1,546 @@
+package stream
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	eos "github.com/eoscanada/eos-go"
+	"github.com/eoscanada/eos-go/ship"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/gorilla/websocket"
+	"github.com/sebastianmontero/dfuse-firehose-client/dfclient"
+	"github.com/streamingfast/bstream"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+)
+
+// Name of the state history table that contains the contract table rows
+const ShipContractRowTable = "contract_row"
+
+// Maximum number of results the state history plugin sends before waiting for an acknowledgement
+const ShipMaxMessagesInFlight uint32 = 10
+
+// Decodes binary table rows into JSON using the contract ABI, the dfuse client decoder
+// implements this interface
+type RowDecoder interface {
+	Decode(account eos.AccountName, table eos.TableName, data []byte) ([]byte, error)
+}
+
+// Delta source that connects to the websocket endpoint of a nodeos state history plugin, the
+// contract_row deltas of the requested tables are decoded using the contract ABI and provided
+// to the handler. State history does not distinguish inserts from updates nor provides the
+// previous values of a row, so the values of the rows changed in reversible blocks are kept,
+// present rows are provided as updates that have the kept value as old data, or as upserts
+// without old data when the row was last changed in an irreversible block, and removed rows
+// as removes that have the removed values as old data. The deltas of reversible blocks are
+// kept so that they can be undone when a fork occurs.
+//
+// Cursors have the format: <blockId>__<blockNum>__0__<deltaIndex>, a delta index of 0 indicates
+// that the block was completely processed, otherwise the number of deltas of the block already
+// processed. Only the block number and delta index are used to resume the stream
+type ShipSource struct {
+	Endpoint string
+	Decoder  RowDecoder
+}
+
+func NewShipSource(endpoint string, decoder RowDecoder) *ShipSource {
+	return &ShipSource{
+		Endpoint: endpoint,
+		Decoder:  decoder,
+	}
+}
+
+// Streams the deltas of the requested tables to the handler, starting from the cursor if the
+// request has one, otherwise from the start block
+func (m *ShipSource) DeltaStream(ctx context.Context, request *dfclient.DeltaStreamRequest, handler dfclient.DeltaStreamHandler) {
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, m.Endpoint, nil)
+	if err != nil {
+		if ctx.Err() == nil {
+			handler.OnError(fmt.Errorf("failed to connect to state history endpoint: %v, error: %v", m.Endpoint, err))
+		}
+		return
+	}
+	defer conn.Close()
+	// Closing the connection unblocks the stream when the context is cancelled
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-finished:
+		}
+	}()
+	lastBlockRef, err := newShipStream(conn, m.Decoder, request, handler).run()
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		handler.OnError(fmt.Errorf("state history stream failed, endpoint: %v, error: %v", m.Endpoint, err))
+		return
+	}
+	handler.OnComplete(lastBlockRef)
+}
+
+// Value of a row changed in a reversible block, in its binary and decoded forms
+type shipRow struct {
+	value    []byte
+	data     []byte
+	blockNum uint32
+}
+
+type shipDelta struct {
+	delta  *dfclient.TableDelta
+	cursor string
+	// Value the row had before the delta, nil if unknown
+	previous *shipRow
+}
+
+// Deltas provided for a reversible block
+type shipBlock struct {
+	num    uint32
+	prevId string
+	deltas []*shipDelta
+}
+
+// Keeps the state of a state history stream
+type shipStream struct {
+	conn                *websocket.Conn
+	decoder             RowDecoder
+	request             *dfclient.DeltaStreamRequest
+	handler             dfclient.DeltaStreamHandler
+	forkSteps           map[pbbstream.ForkStep]bool
+	irreversibleOnly    bool
+	lastBlockNum        uint32
+	skipBlockNum        uint32
+	skipDeltas          int
+	countSinceHeartBeat uint
+	reversible          []*shipBlock
+	lastIrreversible    uint32
+	rows                map[string]*shipRow
+}
+
+func newShipStream(conn *websocket.Conn, decoder RowDecoder, request *dfclient.DeltaStreamRequest, handler dfclient.DeltaStreamHandler) *shipStream {
+	forkSteps := make(map[pbbstream.ForkStep]bool, len(request.ForkSteps))
+	for _, forkStep := range request.ForkSteps {
+		forkSteps[forkStep] = true
+	}
+	return &shipStream{
+		conn:             conn,
+		decoder:          decoder,
+		request:          request,
+		handler:          handler,
+		forkSteps:        forkSteps,
+		irreversibleOnly: forkSteps[pbbstream.ForkStep_STEP_IRREVERSIBLE] && !forkSteps[pbbstream.ForkStep_STEP_NEW],
+		reversible:       make([]*shipBlock, 0),
+		rows:             make(map[string]*shipRow),
+	}
+}
+
+// Requests the blocks and processes the results until the stop block is reached, returns the
+// reference to the last block processed
+func (m *shipStream) run() (bstream.BlockRef, error) {
+	startBlockNum, err := m.startPosition()
+	if err != nil {
+		return nil, err
+	}
+	// The first message contains the state history ABI, the types are already known
+	_, _, err = m.conn.ReadMessage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state history ABI, error: %v", err)
+	}
+	endBlockNum := uint32(math.MaxUint32)
+	if m.request.StopBlockNum > 0 {
+		endBlockNum = uint32(m.request.StopBlockNum) + 1
+	}
+	err = m.conn.WriteMessage(websocket.BinaryMessage, ship.NewRequest(&ship.GetBlocksRequestV0{
+		StartBlockNum:       startBlockNum,
+		EndBlockNum:         endBlockNum,
+		MaxMessagesInFlight: ShipMaxMessagesInFlight,
+		HavePositions:       []*ship.BlockPosition{},
+		IrreversibleOnly:    m.irreversibleOnly,
+		FetchBlock:          true,
+		FetchDeltas:         true,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("failed to send get blocks request, error: %v", err)
+	}
+	lastBlockRef := bstream.BlockRefEmpty
+	for {
+		_, message, err := m.conn.ReadMessage()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read get blocks result, error: %v", err)
+		}
+		result, err := DecodeShipBlocksResult(message)
+		if err != nil {
+			return nil, err
+		}
+		if result.ThisBlock != nil {
+			err = m.processBlock(result)
+			if err != nil {
+				return nil, fmt.Errorf("failed to process block: %v, error: %v", result.ThisBlock.BlockNum, err)
+			}
+			lastBlockRef = bstream.NewBlockRef(result.ThisBlock.BlockID.String(), uint64(result.ThisBlock.BlockNum))
+		}
+		err = m.conn.WriteMessage(websocket.BinaryMessage, ship.NewGetBlocksAck(1))
+		if err != nil {
+			return nil, fmt.Errorf("failed to send get blocks ack, error: %v", err)
+		}
+		if m.request.StopBlockNum > 0 && lastBlockRef.Num() >= m.request.StopBlockNum {
+			return lastBlockRef, nil
+		}
+	}
+}
+
+// Determines the block from which to start the stream and the number of deltas of that block
+// to skip based on the request cursor
+func (m *shipStream) startPosition() (uint32, error) {
+	cursor, err := m.request.ParseCursor()
+	if err != nil {
+		return 0, err
+	}
+	if !cursor.HasBlockNum() {
+		return uint32(m.request.StartBlockNum), nil
+	}
+	if cursor.DeltaIndex == 0 {
+		return uint32(cursor.BlockNum) + 1, nil
+	}
+	m.skipBlockNum = uint32(cursor.BlockNum)
+	m.skipDeltas = cursor.DeltaIndex
+	return m.skipBlockNum, nil
+}
+
+// Provides the deltas of interest of the block to the handler, if the block number is not
+// greater than the last one processed a fork occurred and the deltas of the abandoned blocks
+// are undone first
+func (m *shipStream) processBlock(result *ShipBlocksResult) error {
+	num := result.ThisBlock.BlockNum
+	if m.lastBlockNum > 0 && num <= m.lastBlockNum {
+		m.undo(num)
+	}
+	m.lastBlockNum = num
+	block, err := result.GetBlock()
+	if err != nil {
+		return err
+	}
+	deltas, err := m.getDeltas(result, block)
+	if err != nil {
+		return err
+	}
+	forkStep := pbbstream.ForkStep_STEP_NEW
+	if m.irreversibleOnly {
+		forkStep = pbbstream.ForkStep_STEP_IRREVERSIBLE
+	}
+	skip := 0
+	if num == m.skipBlockNum {
+		skip = m.skipDeltas
+		m.skipBlockNum = 0
+	}
+	m.countSinceHeartBeat++
+	if len(m.forkSteps) == 0 || m.forkSteps[forkStep] {
+		for i := skip; i < len(deltas); i++ {
+			m.handler.OnDelta(deltas[i].delta, deltas[i].cursor, forkStep)
+			m.countSinceHeartBeat = 0
+		}
+	}
+	if m.countSinceHeartBeat > m.request.HeartBeatFrequency {
+		m.handler.OnHeartBeat(block, ShipCursor(block.Id, num, 0))
+		m.countSinceHeartBeat = 0
+	}
+	if !m.irreversibleOnly {
+		prevId := ""
+		if result.PrevBlock != nil {
+			prevId = result.PrevBlock.BlockID.String()
+		}
+		m.reversible = append(m.reversible, &shipBlock{
+			num:    num,
+			prevId: prevId,
+			deltas: deltas,
+		})
+		m.pruneReversible(result.LastIrreversible.BlockNum)
+	}
+	return nil
+}
+
+// Decodes the rows of the requested tables, the block traces are set so that the handler can
+// find the operations of the block
+func (m *shipStream) getDeltas(result *ShipBlocksResult, block *pbcodec.Block) ([]*shipDelta, error) {
+	deltas := make([]*shipDelta, 0)
+	dbOps := make([]*pbcodec.DBOp, 0)
+	for _, tableDelta := range result.Deltas {
+		if tableDelta.Name != ShipContractRowTable {
+			continue
+		}
+		for _, row := range tableDelta.Rows {
+			contractRow, err := DecodeShipContractRow(row.Data)
+			if err != nil {
+				return nil, err
+			}
+			if !m.request.HasTable(string(contractRow.Code), string(contractRow.Table)) {
+				continue
+			}
+			data, err := m.decoder.Decode(eos.AccountName(contractRow.Code), eos.TableName(contractRow.Table), contractRow.Value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode row of table: %v, primary key: %v, error: %v", contractRow.Table, contractRow.PrimaryKey, err)
+			}
+			dbOp := &pbcodec.DBOp{
+				Code:       string(contractRow.Code),
+				Scope:      string(contractRow.Scope),
+				TableName:  string(contractRow.Table),
+				PrimaryKey: eos.NameToString(contractRow.PrimaryKey),
+			}
+			delta := &dfclient.TableDelta{
+				Code:       dbOp.Code,
+				Scope:      dbOp.Scope,
+				TableName:  dbOp.TableName,
+				PrimaryKey: dbOp.PrimaryKey,
+				DBOp:       dbOp,
+				Block:      block,
+			}
+			key := rowKey(dbOp)
+			previous := m.rows[key]
+			if row.Present {
+				dbOp.Operation = OperationUpsert
+				if previous != nil {
+					dbOp.Operation = pbcodec.DBOp_OPERATION_UPDATE
+					dbOp.OldData = previous.value
+					delta.OldData = previous.data
+				}
+				dbOp.NewData = contractRow.Value
+				delta.NewData = data
+				if !m.irreversibleOnly {
+					m.rows[key] = &shipRow{value: contractRow.Value, data: data, blockNum: block.Number}
+				}
+			} else {
+				dbOp.Operation = pbcodec.DBOp_OPERATION_REMOVE
+				dbOp.OldData = contractRow.Value
+				delta.OldData = data
+				delete(m.rows, key)
+			}
+			delta.Operation = dbOp.Operation
+			dbOps = append(dbOps, dbOp)
+			deltas = append(deltas, &shipDelta{
+				delta:    delta,
+				cursor:   ShipCursor(block.Id, block.Number, len(deltas)+1),
+				previous: previous,
+			})
+		}
+	}
+	block.UnfilteredTransactionTraces = []*pbcodec.TransactionTrace{{DbOps: dbOps}}
+	return deltas, nil
+}
+
+// Undoes the deltas of the reversible blocks from the last one processed down to the specified
+// block, in reverse order
+func (m *shipStream) undo(blockNum uint32) {
+	for i := len(m.reversible) - 1; i >= 0 && m.reversible[i].num >= blockNum; i-- {
+		reversible := m.reversible[i]
+		m.reversible = m.reversible[:i]
+		for j := len(reversible.deltas) - 1; j >= 0; j-- {
+			m.revertRow(reversible.deltas[j])
+		}
+		if len(m.forkSteps) > 0 && !m.forkSteps[pbbstream.ForkStep_STEP_UNDO] {
+			continue
+		}
+		cursor := ShipCursor(reversible.prevId, reversible.num-1, 0)
+		for j := len(reversible.deltas) - 1; j >= 0; j-- {
+			delta := reversible.deltas[j].delta
+			if m.request.ReverseUndoOps {
+				delta = reverseDelta(delta)
+			}
+			m.handler.OnDelta(delta, cursor, pbbstream.ForkStep_STEP_UNDO)
+		}
+	}
+}
+
+// Restores the kept value of the row changed by the delta to the one it had before the delta,
+// the value is dropped if it was unknown or set in a block that became irreversible since
+func (m *shipStream) revertRow(delta *shipDelta) {
+	key := rowKey(delta.delta.DBOp)
+	if delta.previous == nil || delta.previous.blockNum <= m.lastIrreversible {
+		delete(m.rows, key)
+		return
+	}
+	m.rows[key] = delta.previous
+}
+
+// Returns the key that identifies the row changed by the operation
+func rowKey(dbOp *pbcodec.DBOp) string {
+	return fmt.Sprintf("%v/%v/%v/%v", dbOp.Code, dbOp.Scope, dbOp.TableName, dbOp.PrimaryKey)
+}
+
+// Removes the blocks that became irreversible along with the values of the rows last changed
+// in them, so that only the rows changed in reversible blocks are kept
+func (m *shipStream) pruneReversible(lastIrreversible uint32) {
+	m.lastIrreversible = lastIrreversible
+	i := 0
+	for ; i < len(m.reversible) && m.reversible[i].num <= lastIrreversible; i++ {
+		for _, delta := range m.reversible[i].deltas {
+			key := rowKey(delta.delta.DBOp)
+			if row, ok := m.rows[key]; ok && row.blockNum <= lastIrreversible {
+				delete(m.rows, key)
+			}
+		}
+	}
+	m.reversible = m.reversible[i:]
+}
+
+// Returns the delta that reverts the one specified, the previous value of upserted rows is not
+// known so their reversed delta has no new data
+func reverseDelta(delta *dfclient.TableDelta) *dfclient.TableDelta {
+	reversed := *delta
+	reversed.OldData = delta.NewData
+	reversed.NewData = delta.OldData
+	if delta.Operation == pbcodec.DBOp_OPERATION_INSERT {
+		reversed.Operation = pbcodec.DBOp_OPERATION_REMOVE
+	} else if delta.Operation == pbcodec.DBOp_OPERATION_REMOVE {
+		reversed.Operation = pbcodec.DBOp_OPERATION_INSERT
+	}
+	return &reversed
+}
+
+// Returns the cursor for a position in the state history stream
+func ShipCursor(blockId string, blockNum uint32, deltaIndex int) string {
+	return fmt.Sprintf("%v__%v__0__%v", blockId, blockNum, deltaIndex)
+}
+
+// Result of a state history get blocks request, the block is kept in its binary form as only
+// its timestamp is used
+type ShipBlocksResult struct {
+	Head             *ship.BlockPosition
+	LastIrreversible *ship.BlockPosition
+	ThisBlock        *ship.BlockPosition
+	PrevBlock        *ship.BlockPosition
+	Block            []byte
+	Deltas           []*ship.TableDeltaV0
+}
+
+// Decodes a get_blocks_result_v0 message
+func DecodeShipBlocksResult(data []byte) (*ShipBlocksResult, error) {
+	decoder := eos.NewDecoder(data)
+	typeID, err := decoder.ReadUvarint32()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read result type, error: %v", err)
+	}
+	if typeID != ship.ResultVariant.TypeID("get_blocks_result_v0") {
+		return nil, fmt.Errorf("unexpected result type: %v", typeID)
+	}
+	result := &ShipBlocksResult{
+		Head:             &ship.BlockPosition{},
+		LastIrreversible: &ship.BlockPosition{},
+	}
+	err = decoder.Decode(result.Head)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode head, error: %v", err)
+	}
+	err = decoder.Decode(result.LastIrreversible)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode last irreversible, error: %v", err)
+	}
+	result.ThisBlock, err = decodeOptionalBlockPosition(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode this block, error: %v", err)
+	}
+	result.PrevBlock, err = decodeOptionalBlockPosition(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode prev block, error: %v", err)
+	}
+	result.Block, err = decodeOptionalBytes(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode block, error: %v", err)
+	}
+	_, err = decodeOptionalBytes(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode traces, error: %v", err)
+	}
+	deltas, err := decodeOptionalBytes(decoder)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode deltas, error: %v", err)
+	}
+	if deltas != nil {
+		tableDeltas := &ship.TableDeltaArray{}
+		err = eos.UnmarshalBinary(deltas, &tableDeltas.Elem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode table deltas, error: %v", err)
+		}
+		for _, tableDelta := range tableDeltas.Elem {
+			deltaV0, ok := tableDelta.Impl.(*ship.TableDeltaV0)
+			if !ok {
+				return nil, fmt.Errorf("unexpected table delta type: %v", tableDelta.TypeID)
+			}
+			result.Deltas = append(result.Deltas, deltaV0)
+		}
+	}
+	return result, nil
+}
+
+// Returns the block of the result, only the id, number and timestamp are set
+func (m *ShipBlocksResult) GetBlock() (*pbcodec.Block, error) {
+	block := &pbcodec.Block{
+		Id:     m.ThisBlock.BlockID.String(),
+		Number: m.ThisBlock.BlockNum,
+		Header: &pbcodec.BlockHeader{},
+	}
+	if len(m.Block) > 0 {
+		// The timestamp is the first field of the signed block
+		blockTime, err := eos.NewDecoder(m.Block).ReadBlockTimestamp()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode block timestamp, error: %v", err)
+		}
+		block.Header.Timestamp, err = ptypes.TimestampProto(blockTime.Time)
+		if err != nil {
+			return nil, fmt.Errorf("invalid block timestamp: %v, error: %v", blockTime, err)
+		}
+	}
+	return block, nil
+}
+
+func decodeOptionalBlockPosition(decoder *eos.Decoder) (*ship.BlockPosition, error) {
+	present, err := decoder.ReadBool()
+	if err != nil || !present {
+		return nil, err
+	}
+	position := &ship.BlockPosition{}
+	err = decoder.Decode(position)
+	if err != nil {
+		return nil, err
+	}
+	return position, nil
+}
+
+func decodeOptionalBytes(decoder *eos.Decoder) ([]byte, error) {
+	present, err := decoder.ReadBool()
+	if err != nil || !present {
+		return nil, err
+	}
+	return decoder.ReadByteArray()
+}
+
+// Row of a contract table as provided by state history
+type ShipContractRow struct {
+	Code       eos.Name
+	Scope      eos.Name
+	Table      eos.Name
+	PrimaryKey uint64
+	Payer      eos.Name
+	Value      []byte
+}
+
+// Decodes a contract_row_v0 row
+func DecodeShipContractRow(data []byte) (*ShipContractRow, error) {
+	decoder := eos.NewDecoder(data)
+	version, err := decoder.ReadUvarint32()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read contract row version, error: %v", err)
+	}
+	if version != 0 {
+		return nil, fmt.Errorf("unsupported contract row version: %v", version)
+	}
+	row := &ShipContractRow{}
+	err = decoder.Decode(row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode contract row, error: %v", err)
+	}
+	return row, nil
+}
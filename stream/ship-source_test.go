@@ -0,0 +1,439 @@
+package stream_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	pbcodec "github.com/dfuse-io/dfuse-eosio/pb/dfuse/eosio/codec/v1"
+	eos "github.com/eoscanada/eos-go"
+	"github.com/eoscanada/eos-go/ship"
+	"github.com/gorilla/websocket"
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/stream"
+	pbbstream "github.com/streamingfast/pbgo/dfuse/bstream/v1"
+	"gotest.tools/assert"
+)
+
+const testABI = `{
+	"version": "eosio::abi/1.1",
+	"structs": [
+		{"name": "document", "base": "", "fields": [{"name": "id", "type": "uint64"}, {"name": "creator", "type": "name"}]},
+		{"name": "edge", "base": "", "fields": [{"name": "id", "type": "uint64"}, {"name": "from_node", "type": "uint64"}, {"name": "to_node", "type": "uint64"}, {"name": "edge_name", "type": "name"}]}
+	],
+	"tables": [
+		{"name": "documents", "index_type": "i64", "type": "document"},
+		{"name": "edges", "index_type": "i64", "type": "edge"},
+		{"name": "votes", "index_type": "i64", "type": "document"}
+	]
+}`
+
+var testBlockTime = time.Date(2022, 3, 4, 10, 20, 30, 500000000, time.UTC)
+
+type abiDecoder struct {
+	abi *eos.ABI
+}
+
+func (m *abiDecoder) Decode(account eos.AccountName, table eos.TableName, data []byte) ([]byte, error) {
+	return m.abi.DecodeTableRowTyped(m.abi.TableForName(table).Type, data)
+}
+
+type testRow struct {
+	present bool
+	table   string
+	json    string
+	// Defaults to the position of the row in the block
+	primaryKey uint64
+}
+
+type testBlock struct {
+	num  uint32
+	fork byte
+	lib  uint32
+	rows []*testRow
+}
+
+// Stub state history endpoint, sends the ABI, records the get blocks request and sends the blocks
+type shipServer struct {
+	t       *testing.T
+	abi     *eos.ABI
+	blocks  []*testBlock
+	request *ship.GetBlocksRequestV0
+}
+
+func (m *shipServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(w, r, nil)
+	assert.NilError(m.t, err)
+	defer conn.Close()
+	err = conn.WriteMessage(websocket.TextMessage, []byte("{}"))
+	assert.NilError(m.t, err)
+	_, message, err := conn.ReadMessage()
+	assert.NilError(m.t, err)
+	request := &ship.Request{}
+	err = eos.UnmarshalBinary(message, request)
+	assert.NilError(m.t, err)
+	m.request = request.Impl.(*ship.GetBlocksRequestV0)
+	for _, block := range m.blocks {
+		if block.num < m.request.StartBlockNum {
+			continue
+		}
+		err = conn.WriteMessage(websocket.BinaryMessage, m.encodeResult(block))
+		assert.NilError(m.t, err)
+	}
+	for {
+		_, _, err = conn.ReadMessage()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (m *shipServer) encodeResult(block *testBlock) []byte {
+	buf := &bytes.Buffer{}
+	write := func(v interface{}) {
+		data, err := eos.MarshalBinary(v)
+		assert.NilError(m.t, err)
+		buf.Write(data)
+	}
+	write(eos.Varuint32(ship.ResultVariant.TypeID("get_blocks_result_v0")))
+	write(blockPosition(block.num+10, 0))
+	write(blockPosition(block.lib, 0))
+	write(true)
+	write(blockPosition(block.num, block.fork))
+	write(true)
+	write(blockPosition(block.num-1, 0))
+	write(true)
+	blockData, err := eos.MarshalBinary(eos.BlockTimestamp{Time: testBlockTime})
+	assert.NilError(m.t, err)
+	write(blockData)
+	write(false)
+	write(true)
+	rows := make([]ship.Row, 0, len(block.rows))
+	for i, row := range block.rows {
+		value, err := m.abi.EncodeTable(eos.TableName(row.table), []byte(row.json))
+		assert.NilError(m.t, err)
+		rowData, err := eos.MarshalBinary(eos.Varuint32(0))
+		assert.NilError(m.t, err)
+		primaryKey := row.primaryKey
+		if primaryKey == 0 {
+			primaryKey = uint64(i + 1)
+		}
+		contractRow, err := eos.MarshalBinary(&stream.ShipContractRow{
+			Code:       "dao.hypha",
+			Scope:      "dao.hypha",
+			Table:      eos.Name(row.table),
+			PrimaryKey: primaryKey,
+			Payer:      "dao.hypha",
+			Value:      value,
+		})
+		assert.NilError(m.t, err)
+		rows = append(rows, ship.Row{Present: row.present, Data: append(rowData, contractRow...)})
+	}
+	deltas := &bytes.Buffer{}
+	for _, v := range []interface{}{
+		eos.Varuint32(2),
+		eos.Varuint32(0),
+		&ship.TableDeltaV0{Name: "account", Rows: []ship.Row{}},
+		eos.Varuint32(0),
+		&ship.TableDeltaV0{Name: stream.ShipContractRowTable, Rows: rows},
+	} {
+		data, err := eos.MarshalBinary(v)
+		assert.NilError(m.t, err)
+		deltas.Write(data)
+	}
+	write(deltas.Bytes())
+	return buf.Bytes()
+}
+
+func blockPosition(num uint32, fork byte) *ship.BlockPosition {
+	id := make([]byte, 32)
+	id[0] = fork
+	id[31] = byte(num)
+	return &ship.BlockPosition{BlockNum: num, BlockID: eos.Checksum256(id)}
+}
+
+func blockId(num uint32, fork byte) string {
+	return blockPosition(num, fork).BlockID.String()
+}
+
+func newShipServer(t *testing.T, blocks []*testBlock) (*shipServer, string) {
+	abi, err := eos.NewABI(strings.NewReader(testABI))
+	assert.NilError(t, err)
+	server := &shipServer{
+		t:      t,
+		abi:    abi,
+		blocks: blocks,
+	}
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+	return server, "ws" + strings.TrimPrefix(httpServer.URL, "http")
+}
+
+func TestShipSource(t *testing.T) {
+	server, endpoint := newShipServer(t, []*testBlock{
+		{num: 10, lib: 5, rows: []*testRow{
+			{present: true, table: "documents", json: `{"id":1,"creator":"dao.hypha"}`},
+			{present: true, table: "votes", json: `{"id":2,"creator":"dao.hypha"}`},
+			{present: true, table: "edges", json: `{"id":3,"from_node":1,"to_node":2,"edge_name":"member"}`},
+		}},
+		{num: 11, lib: 5},
+		{num: 12, lib: 5, rows: []*testRow{
+			{present: false, table: "documents", json: `{"id":1,"creator":"dao.hypha"}`},
+		}},
+	})
+	handler := &recordingHandler{}
+	request := getTestRequest()
+	request.StartBlockNum = 10
+	request.StopBlockNum = 12
+	source := stream.NewShipSource(endpoint, &abiDecoder{abi: server.abi})
+	source.DeltaStream(context.Background(), request, handler)
+
+	assert.Equal(t, len(handler.errors), 0)
+	assert.Equal(t, server.request.StartBlockNum, uint32(10))
+	assert.Equal(t, server.request.EndBlockNum, uint32(13))
+	assert.Equal(t, server.request.FetchDeltas, true)
+	assert.Equal(t, server.request.IrreversibleOnly, false)
+	assert.DeepEqual(t, handler.cursors(), []string{
+		stream.ShipCursor(blockId(10, 0), 10, 1),
+		stream.ShipCursor(blockId(10, 0), 10, 2),
+		stream.ShipCursor(blockId(11, 0), 11, 0),
+		stream.ShipCursor(blockId(12, 0), 12, 1),
+	})
+	insertDoc := handler.events[0].delta
+	assert.Equal(t, insertDoc.Operation, stream.OperationUpsert)
+	assert.Equal(t, insertDoc.TableName, "documents")
+	assert.Equal(t, string(insertDoc.NewData), `{"creator":"dao.hypha","id":1}`)
+	assert.Equal(t, insertDoc.Block.Id, blockId(10, 0))
+	blockTime, err := insertDoc.Block.Time()
+	assert.NilError(t, err)
+	assert.Equal(t, blockTime, testBlockTime)
+
+	insertEdge := handler.events[1].delta
+	assert.Equal(t, insertEdge.Operation, stream.OperationUpsert)
+	assert.Equal(t, string(insertEdge.NewData), `{"edge_name":"member","from_node":1,"id":3,"to_node":2}`)
+	dbOps := insertEdge.Block.TransactionTraces()[0].DbOps
+	assert.Equal(t, len(dbOps), 2)
+	assert.Equal(t, dbOps[1], insertEdge.DBOp)
+
+	assert.Equal(t, handler.events[2].kind, "heartbeat")
+
+	removeDoc := handler.events[3].delta
+	assert.Equal(t, removeDoc.Operation, pbcodec.DBOp_OPERATION_REMOVE)
+	assert.Equal(t, string(removeDoc.OldData), `{"creator":"dao.hypha","id":1}`)
+	assert.Assert(t, removeDoc.NewData == nil)
+	assert.Equal(t, handler.events[3].forkStep, pbbstream.ForkStep_STEP_NEW)
+
+	assert.Equal(t, handler.completed, true)
+	assert.Equal(t, handler.lastBlockRef.Num(), uint64(12))
+}
+
+func TestShipSourceFork(t *testing.T) {
+	server, endpoint := newShipServer(t, []*testBlock{
+		{num: 10, lib: 5, rows: []*testRow{
+			{present: true, table: "documents", json: `{"id":1,"creator":"dao.hypha"}`},
+		}},
+		{num: 11, lib: 5, rows: []*testRow{
+			{present: true, table: "edges", json: `{"id":3,"from_node":1,"to_node":2,"edge_name":"member"}`},
+		}},
+		{num: 11, fork: 1, lib: 5, rows: []*testRow{
+			{present: true, table: "documents", json: `{"id":2,"creator":"dao.hypha"}`},
+		}},
+		{num: 12, lib: 5},
+	})
+	handler := &recordingHandler{}
+	request := getTestRequest()
+	request.StartBlockNum = 10
+	request.StopBlockNum = 12
+	request.ReverseUndoOps = true
+	request.HeartBeatFrequency = 10
+	source := stream.NewShipSource(endpoint, &abiDecoder{abi: server.abi})
+	source.DeltaStream(context.Background(), request, handler)
+
+	assert.Equal(t, len(handler.errors), 0)
+	assert.DeepEqual(t, handler.cursors(), []string{
+		stream.ShipCursor(blockId(10, 0), 10, 1),
+		stream.ShipCursor(blockId(11, 0), 11, 1),
+		stream.ShipCursor(blockId(10, 0), 10, 0),
+		stream.ShipCursor(blockId(11, 1), 11, 1),
+	})
+	undo := handler.events[2]
+	assert.Equal(t, undo.forkStep, pbbstream.ForkStep_STEP_UNDO)
+	assert.Equal(t, undo.blockNum, uint32(11))
+	//The previous value of the upserted row is not known, so the reversed delta has no new data
+	assert.Equal(t, undo.delta.Operation, stream.OperationUpsert)
+	assert.Equal(t, string(undo.delta.OldData), `{"edge_name":"member","from_node":1,"id":3,"to_node":2}`)
+	assert.Assert(t, undo.delta.NewData == nil)
+	assert.Equal(t, handler.events[3].forkStep, pbbstream.ForkStep_STEP_NEW)
+	assert.Equal(t, handler.completed, true)
+}
+
+func TestShipSourceUpdate(t *testing.T) {
+	server, endpoint := newShipServer(t, []*testBlock{
+		{num: 10, lib: 5, rows: []*testRow{
+			{present: true, table: "edges", json: `{"id":3,"from_node":1,"to_node":2,"edge_name":"member"}`, primaryKey: 3},
+		}},
+		{num: 11, lib: 5, rows: []*testRow{
+			{present: true, table: "edges", json: `{"id":3,"from_node":1,"to_node":4,"edge_name":"member"}`, primaryKey: 3},
+		}},
+		{num: 11, fork: 1, lib: 5, rows: []*testRow{
+			{present: true, table: "edges", json: `{"id":3,"from_node":1,"to_node":5,"edge_name":"member"}`, primaryKey: 3},
+		}},
+		{num: 12, lib: 5, rows: []*testRow{
+			{present: false, table: "edges", json: `{"id":3,"from_node":1,"to_node":5,"edge_name":"member"}`, primaryKey: 3},
+			{present: true, table: "edges", json: `{"id":3,"from_node":1,"to_node":6,"edge_name":"member"}`, primaryKey: 3},
+		}},
+	})
+	handler := &recordingHandler{}
+	request := getTestRequest()
+	request.StartBlockNum = 10
+	request.StopBlockNum = 12
+	request.ReverseUndoOps = true
+	request.HeartBeatFrequency = 10
+	source := stream.NewShipSource(endpoint, &abiDecoder{abi: server.abi})
+	source.DeltaStream(context.Background(), request, handler)
+
+	assert.Equal(t, len(handler.errors), 0)
+	assert.Equal(t, len(handler.events), 6)
+	assert.Equal(t, handler.events[0].delta.Operation, stream.OperationUpsert)
+
+	update := handler.events[1].delta
+	assert.Equal(t, update.Operation, pbcodec.DBOp_OPERATION_UPDATE)
+	assert.Equal(t, string(update.OldData), `{"edge_name":"member","from_node":1,"id":3,"to_node":2}`)
+	assert.Equal(t, string(update.NewData), `{"edge_name":"member","from_node":1,"id":3,"to_node":4}`)
+	assert.Equal(t, update.DBOp.Operation, pbcodec.DBOp_OPERATION_UPDATE)
+	assert.Assert(t, len(update.DBOp.OldData) > 0)
+
+	undo := handler.events[2]
+	assert.Equal(t, undo.forkStep, pbbstream.ForkStep_STEP_UNDO)
+	assert.Equal(t, undo.delta.Operation, pbcodec.DBOp_OPERATION_UPDATE)
+	assert.Equal(t, string(undo.delta.NewData), `{"edge_name":"member","from_node":1,"id":3,"to_node":2}`)
+
+	//The row has the value it had before the undone block
+	forkUpdate := handler.events[3].delta
+	assert.Equal(t, forkUpdate.Operation, pbcodec.DBOp_OPERATION_UPDATE)
+	assert.Equal(t, string(forkUpdate.OldData), `{"edge_name":"member","from_node":1,"id":3,"to_node":2}`)
+
+	assert.Equal(t, handler.events[4].delta.Operation, pbcodec.DBOp_OPERATION_REMOVE)
+	//Once removed the previous value of the row is not known
+	upsert := handler.events[5].delta
+	assert.Equal(t, upsert.Operation, stream.OperationUpsert)
+	assert.Assert(t, upsert.OldData == nil)
+}
+
+func TestShipSourceUpsertAfterIrreversible(t *testing.T) {
+	server, endpoint := newShipServer(t, []*testBlock{
+		{num: 10, lib: 5, rows: []*testRow{
+			{present: true, table: "documents", json: `{"id":1,"creator":"dao.hypha"}`, primaryKey: 1},
+		}},
+		{num: 11, lib: 10},
+		{num: 12, lib: 10, rows: []*testRow{
+			{present: true, table: "documents", json: `{"id":1,"creator":"dao.vote"}`, primaryKey: 1},
+		}},
+	})
+	handler := &recordingHandler{}
+	request := getTestRequest()
+	request.StartBlockNum = 10
+	request.StopBlockNum = 12
+	request.HeartBeatFrequency = 10
+	source := stream.NewShipSource(endpoint, &abiDecoder{abi: server.abi})
+	source.DeltaStream(context.Background(), request, handler)
+
+	assert.Equal(t, len(handler.errors), 0)
+	assert.Equal(t, len(handler.events), 2)
+	//The row was last changed in a block that became irreversible, so its value is no longer kept
+	upsert := handler.events[1].delta
+	assert.Equal(t, upsert.Operation, stream.OperationUpsert)
+	assert.Assert(t, upsert.OldData == nil)
+	assert.Equal(t, string(upsert.NewData), `{"creator":"dao.vote","id":1}`)
+}
+
+func TestShipSourceResumeFromCursor(t *testing.T) {
+	blocks := []*testBlock{
+		{num: 10, lib: 5, rows: []*testRow{
+			{present: true, table: "documents", json: `{"id":1,"creator":"dao.hypha"}`},
+			{present: true, table: "documents", json: `{"id":2,"creator":"dao.hypha"}`},
+		}},
+		{num: 11, lib: 5, rows: []*testRow{
+			{present: true, table: "documents", json: `{"id":3,"creator":"dao.hypha"}`},
+		}},
+	}
+	server, endpoint := newShipServer(t, blocks)
+	handler := &recordingHandler{}
+	request := getTestRequest()
+	request.StartCursor = stream.ShipCursor(blockId(10, 0), 10, 1)
+	request.StopBlockNum = 11
+	request.HeartBeatFrequency = 10
+	source := stream.NewShipSource(endpoint, &abiDecoder{abi: server.abi})
+	source.DeltaStream(context.Background(), request, handler)
+
+	assert.Equal(t, len(handler.errors), 0)
+	assert.Equal(t, server.request.StartBlockNum, uint32(10))
+	assert.DeepEqual(t, handler.cursors(), []string{
+		stream.ShipCursor(blockId(10, 0), 10, 2),
+		stream.ShipCursor(blockId(11, 0), 11, 1),
+	})
+
+	server, endpoint = newShipServer(t, blocks)
+	handler = &recordingHandler{}
+	request.StartCursor = stream.ShipCursor(blockId(10, 0), 10, 0)
+	source = stream.NewShipSource(endpoint, &abiDecoder{abi: server.abi})
+	source.DeltaStream(context.Background(), request, handler)
+
+	assert.Equal(t, len(handler.errors), 0)
+	assert.Equal(t, server.request.StartBlockNum, uint32(11))
+	assert.DeepEqual(t, handler.cursors(), []string{
+		stream.ShipCursor(blockId(11, 0), 11, 1),
+	})
+}
+
+func TestShipSourceConnectionError(t *testing.T) {
+	handler := &recordingHandler{}
+	source := stream.NewShipSource("ws://127.0.0.1:1", &abiDecoder{})
+	source.DeltaStream(context.Background(), getTestRequest(), handler)
+	assert.Equal(t, len(handler.errors), 1)
+	assert.Equal(t, handler.completed, false)
+}
+
+func TestShipSourceCancel(t *testing.T) {
+	server, endpoint := newShipServer(t, []*testBlock{
+		{num: 10, lib: 5, rows: []*testRow{
+			{present: true, table: "documents", json: `{"id":1,"creator":"dao.hypha"}`},
+		}},
+	})
+	handler := &recordingHandler{}
+	request := getTestRequest()
+	request.StartBlockNum = 10
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	source := stream.NewShipSource(endpoint, &abiDecoder{abi: server.abi})
+	//Blocks until the context is cancelled as there is no stop block
+	source.DeltaStream(ctx, request, handler)
+
+	assert.Equal(t, len(handler.events), 1)
+	assert.Equal(t, len(handler.errors), 0)
+	assert.Equal(t, handler.completed, false)
+}
+
+func TestShipSourceIrreversibleOnly(t *testing.T) {
+	server, endpoint := newShipServer(t, []*testBlock{
+		{num: 10, lib: 10, rows: []*testRow{
+			{present: true, table: "documents", json: `{"id":1,"creator":"dao.hypha"}`},
+		}},
+	})
+	handler := &recordingHandler{}
+	request := getTestRequest()
+	request.ForkSteps = []pbbstream.ForkStep{pbbstream.ForkStep_STEP_IRREVERSIBLE}
+	request.StartBlockNum = 10
+	request.StopBlockNum = 10
+	source := stream.NewShipSource(endpoint, &abiDecoder{abi: server.abi})
+	source.DeltaStream(context.Background(), request, handler)
+
+	assert.Equal(t, len(handler.errors), 0)
+	assert.Equal(t, server.request.IrreversibleOnly, true)
+	assert.Equal(t, len(handler.events), 1)
+	assert.Equal(t, handler.events[0].forkStep, pbbstream.ForkStep_STEP_IRREVERSIBLE)
+	assert.Equal(t, handler.completed, true)
+}
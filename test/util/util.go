@@ -19,7 +19,9 @@ func AssertSimplifiedInstance(t *testing.T, actual, expected *gql.SimplifiedInst
 	for name := range expected.Values {
 		field := expected.SimplifiedType.GetField(name)
 		assert.Assert(t, field != nil, "Expected field: %v not found, type: %v", name, expected.SimplifiedType)
-		if field.IsEdge() {
+		if field.Type == gql.CertificateSimplifiedType.Name {
+			AssertCertificates(t, actual.GetValue(name), expected.GetValue(name))
+		} else if field.IsEdge() {
 			AssertEdge(t, actual.GetValue(name), expected.GetValue(name))
 		} else if field.IsCoreEdge() {
 			AssertCoreEdge(t, actual.GetValue(name), expected.GetValue(name))
@@ -41,6 +43,32 @@ func AssertEdge(t *testing.T, actual, expected interface{}) {
 	}
 }
 
+func AssertCertificates(t *testing.T, actual, expected interface{}) {
+	if expected == nil {
+		assert.Assert(t, actual == nil, "Expected certificates are nil, but actual are not: %v", actual)
+		return
+	}
+	e := expected.([]map[string]interface{})
+	a, ok := actual.([]interface{})
+	if !ok {
+		a = make([]interface{}, 0)
+		for _, certificate := range actual.([]map[string]interface{}) {
+			a = append(a, certificate)
+		}
+	}
+	assert.Equal(t, len(a), len(e))
+	for _, expectedCertificate := range e {
+		found := false
+		for _, actualCertificate := range a {
+			if actualCertificate.(map[string]interface{})["id"] == expectedCertificate["id"] {
+				assert.DeepEqual(t, actualCertificate, expectedCertificate)
+				found = true
+			}
+		}
+		assert.Assert(t, found, "certificate: %v, not found", expectedCertificate)
+	}
+}
+
 func AssertCoreEdge(t *testing.T, actual, expected interface{}) {
 	if expected == nil {
 		assert.Assert(t, actual == nil)
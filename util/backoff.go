@@ -0,0 +1,42 @@
+package util
+
+import (
+	"time"
+)
+
+// Exponential backoff, the delay doubles with each consecutive failure up to the maximum
+type Backoff struct {
+	MinDelay time.Duration
+	MaxDelay time.Duration
+	failures uint
+}
+
+func NewBackoff(minDelay, maxDelay time.Duration) *Backoff {
+	return &Backoff{
+		MinDelay: minDelay,
+		MaxDelay: maxDelay,
+	}
+}
+
+// Records a failure and returns the delay to wait before the next attempt
+func (m *Backoff) Next() time.Duration {
+	delay := m.MinDelay
+	for i := uint(0); i < m.failures && delay < m.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > m.MaxDelay {
+		delay = m.MaxDelay
+	}
+	m.failures++
+	return delay
+}
+
+// Restarts the delays from the minimum delay
+func (m *Backoff) Reset() {
+	m.failures = 0
+}
+
+// Returns the number of consecutive failures
+func (m *Backoff) Failures() uint {
+	return m.failures
+}
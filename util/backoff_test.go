@@ -0,0 +1,24 @@
+package util_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sebastianmontero/hypha-document-cache-gql-go/util"
+	"gotest.tools/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	backoff := util.NewBackoff(time.Second, 10*time.Second)
+	assert.Equal(t, backoff.Next(), time.Second)
+	assert.Equal(t, backoff.Next(), 2*time.Second)
+	assert.Equal(t, backoff.Next(), 4*time.Second)
+	assert.Equal(t, backoff.Next(), 8*time.Second)
+	assert.Equal(t, backoff.Next(), 10*time.Second)
+	assert.Equal(t, backoff.Next(), 10*time.Second)
+	assert.Equal(t, backoff.Failures(), uint(6))
+
+	backoff.Reset()
+	assert.Equal(t, backoff.Failures(), uint(0))
+	assert.Equal(t, backoff.Next(), time.Second)
+}